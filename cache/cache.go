@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 var NotFound = errors.New("not found")
@@ -11,8 +12,29 @@ func IsNotFound(err error) bool {
 	return errors.Is(err, NotFound)
 }
 
+// Cache is implemented by key-value stores used to cache fetched pages. Get
+// returns NotFound for missing keys, while Delete treats missing keys as
+// already deleted. Clear removes every entry.
 type Cache interface {
 	Get(ctx context.Context, key string) ([]byte, error)
 	Set(ctx context.Context, key string, value []byte) error
 	Delete(ctx context.Context, key string) error
+	Clear(ctx context.Context) error
+}
+
+// TTLCache is implemented by caches that support entries which expire. Get
+// must treat expired entries as not found.
+type TTLCache interface {
+	Cache
+	SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// SetWithTTL stores a value that expires after the given TTL if the cache
+// supports expiry. Otherwise, or if the TTL is not positive, the value is
+// stored without expiry.
+func SetWithTTL(ctx context.Context, c Cache, key string, value []byte, ttl time.Duration) error {
+	if ttlCache, ok := c.(TTLCache); ok && ttl > 0 {
+		return ttlCache.SetWithTTL(ctx, key, value, ttl)
+	}
+	return c.Set(ctx, key, value)
 }
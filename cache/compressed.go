@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// gzipMagic is the header that begins every gzip stream, which identifies
+// compressed values.
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// gzipWriters reuses gzip writers, which are expensive to allocate.
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(io.Discard) },
+}
+
+// CompressedCache implements the Cache interface by gzip compressing values
+// before storing them in another cache, which typically reduces the size of
+// cached HTML several times over. Values that do not begin with the gzip
+// header, such as those stored before compression was enabled, are returned
+// as they are.
+type CompressedCache struct {
+	inner Cache
+}
+
+// Compressed creates a cache that compresses the values stored in inner.
+func Compressed(inner Cache) *CompressedCache {
+	return &CompressedCache{inner: inner}
+}
+
+// Get returns the decompressed value stored for the key, or NotFound if there
+// is none.
+func (c *CompressedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.inner.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(value, gzipMagic) {
+		return value, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// Set compresses and stores the value.
+func (c *CompressedCache) Set(ctx context.Context, key string, value []byte) error {
+	compressed, err := compress(value)
+	if err != nil {
+		return err
+	}
+	return c.inner.Set(ctx, key, compressed)
+}
+
+// SetWithTTL compresses and stores the value with the given TTL, if the inner
+// cache supports expiry.
+func (c *CompressedCache) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	compressed, err := compress(value)
+	if err != nil {
+		return err
+	}
+	return SetWithTTL(ctx, c.inner, key, compressed, ttl)
+}
+
+// Delete removes the key.
+func (c *CompressedCache) Delete(ctx context.Context, key string) error {
+	return c.inner.Delete(ctx, key)
+}
+
+// Clear removes all entries.
+func (c *CompressedCache) Clear(ctx context.Context) error {
+	return c.inner.Clear(ctx)
+}
+
+// compress returns the value gzip compressed.
+func compress(value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(writer)
+	writer.Reset(&buf)
+	if _, err := writer.Write(value); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
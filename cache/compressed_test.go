@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testPage returns an HTML page of roughly the given size, with repetition
+// typical of real pages.
+func testPage(size int) []byte {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html><html><head><title>Products</title></head><body><ul>")
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, `<li class="product"><a href="/products/%d">Product %d</a><span class="price">$%d.99</span></li>`, i, i, i%100)
+	}
+	b.WriteString("</ul></body></html>")
+	return []byte(b.String())
+}
+
+func TestCompressed(t *testing.T) {
+	ctx := context.Background()
+	inner := NewInMemoryCache()
+	c := Compressed(inner)
+
+	_, err := c.Get(ctx, "https://example.com")
+	require.True(t, IsNotFound(err))
+
+	page := testPage(64 * 1024)
+	require.NoError(t, c.Set(ctx, "https://example.com", page))
+	value, err := c.Get(ctx, "https://example.com")
+	require.NoError(t, err)
+	require.Equal(t, page, value)
+
+	// The value is stored compressed
+	stored, err := inner.Get(ctx, "https://example.com")
+	require.NoError(t, err)
+	require.Less(t, len(stored), len(page)/4)
+
+	// Empty values round trip
+	require.NoError(t, c.Set(ctx, "empty", []byte{}))
+	value, err = c.Get(ctx, "empty")
+	require.NoError(t, err)
+	require.Empty(t, value)
+
+	// Uncompressed values stored previously are returned as they are
+	require.NoError(t, inner.Set(ctx, "legacy", []byte("<p>Legacy</p>")))
+	value, err = c.Get(ctx, "legacy")
+	require.NoError(t, err)
+	require.Equal(t, []byte("<p>Legacy</p>"), value)
+
+	require.NoError(t, c.Delete(ctx, "https://example.com"))
+	_, err = inner.Get(ctx, "https://example.com")
+	require.True(t, IsNotFound(err))
+	require.NoError(t, c.Clear(ctx))
+	_, err = inner.Get(ctx, "legacy")
+	require.True(t, IsNotFound(err))
+}
+
+func TestCompressed_TTL(t *testing.T) {
+	ctx := context.Background()
+	c := Compressed(NewInMemoryCache())
+	require.NoError(t, SetWithTTL(ctx, c, "key", []byte("value"), 50*time.Millisecond))
+	value, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), value)
+	time.Sleep(100 * time.Millisecond)
+	_, err = c.Get(ctx, "key")
+	require.True(t, IsNotFound(err))
+}
+
+// BenchmarkCompressed compares storing and loading pages with and without
+// compression. The stored size is reported as a percentage of the original.
+func BenchmarkCompressed(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range []int{4 * 1024, 64 * 1024, 512 * 1024} {
+		page := testPage(size)
+		caches := []struct {
+			name  string
+			cache func(inner Cache) Cache
+		}{
+			{"plain", func(inner Cache) Cache { return inner }},
+			{"gzip", func(inner Cache) Cache { return Compressed(inner) }},
+		}
+		for _, tc := range caches {
+			b.Run(fmt.Sprintf("%s/%dKB", tc.name, size/1024), func(b *testing.B) {
+				inner := NewInMemoryCache()
+				c := tc.cache(inner)
+				b.SetBytes(int64(len(page)))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if err := c.Set(ctx, "key", page); err != nil {
+						b.Fatal(err)
+					}
+					if _, err := c.Get(ctx, "key"); err != nil {
+						b.Fatal(err)
+					}
+				}
+				stored, _ := inner.Get(ctx, "key")
+				b.ReportMetric(100*float64(len(stored))/float64(len(page)), "%stored")
+			})
+		}
+	}
+}
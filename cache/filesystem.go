@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempPrefix marks files that are still being written.
+const tempPrefix = ".tmp-"
+
+// Filesystem implements the Cache interface by storing each value in its own
+// file. Keys are hashed to file names, which are sharded into subdirectories
+// by the first two hex characters of the hash. It is safe for use by multiple
+// goroutines and processes sharing the same directory.
+type Filesystem struct {
+	rootDir string
+}
+
+// NewFilesystem creates a cache that stores files under the given directory.
+// The directory is created when the first value is stored.
+func NewFilesystem(rootDir string) *Filesystem {
+	return &Filesystem{rootDir: rootDir}
+}
+
+// path returns the file path used to store the key.
+func (f *Filesystem) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(f.rootDir, name[:2], name)
+}
+
+// Get returns the value stored for the key, or NotFound if there is none.
+func (f *Filesystem) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := os.ReadFile(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Set stores the value. The value is written to a temporary file which is
+// then renamed into place, so readers never observe a partial write.
+func (f *Filesystem) Set(ctx context.Context, key string, value []byte) error {
+	path := f.path(key)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Delete removes the key. Deleting a missing key is not an error.
+func (f *Filesystem) Delete(ctx context.Context, key string) error {
+	err := os.Remove(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Clear removes all entries from the cache.
+func (f *Filesystem) Clear(ctx context.Context) error {
+	entries, err := os.ReadDir(f.rootDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(f.rootDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Size returns the total number of bytes stored in the cache.
+func (f *Filesystem) Size() (int64, error) {
+	var total int64
+	err := filepath.WalkDir(f.rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tempPrefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
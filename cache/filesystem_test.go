@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesystem(t *testing.T) {
+	ctx := context.Background()
+	rootDir := filepath.Join(t.TempDir(), "cache")
+	c := NewFilesystem(rootDir)
+
+	_, err := c.Get(ctx, "https://example.com")
+	require.True(t, IsNotFound(err))
+
+	size, err := c.Size()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), size)
+
+	require.NoError(t, c.Set(ctx, "https://example.com", []byte("hello")))
+	require.NoError(t, c.Set(ctx, "https://example.com/about", []byte("world!")))
+
+	value, err := c.Get(ctx, "https://example.com")
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), value)
+
+	// Values are sharded into subdirectories
+	path := c.path("https://example.com")
+	require.Equal(t, filepath.Base(path)[:2], filepath.Base(filepath.Dir(path)))
+	require.FileExists(t, path)
+
+	size, err = c.Size()
+	require.NoError(t, err)
+	require.Equal(t, int64(11), size)
+
+	require.NoError(t, c.Delete(ctx, "https://example.com"))
+	require.NoError(t, c.Delete(ctx, "https://example.com"))
+	_, err = c.Get(ctx, "https://example.com")
+	require.True(t, IsNotFound(err))
+
+	require.NoError(t, c.Clear(ctx))
+	_, err = c.Get(ctx, "https://example.com/about")
+	require.True(t, IsNotFound(err))
+	entries, err := os.ReadDir(rootDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestFilesystem_ConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	c := NewFilesystem(t.TempDir())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value := []byte(fmt.Sprintf("value-%02d", i))
+			assert.NoError(t, c.Set(ctx, "key", value))
+			got, err := c.Get(ctx, "key")
+			assert.NoError(t, err)
+			assert.Len(t, got, len(value))
+		}(i)
+	}
+	wg.Wait()
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(c.path("key")))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
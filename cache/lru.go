@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRU implements the Cache interface in memory, holding up to a maximum
+// number of entries. When it is full, storing a new key evicts the least
+// recently used entry, where both Get and Set count as a use. Entries stored
+// with SetWithTTL expire like those of other caches. It is safe for use by
+// multiple goroutines.
+type LRU struct {
+	mutex      sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+	size       int64
+}
+
+// lruEntry is the value held by each element of the LRU's list.
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewLRU creates a cache that holds up to maxEntries entries. A maxEntries of
+// zero or less means no limit.
+func NewLRU(maxEntries int) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the value stored for the key, or NotFound if there is none or
+// it has expired.
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, exists := c.entries[key]
+	if !exists {
+		return nil, NotFound
+	}
+	entry := element.Value.(*lruEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		return nil, NotFound
+	}
+	c.order.MoveToFront(element)
+	return entry.value, nil
+}
+
+// Set stores the value without expiry.
+func (c *LRU) Set(ctx context.Context, key string, value []byte) error {
+	c.set(key, value, time.Time{})
+	return nil
+}
+
+// SetWithTTL stores the value, which expires after the given TTL.
+func (c *LRU) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.set(key, value, time.Now().Add(ttl))
+	return nil
+}
+
+// Delete removes the key. Deleting a missing key is not an error.
+func (c *LRU) Delete(ctx context.Context, key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, exists := c.entries[key]; exists {
+		c.remove(element)
+	}
+	return nil
+}
+
+// Clear removes all entries from the cache.
+func (c *LRU) Clear(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	clear(c.entries)
+	c.order.Init()
+	c.size = 0
+	return nil
+}
+
+// Len returns the number of entries in the cache, including any that have
+// expired but not yet been removed.
+func (c *LRU) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+// Size returns the total number of bytes of the values in the cache.
+func (c *LRU) Size() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.size
+}
+
+func (c *LRU) set(key string, value []byte, expiresAt time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, exists := c.entries[key]; exists {
+		entry := element.Value.(*lruEntry)
+		c.size += int64(len(value) - len(entry.value))
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	c.size += int64(len(value))
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove deletes the element's entry. The caller must hold the mutex.
+func (c *LRU) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*lruEntry)
+	delete(c.entries, entry.key)
+	c.size -= int64(len(entry.value))
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+
+	_, err := c.Get(ctx, "a")
+	require.True(t, IsNotFound(err))
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1")))
+	require.NoError(t, c.Set(ctx, "b", []byte("22")))
+	require.Equal(t, 2, c.Len())
+	require.Equal(t, int64(3), c.Size())
+
+	// Reading a makes b the least recently used, so b is evicted
+	_, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.NoError(t, c.Set(ctx, "c", []byte("333")))
+	require.Equal(t, 2, c.Len())
+	require.Equal(t, int64(4), c.Size())
+	_, err = c.Get(ctx, "b")
+	require.True(t, IsNotFound(err))
+
+	// Replacing a value updates the size without evicting
+	require.NoError(t, c.Set(ctx, "a", []byte("four")))
+	require.Equal(t, 2, c.Len())
+	require.Equal(t, int64(7), c.Size())
+	value, err := c.Get(ctx, "c")
+	require.NoError(t, err)
+	require.Equal(t, []byte("333"), value)
+
+	require.NoError(t, c.Delete(ctx, "a"))
+	require.NoError(t, c.Delete(ctx, "a"))
+	require.Equal(t, 1, c.Len())
+	require.Equal(t, int64(3), c.Size())
+
+	require.NoError(t, c.Clear(ctx))
+	require.Equal(t, 0, c.Len())
+	require.Equal(t, int64(0), c.Size())
+	_, err = c.Get(ctx, "c")
+	require.True(t, IsNotFound(err))
+}
+
+func TestLRU_TTL(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(10)
+
+	require.NoError(t, SetWithTTL(ctx, c, "short", []byte("value"), 50*time.Millisecond))
+	require.NoError(t, SetWithTTL(ctx, c, "long", []byte("value"), time.Hour))
+	time.Sleep(100 * time.Millisecond)
+
+	_, err := c.Get(ctx, "short")
+	require.True(t, IsNotFound(err))
+	_, err = c.Get(ctx, "long")
+	require.NoError(t, err)
+	require.Equal(t, 1, c.Len())
+}
+
+func TestLRU_Unbounded(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(0)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, c.Set(ctx, fmt.Sprint(i), []byte("x")))
+	}
+	require.Equal(t, 100, c.Len())
+}
+
+func TestLRU_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(50)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprint(j % 80)
+				c.Set(ctx, key, []byte(key))
+				c.Get(ctx, key)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 50, c.Len())
+
+	var size int64
+	for i := 0; i < 80; i++ {
+		if value, err := c.Get(ctx, fmt.Sprint(i)); err == nil {
+			size += int64(len(value))
+		}
+	}
+	require.Equal(t, size, c.Size())
+}
@@ -3,17 +3,27 @@ package cache
 import (
 	"context"
 	"sync"
+	"time"
 )
 
+type inMemoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (e inMemoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
 // InMemoryCache implements the cache.Cache interface for testing
 type InMemoryCache struct {
-	data  map[string][]byte
+	data  map[string]inMemoryEntry
 	mutex sync.RWMutex
 }
 
 func NewInMemoryCache() *InMemoryCache {
 	return &InMemoryCache{
-		data: make(map[string][]byte),
+		data: make(map[string]inMemoryEntry),
 	}
 }
 
@@ -21,8 +31,8 @@ func (m *InMemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	if value, exists := m.data[key]; exists {
-		return value, nil
+	if entry, exists := m.data[key]; exists && !entry.expired(time.Now()) {
+		return entry.value, nil
 	}
 	return nil, NotFound
 }
@@ -31,7 +41,15 @@ func (m *InMemoryCache) Set(ctx context.Context, key string, value []byte) error
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	m.data[key] = value
+	m.data[key] = inMemoryEntry{value: value}
+	return nil
+}
+
+func (m *InMemoryCache) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.data[key] = inMemoryEntry{value: value, expiresAt: time.Now().Add(ttl)}
 	return nil
 }
 
@@ -42,3 +60,11 @@ func (m *InMemoryCache) Delete(ctx context.Context, key string) error {
 	delete(m.data, key)
 	return nil
 }
+
+func (m *InMemoryCache) Clear(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	clear(m.data)
+	return nil
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisOptions defines the options for a Redis cache.
+type RedisOptions struct {
+	// Prefix is prepended to every key, which allows several caches to share
+	// one Redis database.
+	Prefix string
+
+	// TTL is the expiry applied by Set. Zero means entries do not expire.
+	TTL time.Duration
+}
+
+// Redis implements the Cache interface using Redis, which allows a cache to
+// be shared by crawlers running on several machines.
+type Redis struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedis creates a cache backed by the given Redis client.
+func NewRedis(client redis.UniversalClient, opts RedisOptions) *Redis {
+	return &Redis{
+		client: client,
+		prefix: opts.Prefix,
+		ttl:    opts.TTL,
+	}
+}
+
+func (r *Redis) key(key string) string {
+	return r.prefix + key
+}
+
+// Get returns the value stored for the key, or NotFound if there is none.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.Get(ctx, r.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Set stores the value using the configured TTL.
+func (r *Redis) Set(ctx context.Context, key string, value []byte) error {
+	return r.client.Set(ctx, r.key(key), value, r.ttl).Err()
+}
+
+// SetWithTTL stores the value with the given TTL, overriding the default.
+func (r *Redis) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, r.key(key), value, ttl).Err()
+}
+
+// Delete removes the key. Deleting a missing key is not an error.
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.key(key)).Err()
+}
+
+// Clear removes all keys with the cache's prefix. Without a prefix, every key
+// in the database is removed. With a cluster client, the keys on every master
+// node are removed.
+func (r *Redis) Clear(ctx context.Context) error {
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return r.clear(ctx, client)
+		})
+	}
+	return r.clear(ctx, r.client)
+}
+
+// clearBatchSize is the number of keys scanned and deleted at a time by Clear.
+const clearBatchSize = 1000
+
+// clear removes the keys with the cache's prefix from one node. Keys are
+// deleted individually, in pipelined batches, since a cluster does not allow
+// deleting keys from several hash slots in one command.
+func (r *Redis) clear(ctx context.Context, client redis.Cmdable) error {
+	pattern := globEscaper.Replace(r.prefix) + "*"
+	iter := client.Scan(ctx, 0, pattern, clearBatchSize).Iterator()
+	var keys []string
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Del(ctx, key)
+			}
+			return nil
+		})
+		keys = keys[:0]
+		return err
+	}
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) >= clearBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// globEscaper escapes the characters that are special in Redis key patterns.
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
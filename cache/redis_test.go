@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRedis returns a Redis cache for integration tests. The tests are
+// skipped unless REDIS_ADDR is set.
+func newTestRedis(t *testing.T, opts RedisOptions) *Redis {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	require.NoError(t, client.Ping(context.Background()).Err())
+	return NewRedis(client, opts)
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("web-test-%d:", time.Now().UnixNano())
+	c := newTestRedis(t, RedisOptions{Prefix: prefix})
+
+	_, err := c.Get(ctx, "missing")
+	require.True(t, IsNotFound(err))
+
+	require.NoError(t, c.Set(ctx, "key", []byte("value")))
+	value, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), value)
+
+	require.NoError(t, c.Delete(ctx, "key"))
+	require.NoError(t, c.Delete(ctx, "key"))
+	_, err = c.Get(ctx, "key")
+	require.True(t, IsNotFound(err))
+
+	// Clear only removes keys with the cache's prefix
+	other := NewRedis(c.client, RedisOptions{Prefix: prefix + "other*"})
+	require.NoError(t, other.Set(ctx, "key", []byte("value")))
+	t.Cleanup(func() { other.Delete(ctx, "key") })
+	for i := 0; i < 2500; i++ {
+		require.NoError(t, c.Set(ctx, fmt.Sprint("key-", i), []byte("value")))
+	}
+	require.NoError(t, other.Clear(ctx))
+	_, err = c.Get(ctx, "key-0")
+	require.NoError(t, err)
+	require.NoError(t, c.Clear(ctx))
+	_, err = c.Get(ctx, "key-2499")
+	require.True(t, IsNotFound(err))
+}
+
+func TestRedis_TTL(t *testing.T) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("web-test-%d:", time.Now().UnixNano())
+	c := newTestRedis(t, RedisOptions{Prefix: prefix})
+
+	require.NoError(t, SetWithTTL(ctx, c, "key", []byte("value"), 100*time.Millisecond))
+	_, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+
+	time.Sleep(200 * time.Millisecond)
+	_, err = c.Get(ctx, "key")
+	require.True(t, IsNotFound(err))
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// TieredCache implements the Cache interface using a fast cache, typically an
+// LRU, in front of a slow one such as Redis or the filesystem. Reads check
+// the fast cache first and fall back to the slow one, copying values found
+// there into the fast cache. Writes go to both.
+//
+// Values copied into the fast cache on read are stored with Set, so they do
+// not inherit the TTL of the slow cache entry. The fast cache should bound
+// its size, or apply its own expiry, so that it does not hold such values
+// indefinitely.
+type TieredCache struct {
+	fast Cache
+	slow Cache
+}
+
+// Tiered creates a cache that places the fast cache in front of the slow one.
+func Tiered(fast, slow Cache) *TieredCache {
+	return &TieredCache{fast: fast, slow: slow}
+}
+
+// Get returns the value stored for the key, or NotFound if neither cache has
+// it. Errors from the fast cache are ignored in favor of the slow cache.
+func (t *TieredCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, err := t.fast.Get(ctx, key); err == nil {
+		return value, nil
+	}
+	value, err := t.slow.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	// Failing to promote the value only costs a slow read next time
+	_ = t.fast.Set(ctx, key, value)
+	return value, nil
+}
+
+// Set stores the value in both caches. The slow cache is written first, and
+// the fast cache is left untouched if that fails.
+func (t *TieredCache) Set(ctx context.Context, key string, value []byte) error {
+	if err := t.slow.Set(ctx, key, value); err != nil {
+		return err
+	}
+	return t.fast.Set(ctx, key, value)
+}
+
+// SetWithTTL stores the value in both caches with the given TTL, for those
+// that support expiry.
+func (t *TieredCache) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := SetWithTTL(ctx, t.slow, key, value, ttl); err != nil {
+		return err
+	}
+	return SetWithTTL(ctx, t.fast, key, value, ttl)
+}
+
+// Delete removes the key from both caches.
+func (t *TieredCache) Delete(ctx context.Context, key string) error {
+	return errors.Join(t.fast.Delete(ctx, key), t.slow.Delete(ctx, key))
+}
+
+// Clear removes all entries from both caches.
+func (t *TieredCache) Clear(ctx context.Context) error {
+	return errors.Join(t.fast.Clear(ctx), t.slow.Clear(ctx))
+}
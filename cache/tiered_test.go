@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// failingCache fails every operation.
+type failingCache struct{}
+
+var errUnavailable = errors.New("unavailable")
+
+func (failingCache) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, errUnavailable
+}
+
+func (failingCache) Set(ctx context.Context, key string, value []byte) error {
+	return errUnavailable
+}
+
+func (failingCache) Delete(ctx context.Context, key string) error {
+	return errUnavailable
+}
+
+func (failingCache) Clear(ctx context.Context) error {
+	return errUnavailable
+}
+
+func TestTiered(t *testing.T) {
+	ctx := context.Background()
+	fast := NewInMemoryCache()
+	slow := NewInMemoryCache()
+	c := Tiered(fast, slow)
+
+	_, err := c.Get(ctx, "https://example.com")
+	require.True(t, IsNotFound(err))
+
+	// Writes go to both caches
+	require.NoError(t, c.Set(ctx, "https://example.com", []byte("hello")))
+	value, err := fast.Get(ctx, "https://example.com")
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), value)
+	value, err = slow.Get(ctx, "https://example.com")
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), value)
+
+	// Slow cache hits are promoted into the fast cache
+	require.NoError(t, slow.Set(ctx, "https://example.com/about", []byte("world")))
+	value, err = c.Get(ctx, "https://example.com/about")
+	require.NoError(t, err)
+	require.Equal(t, []byte("world"), value)
+	value, err = fast.Get(ctx, "https://example.com/about")
+	require.NoError(t, err)
+	require.Equal(t, []byte("world"), value)
+
+	// The fast cache is checked first
+	require.NoError(t, fast.Set(ctx, "https://example.com/about", []byte("fast")))
+	value, err = c.Get(ctx, "https://example.com/about")
+	require.NoError(t, err)
+	require.Equal(t, []byte("fast"), value)
+
+	require.NoError(t, c.Delete(ctx, "https://example.com"))
+	_, err = fast.Get(ctx, "https://example.com")
+	require.True(t, IsNotFound(err))
+	_, err = slow.Get(ctx, "https://example.com")
+	require.True(t, IsNotFound(err))
+
+	require.NoError(t, c.Clear(ctx))
+	_, err = fast.Get(ctx, "https://example.com/about")
+	require.True(t, IsNotFound(err))
+	_, err = slow.Get(ctx, "https://example.com/about")
+	require.True(t, IsNotFound(err))
+}
+
+func TestTiered_TTL(t *testing.T) {
+	ctx := context.Background()
+	fast := NewInMemoryCache()
+	slow := NewInMemoryCache()
+	c := Tiered(fast, slow)
+
+	require.NoError(t, SetWithTTL(ctx, c, "key", []byte("value"), 50*time.Millisecond))
+	time.Sleep(100 * time.Millisecond)
+	_, err := fast.Get(ctx, "key")
+	require.True(t, IsNotFound(err))
+	_, err = slow.Get(ctx, "key")
+	require.True(t, IsNotFound(err))
+}
+
+func TestTiered_Failures(t *testing.T) {
+	ctx := context.Background()
+
+	// A failing fast cache does not prevent reads from the slow cache
+	slow := NewInMemoryCache()
+	require.NoError(t, slow.Set(ctx, "key", []byte("value")))
+	value, err := Tiered(failingCache{}, slow).Get(ctx, "key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), value)
+
+	// A failed write to the slow cache is not applied to the fast cache
+	fast := NewInMemoryCache()
+	c := Tiered(fast, failingCache{})
+	require.ErrorIs(t, c.Set(ctx, "key", []byte("value")), errUnavailable)
+	_, err = fast.Get(ctx, "key")
+	require.True(t, IsNotFound(err))
+	require.ErrorIs(t, c.Delete(ctx, "key"), errUnavailable)
+}
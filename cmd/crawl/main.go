@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"log"
 	"log/slog"
@@ -19,6 +20,10 @@ type Config struct {
 	Timeout        time.Duration
 	URLs           string
 	FollowBehavior string
+	StatsFile      string
+	GraphFile      string
+	SitemapFile    string
+	LogURLs        bool
 }
 
 func main() {
@@ -28,6 +33,10 @@ func main() {
 	flag.DurationVar(&cfg.Timeout, "timeout", 10*time.Second, "timeout for the fetcher")
 	flag.StringVar(&cfg.URLs, "urls", "", "comma separated list of URLs to crawl")
 	flag.StringVar(&cfg.FollowBehavior, "follow-behavior", "same-domain", "follow behavior")
+	flag.StringVar(&cfg.StatsFile, "stats-file", "", "file to write crawl statistics to as JSON")
+	flag.StringVar(&cfg.GraphFile, "graph-file", "", "file to write the link graph to, as DOT if it ends in .dot and JSON otherwise")
+	flag.StringVar(&cfg.SitemapFile, "sitemap-file", "", "file to write a sitemap of the crawled pages to")
+	flag.BoolVar(&cfg.LogURLs, "log-urls", false, "log the fetch duration, size and links of each URL")
 	flag.Parse()
 
 	urls := strings.Split(cfg.URLs, ",")
@@ -51,9 +60,13 @@ func main() {
 		Logger:         logger,
 		ShowProgress:   true,
 		FollowBehavior: crawler.FollowBehavior(cfg.FollowBehavior),
+		RecordGraph:    cfg.GraphFile != "",
+		LogURLs:        cfg.LogURLs,
 	})
 
+	sitemap := crawler.NewSitemapCollector()
 	callback := func(ctx context.Context, result *crawler.Result) {
+		sitemap.Add(ctx, result)
 		if result.Error != nil {
 			logger.Error("error fetching", "url", result.URL, "error", result.Error)
 			return
@@ -64,4 +77,55 @@ func main() {
 	if err := c.Crawl(context.Background(), urls, callback); err != nil {
 		log.Fatal(err)
 	}
+
+	if cfg.StatsFile != "" {
+		data, err := json.MarshalIndent(c.GetStats(), "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(cfg.StatsFile, data, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if cfg.GraphFile != "" {
+		if err := writeGraph(cfg.GraphFile, c.Graph()); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if cfg.SitemapFile != "" {
+		if err := writeSitemap(cfg.SitemapFile, sitemap); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func writeSitemap(path string, sitemap *crawler.SitemapCollector) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := sitemap.WriteTo(file); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func writeGraph(path string, graph *crawler.LinkGraph) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if strings.HasSuffix(path, ".dot") {
+		err = graph.WriteDOT(file)
+	} else {
+		err = json.NewEncoder(file).Encode(graph)
+	}
+	if err != nil {
+		return err
+	}
+	return file.Close()
 }
@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultAdaptiveTargetLatency is the fetch latency above which a host is
+// treated as overloaded when Options.AdaptiveTargetLatency is not set.
+const DefaultAdaptiveTargetLatency = 2 * time.Second
+
+// adaptiveLimiter limits the number of concurrent requests to each host, and
+// adjusts each host's limit using additive increase, multiplicative decrease
+// (AIMD), the scheme TCP uses for congestion control:
+//
+//   - A successful fetch that is faster than the target latency raises the
+//     limit by 1/limit, so the limit grows by one for each limit's worth of
+//     successful fetches.
+//   - A fetch that fails with a retriable error, such as a 429 or 5xx status
+//     or a network error, or that is slower than the target latency, halves
+//     the limit. Fetches that started before the last decrease do not cause
+//     another, so a burst of failures from one round of requests halves the
+//     limit only once.
+//   - Other errors and cancelled fetches leave the limit unchanged.
+//
+// Limits are kept between the minimum and maximum, and start at the minimum.
+type adaptiveLimiter struct {
+	mutex         sync.Mutex
+	min           int
+	max           int
+	targetLatency time.Duration
+	hosts         map[string]*hostConcurrency
+}
+
+// hostConcurrency is the adaptive state for one host.
+type hostConcurrency struct {
+	limit        float64
+	active       int
+	lastDecrease time.Time
+	wake         chan struct{}
+}
+
+func newAdaptiveLimiter(minLimit, maxLimit int, targetLatency time.Duration) *adaptiveLimiter {
+	return &adaptiveLimiter{
+		min:           minLimit,
+		max:           maxLimit,
+		targetLatency: targetLatency,
+		hosts:         make(map[string]*hostConcurrency),
+	}
+}
+
+// host returns the state for the host, creating it if needed. The caller must
+// hold the mutex.
+func (l *adaptiveLimiter) host(host string) *hostConcurrency {
+	h, exists := l.hosts[host]
+	if !exists {
+		h = &hostConcurrency{limit: float64(l.min), wake: make(chan struct{})}
+		l.hosts[host] = h
+	}
+	return h
+}
+
+// Acquire blocks until a request to the host may start or the context is
+// cancelled. Each successful Acquire must be followed by a Release.
+func (l *adaptiveLimiter) Acquire(ctx context.Context, host string) error {
+	for {
+		l.mutex.Lock()
+		h := l.host(host)
+		if h.active < int(h.limit) {
+			h.active++
+			l.mutex.Unlock()
+			return nil
+		}
+		wake := h.wake
+		l.mutex.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release ends a request to the host that started at the given time, adjusts
+// the host's limit from the outcome, and returns the new limit.
+func (l *adaptiveLimiter) Release(host string, started time.Time, latency time.Duration, err error) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	h := l.host(host)
+	h.active--
+	switch {
+	case errors.Is(err, context.Canceled):
+	case isRetriable(err) || latency > l.targetLatency:
+		if started.After(h.lastDecrease) {
+			h.limit = max(float64(l.min), h.limit/2)
+			h.lastDecrease = time.Now()
+		}
+	case err == nil:
+		h.limit = min(float64(l.max), h.limit+1/h.limit)
+	}
+	// Wake waiting requests, which check for a free slot again
+	close(h.wake)
+	h.wake = make(chan struct{})
+	return int(h.limit)
+}
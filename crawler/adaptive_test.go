@@ -0,0 +1,106 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	weberrors "github.com/myzie/web/errors"
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	limiter := newAdaptiveLimiter(1, 4, 100*time.Millisecond)
+	ctx := context.Background()
+	host := "example.com"
+
+	// Only the minimum number of requests may start at first
+	require.NoError(t, limiter.Acquire(ctx, host))
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, limiter.Acquire(timeoutCtx, host), context.DeadlineExceeded)
+
+	// Fast successes increase the limit additively, up to the maximum
+	var limits []int
+	for i := 0; i < 12; i++ {
+		if i > 0 {
+			require.NoError(t, limiter.Acquire(ctx, host))
+		}
+		limits = append(limits, limiter.Release(host, time.Now(), time.Millisecond, nil))
+	}
+	assert.Equal(t, []int{2, 2, 2, 3, 3, 3, 4, 4, 4, 4, 4, 4}, limits)
+
+	// A failure halves the limit, but failures of requests that started
+	// before the decrease do not cut it again
+	started := time.Now()
+	serverErr := weberrors.NewRequestError(fmt.Errorf("unavailable")).
+		WithStatusCode(http.StatusServiceUnavailable)
+	require.NoError(t, limiter.Acquire(ctx, host))
+	require.NoError(t, limiter.Acquire(ctx, host))
+	assert.Equal(t, 2, limiter.Release(host, started, time.Millisecond, serverErr))
+	assert.Equal(t, 2, limiter.Release(host, started, time.Millisecond, serverErr))
+
+	// Slow responses count as failures, while other errors and cancelled
+	// requests leave the limit alone
+	require.NoError(t, limiter.Acquire(ctx, host))
+	assert.Equal(t, 1, limiter.Release(host, time.Now(), time.Second, nil))
+	require.NoError(t, limiter.Acquire(ctx, host))
+	notFound := weberrors.NewRequestError(fmt.Errorf("not found")).
+		WithStatusCode(http.StatusNotFound)
+	assert.Equal(t, 1, limiter.Release(host, time.Now(), time.Millisecond, notFound))
+	require.NoError(t, limiter.Acquire(ctx, host))
+	assert.Equal(t, 1, limiter.Release(host, time.Now(), time.Millisecond, context.Canceled))
+}
+
+func TestAdaptiveLimiter_Wake(t *testing.T) {
+	limiter := newAdaptiveLimiter(1, 1, time.Second)
+	require.NoError(t, limiter.Acquire(context.Background(), "example.com"))
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- limiter.Acquire(context.Background(), "example.com")
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired a slot beyond the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Other hosts are limited independently
+	require.NoError(t, limiter.Acquire(context.Background(), "other.com"))
+
+	limiter.Release("example.com", time.Now(), time.Millisecond, nil)
+	require.NoError(t, <-acquired)
+}
+
+func TestCrawler_Adaptive(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	var links []string
+	for i := 0; i < 10; i++ {
+		links = append(links, fmt.Sprintf("/%d", i))
+		mockFetcher.AddPage(fmt.Sprintf("https://example.com/%d", i))
+	}
+	mockFetcher.AddPage("https://example.com", links...)
+
+	crawler := New(Options{
+		Workers:        4,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		Adaptive:       true,
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			assert.NoError(t, result.Error)
+		})
+	require.NoError(t, err)
+
+	// The limit rises to the number of workers as fetches succeed
+	stats := crawler.GetStats().ByDomain()["example.com"]
+	assert.Equal(t, int64(11), stats.Processed)
+	assert.Equal(t, int64(4), stats.Concurrency)
+	assert.Contains(t, stats.String(), "concurrency=4")
+}
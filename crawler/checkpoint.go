@@ -0,0 +1,178 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultCheckpointInterval is the time between checkpoints when
+// Options.CheckpointPath is set but Options.CheckpointInterval is not.
+const DefaultCheckpointInterval = time.Minute
+
+const checkpointVersion = 1
+
+// Checkpoint is the saved state of a crawl, from which it may be resumed.
+type Checkpoint struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	Pending   []FrontierEntry `json:"pending"`
+	Processed []string        `json:"processed"`
+	Stats     StatsSnapshot   `json:"stats"`
+}
+
+// LoadCheckpoint reads a checkpoint written by a crawler.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	if checkpoint.Version != checkpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %d", checkpoint.Version)
+	}
+	return &checkpoint, nil
+}
+
+// Resume continues the crawl saved in the checkpoint at the given path using a
+// new crawler created with the options. Unless Options.CheckpointPath is set,
+// further checkpoints are written back to the same path.
+func Resume(ctx context.Context, path string, opts Options, callback Callback) error {
+	checkpoint, err := LoadCheckpoint(path)
+	if err != nil {
+		return err
+	}
+	if opts.CheckpointPath == "" {
+		opts.CheckpointPath = path
+	}
+	// Make room in the default frontier for everything that was pending
+	if opts.Frontier == nil && len(checkpoint.Pending) > max(opts.QueueSize, DefaultQueueSize) {
+		opts.QueueSize = len(checkpoint.Pending)
+	}
+	return New(opts).resume(ctx, path, checkpoint, callback)
+}
+
+// Resume continues the crawl saved in the checkpoint at the given path. URLs
+// that were pending or in progress when the checkpoint was written are queued
+// again, and URLs that were already seen are not revisited.
+func (c *Crawler) Resume(ctx context.Context, path string, callback Callback) error {
+	checkpoint, err := LoadCheckpoint(path)
+	if err != nil {
+		return err
+	}
+	return c.resume(ctx, path, checkpoint, callback)
+}
+
+func (c *Crawler) resume(ctx context.Context, path string, checkpoint *Checkpoint, callback Callback) error {
+	stop, err := c.start()
+	if err != nil {
+		return err
+	}
+	defer c.finish()
+
+	c.stats.restore(checkpoint.Stats)
+	for _, rawURL := range checkpoint.Processed {
+		c.processedURLs.Add(rawURL)
+		if u, err := url.Parse(rawURL); err == nil {
+			c.reserveDomain(u.Host)
+		}
+	}
+	for _, entry := range checkpoint.Pending {
+		c.processedURLs.Add(entry.URL)
+		c.push(entry)
+	}
+	c.logger.Info("resuming crawl",
+		slog.String("checkpoint", path),
+		slog.Int("pending", len(checkpoint.Pending)),
+		slog.Int("processed", len(checkpoint.Processed)))
+	return c.run(ctx, stop, nil, callback)
+}
+
+// checkpoint returns the current state of the crawl. While the crawl is
+// running the state is best effort, since workers may move URLs between the
+// frontier and the in-flight set as it is collected.
+func (c *Crawler) checkpoint() *Checkpoint {
+	checkpoint := &Checkpoint{
+		Version:   checkpointVersion,
+		CreatedAt: time.Now().UTC(),
+		Processed: []string{},
+		Pending:   []FrontierEntry{},
+	}
+	if snapshotter, ok := c.frontier.(FrontierSnapshotter); ok {
+		checkpoint.Pending = append(checkpoint.Pending, snapshotter.Snapshot()...)
+	}
+	c.inFlight.Range(func(key, value any) bool {
+		checkpoint.Pending = append(checkpoint.Pending, value.(FrontierEntry))
+		return true
+	})
+	checkpoint.Processed = append(checkpoint.Processed, c.processedURLs.URLs()...)
+	sort.Strings(checkpoint.Processed)
+	checkpoint.Stats = c.stats.Snapshot()
+	return checkpoint
+}
+
+// writeCheckpoint saves the current state of the crawl to the path. The file
+// is written to a temporary file and renamed into place, so an interrupted
+// write leaves any previous checkpoint intact.
+func (c *Crawler) writeCheckpoint(path string) error {
+	data, err := json.Marshal(c.checkpoint())
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// saveCheckpoint writes a checkpoint to the configured path, logging any
+// failure.
+func (c *Crawler) saveCheckpoint() {
+	if err := c.writeCheckpoint(c.checkpointPath); err != nil {
+		c.logger.Error("failed to write checkpoint",
+			slog.String("path", c.checkpointPath),
+			slog.String("error", err.Error()))
+		return
+	}
+	c.logger.Debug("wrote checkpoint", slog.String("path", c.checkpointPath))
+}
+
+// checkpointer periodically saves a checkpoint until the context is done.
+func (c *Crawler) checkpointer(ctx context.Context) {
+	ticker := time.NewTicker(c.checkpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.saveCheckpoint()
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package crawler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrawler_CheckpointAndResume(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+
+	// A chain of pages: / -> /1 -> /2
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL:   "https://example.com",
+		Links: []*fetch.Link{{URL: "/1"}},
+	})
+	mockFetcher.AddResponse("https://example.com/1", &fetch.Response{
+		URL:   "https://example.com/1",
+		Links: []*fetch.Link{{URL: "/2"}},
+	})
+	mockFetcher.AddResponse("https://example.com/2", &fetch.Response{
+		URL:   "https://example.com/2",
+		Links: []*fetch.Link{{URL: "/"}},
+	})
+
+	path := filepath.Join(t.TempDir(), "crawl", "checkpoint.json")
+	opts := Options{
+		MaxURLs:        10,
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		CheckpointPath: path,
+	}
+
+	// Stop after the first page so that its link is left pending
+	crawler := New(opts)
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			crawler.Stop()
+		})
+	require.NoError(t, err)
+
+	checkpoint, err := LoadCheckpoint(path)
+	require.NoError(t, err)
+	assert.Equal(t, []FrontierEntry{
+		{URL: "https://example.com/1", Depth: 1, Parent: "https://example.com"},
+	}, checkpoint.Pending)
+	assert.Equal(t, []string{"https://example.com", "https://example.com/1"}, checkpoint.Processed)
+	assert.Equal(t, int64(1), checkpoint.Stats.Processed)
+
+	// Resuming visits only the remaining pages
+	depths := map[string]int{}
+	mu := sync.Mutex{}
+	err = Resume(context.Background(), path, opts, func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		depths[result.URL.String()] = result.Depth
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"https://example.com/1": 1,
+		"https://example.com/2": 2,
+	}, depths)
+
+	checkpoint, err = LoadCheckpoint(path)
+	require.NoError(t, err)
+	assert.Empty(t, checkpoint.Pending)
+	assert.Len(t, checkpoint.Processed, 3)
+	assert.Equal(t, int64(3), checkpoint.Stats.Processed)
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
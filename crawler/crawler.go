@@ -2,10 +2,16 @@ package crawler
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"net/url"
+	"path"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -26,19 +32,106 @@ const (
 	FollowNone              FollowBehavior = "none"
 )
 
+// Strategy determines the order in which URLs are crawled.
+type Strategy string
+
+const (
+	// StrategyFIFO crawls URLs in the order they are discovered. With more
+	// than one worker, a URL may be crawled before shallower URLs that are
+	// still in progress.
+	StrategyFIFO Strategy = "fifo"
+
+	// StrategyBFS crawls breadth-first, processing every URL at one depth
+	// before any at the next.
+	StrategyBFS Strategy = "bfs"
+)
+
+// ErrContentTypeNotAllowed is reported on a Result when the response content
+// type is not one of Options.AllowedContentTypes.
+var ErrContentTypeNotAllowed = errors.New("content type not allowed")
+
+// ErrRedirectNotFollowed is reported on a Result when the page redirected to
+// a URL that would not be followed as a link from the page, because it is
+// outside Options.FollowBehavior or rejected by the link filters such as
+// Options.ExcludePatterns and Options.PathPrefixes.
+var ErrRedirectNotFollowed = errors.New("redirect not followed")
+
+// ErrAlreadyRunning is returned when a crawl is started while the Crawler is
+// already crawling.
+var ErrAlreadyRunning = errors.New("crawler is already running")
+
+// ErrNotRunning is returned by AddURLs when no crawl is in progress.
+var ErrNotRunning = errors.New("crawler is not running")
+
+// ErrURLDropped is returned when Options.RewriteURL drops a URL.
+var ErrURLDropped = errors.New("url dropped by rewrite")
+
+// DefaultQueueSize is the capacity of the default frontier when
+// Options.QueueSize is not set.
+const DefaultQueueSize = 10000
+
+const (
+	// idleCheckInterval is how often the idle monitor checks for work.
+	idleCheckInterval = 250 * time.Millisecond
+
+	// idleCheckCount is the number of consecutive idle checks after which
+	// the crawl is considered done.
+	idleCheckCount = 2
+)
+
 // Parser is an interface describing a webpage parser. It accepts the fetched
 // page and returns a parsed object.
 type Parser interface {
 	Parse(ctx context.Context, page *fetch.Response) (any, error)
 }
 
-// Result represents the result of one page being crawled.
+// Result represents the result of one page being crawled. Depth is the number
+// of links followed from a seed URL and ParentURL is the page the URL was
+// discovered on. Seeds have a depth of 0 and a nil ParentURL. When content
+// deduplication is enabled, Duplicate indicates the page content was already
+// seen at the URL given by DuplicateOf. When canonical URLs are respected,
+// Canonical is the canonical URL declared by the page, and pages declaring a
+// different canonical URL are marked Duplicate of it. NoIndex indicates the
+// page's robots meta tag asked for it not to be indexed. StatusCode is the
+// HTTP status of the response, if one was received. Responses with an error
+// status are reported with an Error and are neither cached nor parsed. When
+// the request was redirected, FinalURL is the URL the page was fetched from
+// and Redirects lists the URLs that redirected, in order. A page that
+// redirects to a URL that was already queued is marked Duplicate of it.
+// Links holds the distinct links found on the page, resolved and normalized,
+// and LinkDetails describes each link as it appeared on the page.
 type Result struct {
-	URL      *url.URL
-	Parsed   any
-	Links    []string
-	Response *fetch.Response
-	Error    error
+	URL         *url.URL
+	StatusCode  int
+	Parsed      any
+	Links       []string
+	Response    *fetch.Response
+	Error       error
+	Depth       int
+	ParentURL   *url.URL
+	Duplicate   bool
+	DuplicateOf *url.URL
+	Canonical   *url.URL
+	NoIndex     bool
+	FinalURL    *url.URL
+	Redirects   []string
+	LinkDetails []LinkDetail
+}
+
+// LinkDetail describes a link found on a page, for auditing why a link was or
+// wasn't crawled. Raw is the link as it appeared on the page and Resolved is
+// the absolute, normalized URL it resolved to, which is empty if the link is
+// invalid or not an http or https URL. Followed reports whether the link was
+// accepted for crawling from the page, having passed the follow behavior and
+// link filters. Links that had already been queued are reported as followed
+// without being checked again, and followed links may still be dropped by
+// BlockedDomains or the MaxURLs and per-domain limits. Links marked
+// rel="nofollow" when RespectNofollow is set, and the links on pages at
+// MaxDepth, are not followed.
+type LinkDetail struct {
+	Raw      string `json:"raw"`
+	Resolved string `json:"resolved,omitempty"`
+	Followed bool   `json:"followed"`
 }
 
 // ProcessCallback is called with the fetch request and parsed result (if any)
@@ -60,28 +153,476 @@ type Options struct {
 	ShowProgress         bool
 	ShowProgressInterval time.Duration
 	QueueSize            int
+
+	// Normalize controls how seed and discovered URLs are normalized before
+	// they are deduplicated. By default query parameters are removed. Set
+	// KeepQuery to treat pages such as "?page=2" as distinct while still
+	// removing tracking parameters like utm_source. TrimTrailingSlash is
+	// always enabled, so that "/docs/" and "/docs" are crawled once.
+	Normalize web.NormalizeOptions
+
+	// IgnoreWWW treats a host with a leading "www." and its bare form as the
+	// same host when deciding whether to follow links and redirects, so that
+	// FollowSameDomain crawls both www.example.com and example.com. The two
+	// are still crawled as separate URLs unless Normalize.StripWWW is also
+	// set, which queues them in their bare form. Leave both unset for sites
+	// that serve different content on the two hosts.
+	IgnoreWWW bool
+
+	// Normalizer replaces the built-in normalization of seed and discovered
+	// URLs, and Normalize is then ignored, for sites where the default
+	// rules break deduplication, such as those with case-sensitive paths or
+	// significant query parameter order. Links are resolved to absolute URLs
+	// before they are passed to it, and it must return an absolute URL. It
+	// must be deterministic and idempotent, so that a URL normalizes to the
+	// same string however it is discovered and however often it is
+	// normalized, or pages may be crawled more than once. Returning an error
+	// drops the URL.
+	Normalizer func(raw string) (string, error)
+
+	// RewriteURL, when set, rewrites each URL after it is normalized, for
+	// example to strip an AMP suffix or to swap a staging host for the
+	// production one. It applies wherever URLs are normalized: to seeds,
+	// links, redirect targets and canonical URLs. The rewritten URL is the
+	// one that is filtered, deduplicated, fetched and reported in
+	// Result.Links, so the follow behavior and link filters judge the
+	// rewritten form. It may modify and return the given URL, and returning
+	// nil drops the URL. Like Normalizer, it must be deterministic and
+	// idempotent. It may be called from many goroutines at once.
+	RewriteURL func(u *url.URL) *url.URL
+
+	// ApproxDedup tracks the URLs that have been seen with a scalable bloom
+	// filter instead of storing every URL, which greatly reduces memory use
+	// on very large crawls. In exchange, a small fraction of URLs are wrongly
+	// treated as already seen and are never crawled, URLs dropped because the
+	// frontier was full are not queued again if rediscovered, and checkpoints
+	// do not list the processed URLs, so a resumed crawl may revisit pages.
+	ApproxDedup bool
+
+	// ApproxDedupItems is the number of URLs the bloom filter is initially
+	// sized for. It grows beyond this as needed. Defaults to
+	// DefaultApproxDedupItems.
+	ApproxDedupItems int
+
+	// ApproxDedupErrorRate is the target fraction of new URLs wrongly treated
+	// as already seen. Defaults to DefaultApproxDedupErrorRate.
+	ApproxDedupErrorRate float64
+
+	// MaxURLsPerDomain limits the number of URLs crawled on each host,
+	// including seeds, so that one large site does not crowd out the others.
+	// Zero means no limit.
+	MaxURLsPerDomain int
+
+	// Deterministic crawls URLs in a stable order so that repeated crawls of
+	// the same content visit pages in the same sequence. A single worker is
+	// used regardless of Workers, and pages are visited breadth-first: seeds
+	// in the order given, then the links of each page sorted by URL. With a
+	// custom Frontier, the order is that of the Frontier.
+	Deterministic bool
+
+	// Frontier holds the URLs waiting to be crawled. Defaults to a
+	// ChannelFrontier holding up to QueueSize entries, a BFSFrontier with
+	// StrategyBFS, or a PriorityFrontier when Priority is set. Use a
+	// DiskFrontier when more URLs may be pending than fit in memory.
+	Frontier Frontier
+
+	// Strategy determines the crawl order of the default frontier. Defaults
+	// to StrategyFIFO. Ignored when a Frontier or Priority is given.
+	Strategy Strategy
+
+	// Priority scores each URL so that the most promising are crawled first,
+	// for example to favor shorter paths or URLs matching a topic. URLs with
+	// equal scores are crawled in the order they were found. Ignored when a
+	// Frontier is given.
+	Priority PriorityFunc
+
+	// ExcludeExtensions prevents links whose path ends with one of the file
+	// extensions, such as ".pdf" or ".zip", from being followed, so that they
+	// are never fetched. Matching ignores case and the query string.
+	ExcludeExtensions []string
+
+	// PathPrefixes restricts the links followed on each host, keyed by
+	// hostname, to those whose path begins with one of the prefixes. Use a
+	// trailing slash, as in "/docs/", to match only within a directory; the
+	// directory itself also matches. Hosts without an entry are unrestricted.
+	// Seed URLs are crawled regardless. This applies in addition to
+	// FollowBehavior.
+	PathPrefixes map[string][]string
+
+	// AllowedDomains lists additional domains whose links are followed
+	// whatever the FollowBehavior, for sites split across several domains,
+	// such as a site and its CDN. Links to a listed domain or any of its
+	// subdomains are followed. Entries may be hostnames or URLs and are
+	// normalized like links, so internationalized names match their ASCII
+	// form. FollowNone still follows no links.
+	AllowedDomains []string
+
+	// BlockedDomains lists domains that are never crawled, such as ad
+	// networks and analytics hosts. URLs on a listed domain or any of its
+	// subdomains are dropped before they are queued, whatever the
+	// FollowBehavior or AllowedDomains, and counted in the blocked statistic.
+	// An entry of the form "*.example.com" blocks only the subdomains of
+	// example.com. Entries are normalized like links.
+	BlockedDomains []string
+
+	// ShouldFollow, when set, decides whether a link is followed, which lets
+	// a crawl prune branches once it has found what it needs. It is called
+	// with the URL of the page the link was found on, after any redirects,
+	// and the normalized link. It is only consulted for links that pass the
+	// built-in filters: FollowBehavior, AllowedDomains, PathPrefixes,
+	// ExcludeExtensions, IncludePatterns, ExcludePatterns and trap detection.
+	// Links it accepts may still be dropped as duplicates, by BlockedDomains
+	// or by the MaxURLs and per-domain limits. It may be called from many
+	// goroutines at once.
+	ShouldFollow func(ctx context.Context, from *url.URL, link string) bool
+
+	// MaxURLsPerPath limits the number of distinct URLs followed for each
+	// host and path, which only differ by their query when KeepQuery is set.
+	// This stops crawls from following calendars and filters that generate
+	// endless query variations. Set it above the number of pages in any
+	// paginated section that should be crawled fully. Zero means no limit.
+	MaxURLsPerPath int
+
+	// MaxPathSegmentRepeats drops links whose path repeats a segment more
+	// than this many times, such as "/a/b/a/b/a", which often results from
+	// relative links resolving against their own page. Zero means no limit.
+	MaxPathSegmentRepeats int
+
+	// MaxDuration limits how long the crawl runs. When it elapses, no new
+	// URLs are started and Crawl returns once in-flight URLs finish, with
+	// the stop reason set to ReasonTimeout. Zero means no limit.
+	MaxDuration time.Duration
+
+	// StayAlive keeps the crawl running when it runs out of work, for
+	// services that keep adding seeds with AddURLs. Crawl then returns only
+	// when the context is cancelled, Stop is called or MaxDuration elapses,
+	// and the workers and progress reporter wait for more URLs in the
+	// meantime. MaxURLs is still honored: URLs beyond the limit are not
+	// queued, and once the limit is reached the crawl ends when it is next
+	// idle, as no further URLs could be crawled.
+	StayAlive bool
+
+	// MaxDepth limits how many links away from the seed URLs the crawl may
+	// go. Seeds are at depth 0. Zero means no limit.
+	MaxDepth int
+
+	// IncludePatterns restricts which discovered links are followed. When any
+	// are set, a link must match at least one of them.
+	IncludePatterns []*regexp.Regexp
+
+	// ExcludePatterns prevents links matching any of the patterns from being
+	// followed. Exclusion takes precedence over inclusion and also applies to
+	// the seed URLs passed to Crawl, which are not subject to IncludePatterns.
+	// Seed URLs are matched as given, while discovered links are matched in
+	// their normalized form.
+	ExcludePatterns []*regexp.Regexp
+
+	// CacheTTL is how long fetched pages remain in the cache. It is only
+	// honored by caches implementing cache.TTLCache. Zero means no expiry.
+	CacheTTL time.Duration
+
+	// CacheErrors stores a marker in the cache for URLs that fail with a
+	// terminal client error status, such as 404 or 410, so that later
+	// crawls skip fetching them. The callback still receives a Result with
+	// the error for these URLs. Requires a Cache.
+	CacheErrors bool
+
+	// CacheErrorTTL is how long the markers stored by CacheErrors remain in
+	// the cache, allowing dead URLs to be checked again eventually. Each
+	// marker records its expiry, which is checked when it is read, so this
+	// holds for caches that do not implement cache.TTLCache too. Those keep
+	// expired markers until they are overwritten. Defaults to
+	// DefaultCacheErrorTTL.
+	CacheErrorTTL time.Duration
+
+	// RespectNofollow prevents links marked rel="nofollow" from being followed.
+	RespectNofollow bool
+
+	// DeduplicateContent skips parsing and link following for pages whose
+	// content is identical to a page that was already crawled. The callback
+	// still receives a Result for these pages with Duplicate set.
+	DeduplicateContent bool
+
+	// RespectMetaRobots honors the page's <meta name="robots"> tag. With
+	// nofollow, none of the page's links are followed. With noindex, the
+	// parser is not run and the Result is marked NoIndex. Pages disallowed
+	// by robots.txt are never fetched, so their meta tags are not seen.
+	// RespectNofollow applies to individual links in addition to this.
+	RespectMetaRobots bool
+
+	// RespectCanonical collapses pages that declare a different
+	// <link rel="canonical"> URL onto that URL. The canonical URL is queued
+	// in place of the page's links and the page is reported as a Duplicate of
+	// it without being parsed.
+	RespectCanonical bool
+
+	// RequestTimeout limits the time each fetch attempt may take, so that a
+	// hung connection does not tie up a worker. A timed out attempt fails
+	// with context.DeadlineExceeded and is retried like a network error.
+	// Zero means no limit beyond that of the Fetcher.
+	RequestTimeout time.Duration
+
+	// MaxRetries is the number of times a failed fetch is retried. Network
+	// errors, 429 responses, and 5xx responses are retried, while other
+	// failures are not.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry, which doubles with
+	// each subsequent attempt and has random jitter added. Defaults to
+	// DefaultRetryBackoff.
+	RetryBackoff time.Duration
+
+	// MaxRetryAfter caps the delay honored from a Retry-After header on 429
+	// and 503 responses. Defaults to DefaultMaxRetryAfter.
+	MaxRetryAfter time.Duration
+
+	// MaxRateLimitRetries is the number of times a URL is requeued after a
+	// 429 or 503 response with a Retry-After header. Defaults to
+	// DefaultMaxRateLimitRetries.
+	MaxRateLimitRetries int
+
+	// PerHostDelay is the minimum time between the start of requests to the
+	// same host. Each host is limited independently, so many hosts may still
+	// be crawled concurrently. This is applied in addition to RequestDelay.
+	PerHostDelay time.Duration
+
+	// RequestDelayJitter randomizes the pause after each request, which is
+	// chosen uniformly from [RequestDelay, RequestDelay+RequestDelayJitter],
+	// so that requests do not arrive at a fixed cadence. The same jitter is
+	// added to the spacing between requests to a host when PerHostDelay or a
+	// robots.txt Crawl-delay applies.
+	RequestDelayJitter time.Duration
+
+	// GlobalRPS limits the rate of requests across all hosts, in requests
+	// per second, for crawls with a total request budget. Requests wait for
+	// both this limit and the host's PerHostDelay, so the more restrictive of
+	// the two applies. Zero means no limit.
+	GlobalRPS float64
+
+	// Adaptive limits the number of concurrent requests to each host, raising
+	// the limit while the host responds quickly and cutting it when the host
+	// slows down or returns errors such as 429 or 5xx statuses. The limit for
+	// each host is reported in DomainStats.Concurrency.
+	Adaptive bool
+
+	// AdaptiveMinConcurrency is the lowest per-host limit, which each host
+	// starts at, when Adaptive is set. Defaults to 1.
+	AdaptiveMinConcurrency int
+
+	// AdaptiveMaxConcurrency is the highest per-host limit when Adaptive is
+	// set. Defaults to Workers.
+	AdaptiveMaxConcurrency int
+
+	// AdaptiveTargetLatency is the fetch latency above which a host is treated
+	// as overloaded when Adaptive is set. Defaults to
+	// DefaultAdaptiveTargetLatency.
+	AdaptiveTargetLatency time.Duration
+
+	// RespectRobots causes robots.txt to be fetched for each host on first
+	// contact. URLs it disallows for UserAgent are skipped and reported with
+	// ErrRobotsDisallowed. While a host's robots.txt fails to load with a
+	// network error or server error status, its URLs are skipped and
+	// reported with ErrRobotsUnavailable. Skipped URLs are counted in
+	// RobotsDenied rather than Processed. Crawl-delay directives are also
+	// honored.
+	RespectRobots bool
+
+	// UserAgent identifies the crawler when matching robots.txt groups. When
+	// set it is also sent as the User-Agent header on fetch requests.
+	UserAgent string
+
+	// AllowedContentTypes lists the content types that are parsed and have
+	// their links followed. Other responses are reported with
+	// ErrContentTypeNotAllowed. A type such as "text/*" matches any subtype,
+	// and responses without a content type are allowed. Defaults to
+	// fetch.DefaultAllowedContentTypes. The HTTP fetcher only downloads the
+	// bodies of its own AllowedContentTypes, which should be set to match.
+	AllowedContentTypes []string
+
+	// RevalidateCache causes cached pages with an ETag or Last-Modified
+	// header to be requested again with If-None-Match or If-Modified-Since.
+	// The cached page is used if the server responds 304 Not Modified.
+	// Otherwise cached pages are used without contacting the server.
+	RevalidateCache bool
+
+	// MaxResponseBytes limits the size of response bodies. Larger responses
+	// fail with fetch.ErrResponseTooLarge, or are truncated when
+	// TruncateLargeResponses is set. Defaults to the fetcher's own limit.
+	MaxResponseBytes int64
+
+	// TruncateLargeResponses keeps the first MaxResponseBytes of oversized
+	// responses rather than failing them.
+	TruncateLargeResponses bool
+
+	// PreflightHead sends a HEAD request before fetching each URL and skips
+	// the fetch when the response's Content-Type is not allowed or its
+	// Content-Length exceeds MaxResponseBytes, saving the download. If the
+	// HEAD request fails or returns an error status, for example because the
+	// server or fetcher does not support HEAD, the URL is fetched as usual.
+	// Pages served from the cache are not checked.
+	PreflightHead bool
+
+	// Fetchers are additional fetchers, keyed by name, that FetcherRules may
+	// choose for particular URLs, for example a headless browser for sites
+	// that render their content with JavaScript.
+	Fetchers map[string]fetch.Fetcher
+
+	// FetcherRules choose the fetcher for each URL from Fetchers. The first
+	// matching rule applies, and Fetcher is used when none match.
+	FetcherRules []FetcherRule
+
+	// ParserRules choose the parser for each URL. The first matching rule
+	// applies. When none match, the parser for the URL's host in Parsers is
+	// used, and then DefaultParser.
+	ParserRules []ParserRule
+
+	// FetchOnly fetches and caches pages without parsing them, for example to
+	// warm the Cache ahead of a later crawl that does the parsing. Links are
+	// still followed, so the whole site is fetched. The callback receives
+	// minimal Results, without Parsed, Response or LinkDetails, and may
+	// be nil.
+	FetchOnly bool
+
+	// DefaultHeaders are sent with every fetch request, for example to
+	// provide an Authorization header.
+	DefaultHeaders map[string]string
+
+	// HostHeaders are sent with fetch requests to the given hosts, keyed by
+	// hostname, and override DefaultHeaders. UserAgent, when set, overrides
+	// any User-Agent header given here.
+	HostHeaders map[string]map[string]string
+
+	// HTTPClient is used for auxiliary requests such as fetching robots.txt
+	// and sitemaps. Defaults to fetch.DefaultHTTPClient.
+	HTTPClient *http.Client
+
+	// SeedSitemaps lists sitemap URLs that are loaded when a crawl starts.
+	// The page URLs they contain are added to the seed URLs.
+	SeedSitemaps []string
+
+	// OnEnqueue, when set, is called each time a newly discovered URL is
+	// queued, including seeds. Like the other hooks it may be called from
+	// many goroutines at once and should return quickly.
+	OnEnqueue func(url string, depth int)
+
+	// OnFetchStart, when set, is called before a URL is fetched. It is not
+	// called for pages served from the cache.
+	OnFetchStart func(url string)
+
+	// OnFetchDone, when set, is called after a URL is fetched with the
+	// response, if any, the error, if any, and the time taken including
+	// retries.
+	OnFetchDone func(url string, resp *fetch.Response, err error, dur time.Duration)
+
+	// LogURLs logs a summary of each URL processed at debug level, with the
+	// fetch duration, response size, status code, and the number of links
+	// discovered and queued. It is off by default so that debug logs from
+	// large crawls are not flooded.
+	LogURLs bool
+
+	// RecordGraph records the links found on each crawled page, whether or
+	// not they are followed, in a LinkGraph available from Graph. Link URLs
+	// are normalized like the URLs that are crawled.
+	RecordGraph bool
+
+	// CheckpointPath is the file the crawl state is periodically saved to, so
+	// that an interrupted crawl may be continued with Resume. A final
+	// checkpoint is written when the crawl stops. Disabled when empty.
+	CheckpointPath string
+
+	// CheckpointInterval is the time between checkpoints. Defaults to
+	// DefaultCheckpointInterval.
+	CheckpointInterval time.Duration
 }
 
 // Crawler is used to crawl the web.
 type Crawler struct {
-	processedURLs        sync.Map
-	queue                chan string
+	processedURLs        urlSet
+	frontier             Frontier
+	newFrontier          func() Frontier
+	deterministic        bool
 	maxURLs              int
+	maxURLsPerDomain     int
+	maxDuration          time.Duration
+	stayAlive            bool
+	maxURLsPerPath       int
+	maxSegmentRepeats    int
+	pathVariants         sync.Map
+	normalize            web.NormalizeOptions
+	normalizer           func(string) (string, error)
+	rewriteURL           func(*url.URL) *url.URL
+	ignoreWWW            bool
+	domainCounts         sync.Map
+	maxDepth             int
 	workers              int
+	maxRetries           int
+	requestTimeout       time.Duration
+	retryBackoff         time.Duration
+	maxRetryAfter        time.Duration
+	maxRateLimitRetries  int
 	requestDelay         time.Duration
+	delayJitter          time.Duration
 	cache                cache.Cache
+	cacheTTL             time.Duration
+	cacheErrors          bool
+	cacheErrorTTL        time.Duration
 	fetcher              fetch.Fetcher
 	fetcherName          string
+	fetchers             map[string]fetch.Fetcher
+	fetcherRules         []FetcherRule
 	knownURLs            []string
 	parsers              map[string]Parser
+	parserRules          []ParserRule
 	defaultParser        Parser
+	fetchOnly            bool
 	followBehavior       FollowBehavior
-	activeWorkers        int64
+	includePatterns      []*regexp.Regexp
+	excludePatterns      []*regexp.Regexp
+	pathPrefixes         map[string][]string
+	allowedDomains       []string
+	blockedDomains       []string
+	shouldFollow         func(ctx context.Context, from *url.URL, link string) bool
+	onEnqueue            func(url string, depth int)
+	onFetchStart         func(url string)
+	onFetchDone          func(url string, resp *fetch.Response, err error, dur time.Duration)
+	logURLs              bool
+	excludeExtensions    map[string]bool
+	respectNofollow      bool
+	deduplicateContent   bool
+	respectCanonical     bool
+	respectMetaRobots    bool
+	contentHashes        sync.Map
+	redirectedTo         sync.Map
+	outstanding          int64
+	active               int64
 	stats                *CrawlerStats
 	logger               *slog.Logger
+	mutex                sync.Mutex
 	running              bool
+	stopped              bool
+	stop                 chan struct{}
+	unpause              chan struct{}
 	showProgress         bool
 	showProgressInterval time.Duration
+	respectRobots        bool
+	userAgent            string
+	defaultHeaders       map[string]string
+	allowedContentTypes  []string
+	revalidateCache      bool
+	maxResponseBytes     int64
+	truncateLarge        bool
+	preflightHead        bool
+	hostHeaders          map[string]map[string]string
+	robots               *robotsCache
+	httpClient           *http.Client
+	seedSitemaps         []string
+	hostLimiter          *hostLimiter
+	globalLimiter        *rateLimiter
+	adaptive             *adaptiveLimiter
+	graph                *LinkGraph
+	inFlight             sync.Map
+	checkpointPath       string
+	checkpointInterval   time.Duration
 }
 
 // New creates a new crawler.
@@ -93,41 +634,181 @@ func New(opts Options) *Crawler {
 	if opts.ShowProgress && opts.ShowProgressInterval == 0 {
 		opts.ShowProgressInterval = 30 * time.Second
 	}
+	if opts.Deterministic {
+		opts.Workers = 1
+	}
+	opts.Normalize.TrimTrailingSlash = true
 	if opts.QueueSize <= 0 {
-		opts.QueueSize = 10000
+		opts.QueueSize = DefaultQueueSize
+	}
+	var newFrontier func() Frontier
+	if opts.Frontier == nil {
+		newFrontier = func() Frontier {
+			switch {
+			case opts.Priority != nil:
+				return NewPriorityFrontier(opts.QueueSize, opts.Priority)
+			case opts.Strategy == StrategyBFS:
+				return NewBFSFrontier(opts.QueueSize)
+			default:
+				return NewChannelFrontier(opts.QueueSize)
+			}
+		}
+		opts.Frontier = newFrontier()
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = fetch.DefaultHTTPClient
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = DefaultRetryBackoff
+	}
+	if opts.MaxRetryAfter <= 0 {
+		opts.MaxRetryAfter = DefaultMaxRetryAfter
+	}
+	if opts.AllowedContentTypes == nil {
+		opts.AllowedContentTypes = fetch.DefaultAllowedContentTypes
+	}
+	if opts.CheckpointInterval <= 0 {
+		opts.CheckpointInterval = DefaultCheckpointInterval
+	}
+	if opts.MaxRateLimitRetries <= 0 {
+		opts.MaxRateLimitRetries = DefaultMaxRateLimitRetries
+	}
+	if opts.CacheErrorTTL <= 0 {
+		opts.CacheErrorTTL = DefaultCacheErrorTTL
+	}
+	var adaptive *adaptiveLimiter
+	if opts.Adaptive {
+		if opts.AdaptiveMinConcurrency <= 0 {
+			opts.AdaptiveMinConcurrency = 1
+		}
+		if opts.AdaptiveMaxConcurrency <= 0 {
+			opts.AdaptiveMaxConcurrency = opts.Workers
+		}
+		opts.AdaptiveMaxConcurrency = max(opts.AdaptiveMaxConcurrency, opts.AdaptiveMinConcurrency)
+		if opts.AdaptiveTargetLatency <= 0 {
+			opts.AdaptiveTargetLatency = DefaultAdaptiveTargetLatency
+		}
+		adaptive = newAdaptiveLimiter(opts.AdaptiveMinConcurrency,
+			opts.AdaptiveMaxConcurrency, opts.AdaptiveTargetLatency)
+	}
+	var graph *LinkGraph
+	if opts.RecordGraph {
+		graph = NewLinkGraph()
+	}
+	var processedURLs urlSet = newExactURLSet()
+	if opts.ApproxDedup {
+		if opts.ApproxDedupItems <= 0 {
+			opts.ApproxDedupItems = DefaultApproxDedupItems
+		}
+		if opts.ApproxDedupErrorRate <= 0 || opts.ApproxDedupErrorRate >= 1 {
+			opts.ApproxDedupErrorRate = DefaultApproxDedupErrorRate
+		}
+		processedURLs = newBloomURLSet(opts.ApproxDedupItems, opts.ApproxDedupErrorRate)
 	}
 	return &Crawler{
 		cache:                opts.Cache,
+		cacheTTL:             opts.CacheTTL,
+		cacheErrors:          opts.CacheErrors,
+		cacheErrorTTL:        opts.CacheErrorTTL,
 		maxURLs:              opts.MaxURLs,
+		maxURLsPerDomain:     opts.MaxURLsPerDomain,
+		maxDuration:          opts.MaxDuration,
+		stayAlive:            opts.StayAlive,
+		maxURLsPerPath:       opts.MaxURLsPerPath,
+		maxSegmentRepeats:    opts.MaxPathSegmentRepeats,
+		normalize:            opts.Normalize,
+		normalizer:           opts.Normalizer,
+		rewriteURL:           opts.RewriteURL,
+		ignoreWWW:            opts.IgnoreWWW,
+		maxDepth:             opts.MaxDepth,
 		workers:              opts.Workers,
+		maxRetries:           opts.MaxRetries,
+		requestTimeout:       opts.RequestTimeout,
+		retryBackoff:         opts.RetryBackoff,
+		maxRetryAfter:        opts.MaxRetryAfter,
+		maxRateLimitRetries:  opts.MaxRateLimitRetries,
 		requestDelay:         opts.RequestDelay,
+		delayJitter:          opts.RequestDelayJitter,
 		fetcher:              opts.Fetcher,
 		fetcherName:          opts.FetcherName,
+		fetchers:             opts.Fetchers,
+		fetcherRules:         opts.FetcherRules,
 		knownURLs:            opts.KnownURLs,
 		parsers:              opts.Parsers,
+		parserRules:          opts.ParserRules,
+		fetchOnly:            opts.FetchOnly,
 		followBehavior:       opts.FollowBehavior,
+		includePatterns:      opts.IncludePatterns,
+		excludePatterns:      opts.ExcludePatterns,
+		pathPrefixes:         opts.PathPrefixes,
+		allowedDomains:       normalizeDomains(opts.AllowedDomains, opts.Normalize),
+		blockedDomains:       normalizeDomains(opts.BlockedDomains, opts.Normalize),
+		shouldFollow:         opts.ShouldFollow,
+		onEnqueue:            opts.OnEnqueue,
+		onFetchStart:         opts.OnFetchStart,
+		onFetchDone:          opts.OnFetchDone,
+		logURLs:              opts.LogURLs,
+		excludeExtensions:    extensionSet(opts.ExcludeExtensions),
+		respectNofollow:      opts.RespectNofollow,
+		deduplicateContent:   opts.DeduplicateContent,
+		respectCanonical:     opts.RespectCanonical,
+		respectMetaRobots:    opts.RespectMetaRobots,
 		defaultParser:        opts.DefaultParser,
+		processedURLs:        processedURLs,
 		stats:                &CrawlerStats{},
 		logger:               logger,
 		showProgress:         opts.ShowProgress,
 		showProgressInterval: opts.ShowProgressInterval,
-		queue:                make(chan string, opts.QueueSize),
+		frontier:             opts.Frontier,
+		newFrontier:          newFrontier,
+		deterministic:        opts.Deterministic,
+		respectRobots:        opts.RespectRobots,
+		userAgent:            opts.UserAgent,
+		defaultHeaders:       opts.DefaultHeaders,
+		allowedContentTypes:  opts.AllowedContentTypes,
+		revalidateCache:      opts.RevalidateCache,
+		maxResponseBytes:     opts.MaxResponseBytes,
+		truncateLarge:        opts.TruncateLargeResponses,
+		preflightHead:        opts.PreflightHead,
+		hostHeaders:          opts.HostHeaders,
+		robots:               newRobotsCache(opts.HTTPClient, opts.UserAgent),
+		httpClient:           opts.HTTPClient,
+		seedSitemaps:         opts.SeedSitemaps,
+		hostLimiter:          newHostLimiter(opts.PerHostDelay, opts.RequestDelayJitter),
+		globalLimiter:        newGlobalLimiter(opts.GlobalRPS),
+		adaptive:             adaptive,
+		graph:                graph,
+		checkpointPath:       opts.CheckpointPath,
+		checkpointInterval:   opts.CheckpointInterval,
 	}
 }
 
-// incrementActiveWorkers atomically increments the active workers counter
-func (c *Crawler) incrementActiveWorkers() {
-	atomic.AddInt64(&c.activeWorkers, 1)
+// addOutstanding atomically adjusts the count of outstanding entries. An
+// entry is outstanding from just before it is pushed to the frontier until a
+// worker has finished processing it, which includes queueing its links, so
+// the count never drops to zero while work remains. Pending requeues are
+// outstanding until they are pushed again.
+func (c *Crawler) addOutstanding(delta int64) {
+	atomic.AddInt64(&c.outstanding, delta)
+}
+
+// getOutstanding atomically gets the count of outstanding entries
+func (c *Crawler) getOutstanding() int64 {
+	return atomic.LoadInt64(&c.outstanding)
 }
 
-// decrementActiveWorkers atomically decrements the active workers counter
-func (c *Crawler) decrementActiveWorkers() {
-	atomic.AddInt64(&c.activeWorkers, -1)
+// isIdle reports whether there is no work queued or in progress
+func (c *Crawler) isIdle() bool {
+	return c.getOutstanding() <= 0 && c.frontier.Len() == 0
 }
 
-// getActiveWorkers atomically gets the current active workers count
-func (c *Crawler) getActiveWorkers() int64 {
-	return atomic.LoadInt64(&c.activeWorkers)
+// isDone reports whether the crawl should end because it is idle. With
+// StayAlive, an idle crawl continues unless MaxURLs has been reached.
+func (c *Crawler) isDone() bool {
+	if !c.isIdle() {
+		return false
+	}
+	return !c.stayAlive || c.maxURLs > 0 && c.stats.GetProcessed() >= int64(c.maxURLs)
 }
 
 func (c *Crawler) getFetcherName() string {
@@ -138,206 +819,1137 @@ func (c *Crawler) getFetcherName() string {
 }
 
 // Crawl the provided URLs and call the callback for each processed page.
-// Links may be followed depending on the configured follow behavior.
+// Links may be followed depending on the configured follow behavior. The
+// reason the crawl stopped is available from GetStats().GetStopReason().
+//
+// A Crawler may crawl more than once. Each crawl carries on from the state
+// left by the last: URLs that have already been queued are not crawled again,
+// the per-domain limits and statistics accumulate, and URLs left in the
+// frontier by a stopped crawl are crawled. Call Reset between crawls to start
+// afresh instead.
 func (c *Crawler) Crawl(ctx context.Context, urls []string, callback Callback) error {
-	if c.running {
-		return errors.New("crawler is already running")
+	stop, err := c.start()
+	if err != nil {
+		return err
 	}
-	c.running = true
+	defer c.finish()
+	return c.crawl(ctx, stop, seedConfigs(urls), callback)
+}
+
+// CrawlChan crawls the provided URLs like Crawl, but delivers results on the
+// returned channel instead of to a callback. The channel is closed when the
+// crawl finishes. Results arrive in the order pages finish processing, which
+// is not the order they were discovered. Errors for individual pages are
+// reported in Result.Error.
+//
+// Workers block until their result is received, so a slow reader slows the
+// crawl. The caller must either drain the channel or cancel the context.
+func (c *Crawler) CrawlChan(ctx context.Context, urls []string) (<-chan *Result, error) {
+	stop, err := c.start()
+	if err != nil {
+		return nil, err
+	}
+	results := make(chan *Result)
+	go func() {
+		defer close(results)
+		defer c.finish()
+		c.crawl(ctx, stop, seedConfigs(urls), func(ctx context.Context, result *Result) {
+			select {
+			case results <- result:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return results, nil
+}
+
+// crawl queues the seeds and runs the crawl to completion.
+func (c *Crawler) crawl(ctx context.Context, stop <-chan struct{}, seeds []SeedConfig, callback Callback) error {
+	// Queue initial URLs, including any listed in sitemaps, skipping any
+	// that are excluded
+	if callback == nil {
+		callback = func(context.Context, *Result) {}
+	}
+	var included []SeedConfig
+	candidates := append(append([]SeedConfig{}, seeds...), seedConfigs(c.loadSitemaps(ctx))...)
+	for _, seed := range candidates {
+		if !c.isExcluded(seed.URL) {
+			included = append(included, seed)
+		}
+	}
+	return c.run(ctx, stop, included, callback)
+}
+
+// run starts the workers, queues the seed URLs, and waits until there is no
+// more work or the crawl is stopped.
+func (c *Crawler) run(ctx context.Context, stop <-chan struct{}, seeds []SeedConfig, callback Callback) error {
+	// Cancellation by the caller is reported once the workers return
+	parent := ctx
+	defer func() {
+		if parent.Err() != nil {
+			c.stats.SetStopReason(ReasonContextCancelled)
+		}
+	}()
 
 	// This context will be used to stop workers when the work is done
 	ctx, cancel := context.WithCancel(ctx)
-	defer func() {
-		c.running = false
-		cancel()
+	defer cancel()
+
+	// Queue the seeds before starting the workers so that they are crawled
+	// in the order given
+	count, err := c.enqueueSeeds(ctx, seeds)
+	if err != nil {
+		return err
+	}
+	if count == 0 && c.isDone() {
+		c.stats.SetStopReason(ReasonIdle)
+		return nil
+	}
+
+	// Workers pop from the frontier using a context that is also cancelled
+	// by Stop, which lets in-flight URLs finish while preventing new ones
+	// from starting
+	popCtx, cancelPop := context.WithCancel(ctx)
+	defer cancelPop()
+	go func() {
+		select {
+		case <-stop:
+			cancelPop()
+		case <-popCtx.Done():
+		}
 	}()
 
+	// Stop starting new URLs when the time limit elapses
+	if c.maxDuration > 0 {
+		timer := time.AfterFunc(c.maxDuration, func() {
+			c.logger.Info("time limit reached, stopping crawler")
+			c.stats.SetStopReason(ReasonTimeout)
+			cancelPop()
+		})
+		defer timer.Stop()
+	}
+
 	// Start workers
 	var wg sync.WaitGroup
 	for i := 0; i < c.workers; i++ {
 		wg.Add(1)
-		go c.worker(ctx, &wg, callback)
+		go c.worker(ctx, popCtx, &wg, stop, callback)
 	}
-	defer close(c.queue)
 
 	// Optionally start the progress reporter
 	if c.showProgress {
 		go c.progressReporter(ctx)
 	}
 
-	// Start idle monitor to detect when no more work is available
-	go c.idleMonitor(ctx, cancel)
+	// Start idle monitor to detect when no more work is available. In
+	// deterministic mode the single worker returns once the work is done.
+	if !c.deterministic {
+		go c.idleMonitor(ctx, cancel)
+	}
+
+	// Optionally start saving checkpoints
+	if c.checkpointPath != "" {
+		go c.checkpointer(ctx)
+	}
+
+	// Wait for workers to complete, then save the final state
+	wg.Wait()
+	if c.checkpointPath != "" {
+		c.saveCheckpoint()
+	}
+	return nil
+}
+
+// loadSitemaps returns the page URLs listed in the configured seed sitemaps.
+// Sitemaps that fail to load are logged and skipped.
+func (c *Crawler) loadSitemaps(ctx context.Context) []string {
+	var urls []string
+	for _, sitemapURL := range c.seedSitemaps {
+		sitemapURLs, err := LoadSitemap(ctx, c.httpClient, sitemapURL)
+		if err != nil {
+			c.logger.Warn("failed to load sitemap",
+				slog.String("url", sitemapURL),
+				slog.String("error", err.Error()))
+			continue
+		}
+		c.logger.Debug("loaded sitemap",
+			slog.String("url", sitemapURL),
+			slog.Int("urls", len(sitemapURLs)))
+		urls = append(urls, sitemapURLs...)
+	}
+	return urls
+}
+
+// AddURLs queues more seed URLs while a crawl is running, such as URLs
+// received from another system. The URLs are normalized and filtered like
+// those given to Crawl, and URLs that have already been queued or that would
+// exceed MaxURLs are ignored. It returns the number of URLs queued, or
+// ErrNotRunning if no crawl is in progress or the crawl has been stopped. It
+// is safe to call from any goroutine, including callbacks.
+//
+// Queued URLs keep the crawl from going idle until they are processed.
+// However, URLs added just as a crawl finishes are left in the frontier for
+// the next crawl.
+func (c *Crawler) AddURLs(ctx context.Context, urls []string) (int, error) {
+	c.mutex.Lock()
+	running := c.running && !c.stopped
+	c.mutex.Unlock()
+	if !running {
+		return 0, ErrNotRunning
+	}
+	var seeds []string
+	for _, rawURL := range urls {
+		if !c.isExcluded(rawURL) {
+			seeds = append(seeds, rawURL)
+		}
+	}
+	return c.enqueue(ctx, seeds, nil)
+}
+
+// Reset clears the state kept between crawls: the URLs that have been
+// queued, per-domain counts, content hashes, statistics, and the link graph.
+// A frontier created by the crawler is replaced with an empty one, while a
+// Frontier supplied in Options is left as it is. Reset returns
+// ErrAlreadyRunning if a crawl is in progress.
+func (c *Crawler) Reset() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.running {
+		return ErrAlreadyRunning
+	}
+	c.processedURLs.Clear()
+	c.domainCounts.Clear()
+	c.contentHashes.Clear()
+	c.redirectedTo.Clear()
+	c.pathVariants.Clear()
+	c.inFlight.Clear()
+	c.stats.reset()
+	if c.graph != nil {
+		c.graph.clear()
+	}
+	if c.newFrontier != nil {
+		c.frontier = c.newFrontier()
+	}
+	return nil
+}
 
-	// Queue initial URLs
-	count, err := c.enqueue(ctx, urls)
+// Invalidate removes the cached response for the URL and forgets that it was
+// processed, so that the URL is fetched again the next time it is crawled.
+// Processed URLs cannot be forgotten with Options.ApproxDedup, so only the
+// cached response is removed in that case.
+func (c *Crawler) Invalidate(ctx context.Context, rawURL string) error {
+	u, err := c.normalizeURL(rawURL)
 	if err != nil {
 		return err
 	}
-	if count == 0 {
+	c.processedURLs.Remove(u.String())
+	c.redirectedTo.Delete(u.String())
+	if c.cache == nil {
 		return nil
 	}
+	return c.cache.Delete(ctx, u.String())
+}
+
+// start marks the crawler as running and returns the channel that is closed
+// when Stop is called.
+func (c *Crawler) start() (chan struct{}, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.running {
+		return nil, ErrAlreadyRunning
+	}
+	// Entries already in the frontier, such as those left by a persistent
+	// frontier or a stopped crawl, are outstanding. They are counted before
+	// the crawler is marked running, so that URLs added from then on by
+	// AddURLs are counted on top.
+	atomic.StoreInt64(&c.outstanding, int64(c.frontier.Len()))
+	c.running = true
+	c.stopped = false
+	c.stats.resetStopReason()
+	c.stop = make(chan struct{})
+	return c.stop, nil
+}
 
-	// Wait for workers to complete
-	wg.Wait()
-	return nil
+// finish marks the crawler as no longer running.
+func (c *Crawler) finish() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.running = false
+}
+
+// Stop signals the workers to finish their current URL and return, which
+// causes Crawl to return. It is safe to call from any goroutine and calling
+// it more than once, or when the crawler is not running, has no effect.
+func (c *Crawler) Stop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.running && !c.stopped {
+		c.stopped = true
+		c.stats.SetStopReason(ReasonStopped)
+		close(c.stop)
+	}
+}
+
+// Pause stops the workers from starting new URLs until Unpause is called. URLs
+// already being processed are finished, and the frontier and the record of
+// processed URLs are kept. A paused crawl is never considered idle, so Crawl
+// does not return until the crawl is unpaused or stopped, or MaxDuration
+// elapses. Pausing before Crawl is called starts the crawl paused. Calling
+// Pause when already paused has no effect.
+func (c *Crawler) Pause() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.unpause == nil {
+		c.unpause = make(chan struct{})
+	}
+}
+
+// Unpause lets the workers continue after Pause. Calling Unpause when not
+// paused has no effect.
+func (c *Crawler) Unpause() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.unpause != nil {
+		close(c.unpause)
+		c.unpause = nil
+	}
+}
+
+// Paused reports whether the crawler is paused.
+func (c *Crawler) Paused() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.unpause != nil
+}
+
+// waitWhilePaused blocks while the crawler is paused. It returns the context's
+// error if the context is cancelled first.
+func (c *Crawler) waitWhilePaused(ctx context.Context) error {
+	c.mutex.Lock()
+	unpause := c.unpause
+	c.mutex.Unlock()
+	if unpause == nil {
+		return nil
+	}
+	select {
+	case <-unpause:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func (c *Crawler) enqueue(ctx context.Context, urls []string) (int, error) {
+// enqueue normalizes and queues seed URLs, which come from outside the crawl,
+// with the seed's scope if it has one. Invalid URLs are logged and ignored.
+func (c *Crawler) enqueue(ctx context.Context, urls []string, seed *SeedConfig) (int, error) {
+	normalized := make([]*url.URL, 0, len(urls))
+	for _, rawURL := range urls {
+		u, err := c.normalizeURL(rawURL)
+		if err != nil {
+			c.logger.Warn("invalid url",
+				slog.String("url", rawURL),
+				slog.String("error", err.Error()))
+			continue
+		}
+		normalized = append(normalized, u)
+	}
+	return c.enqueueURLs(ctx, normalized, nil, 0, seed)
+}
+
+// enqueueURLs queues normalized URLs that have not been queued before,
+// returning the number queued. The entries carry the scope of the seed they
+// were reached from, if any.
+func (c *Crawler) enqueueURLs(ctx context.Context, urls []*url.URL, parent *url.URL, depth int, seed *SeedConfig) (int, error) {
 	// Prevent exceeding the max URLs limit
 	if c.maxURLs > 0 {
 		allowedCount := c.maxURLs - int(c.stats.GetProcessed())
 		if allowedCount <= 0 {
+			if len(urls) > 0 {
+				c.stats.SetStopReason(ReasonMaxURLs)
+			}
 			return 0, nil
 		}
 		if allowedCount < len(urls) {
+			c.stats.SetStopReason(ReasonMaxURLs)
 			urls = urls[:allowedCount]
 		}
 	}
-	// Normalize and enqueue the URLs
+	// Mark the URLs as processed in one batch, then queue the ones that
+	// were not already processed
+	values := make([]string, len(urls))
+	for i, url := range urls {
+		values[i] = url.String()
+	}
+	added := c.processedURLs.AddAll(values)
+	var parentURL string
+	if parent != nil {
+		parentURL = parent.String()
+	}
 	queued := 0
-	for _, rawURL := range urls {
-		url, err := web.NormalizeURL(rawURL)
-		if err != nil {
-			c.logger.Warn("invalid url",
-				slog.String("url", rawURL),
-				slog.String("error", err.Error()))
+	for i, url := range urls {
+		if !added[i] {
 			continue
 		}
-		value := strings.TrimSuffix(url.String(), "/")
-		// Only enqueue if not already processed
-		if _, exists := c.processedURLs.LoadOrStore(value, true); !exists {
-			select {
-			case c.queue <- value:
-				queued++
-			case <-ctx.Done():
-				return queued, ctx.Err()
-			default:
-				// Queue is full, skip this URL
+		value := values[i]
+		if ctx.Err() != nil {
+			for j := i; j < len(urls); j++ {
+				if added[j] {
+					c.processedURLs.Remove(values[j])
+				}
+			}
+			return queued, ctx.Err()
+		}
+		// Blocked, domain limited and trap URLs stay marked as processed, so
+		// they are only counted once
+		if c.isBlockedDomain(url) {
+			c.stats.IncrementBlocked()
+			c.logger.Debug("domain blocked, dropped url",
+				slog.String("url", value))
+			continue
+		}
+		if !c.reserveDomain(url.Host) {
+			c.stats.IncrementDomainLimited()
+			c.logger.Debug("domain limit reached, dropped url",
+				slog.String("url", value))
+			continue
+		}
+		// Links found on pages are checked for traps last, so that only URLs
+		// that are queued use up the variants allowed for their path
+		if parent != nil && c.isTrap(url) {
+			c.releaseDomain(url.Host)
+			c.logger.Debug("crawl trap, dropped url",
+				slog.String("url", value))
+			continue
+		}
+		entry := FrontierEntry{URL: value, Depth: depth, Parent: parentURL, Seed: seed}
+		if c.push(entry) {
+			queued++
+			if c.onEnqueue != nil {
+				c.onEnqueue(value, depth)
 			}
 		}
 	}
 	return queued, nil
 }
 
-func (c *Crawler) worker(ctx context.Context, wg *sync.WaitGroup, callback Callback) {
+// push adds an entry to the frontier. If the frontier rejects it, the URL is
+// forgotten so that it may be queued again if it is rediscovered.
+func (c *Crawler) push(entry FrontierEntry) bool {
+	// Count the entry first so that it cannot be popped and completed before
+	// it is counted
+	c.addOutstanding(1)
+	err := c.frontier.Push(entry)
+	if err == nil {
+		return true
+	}
+	c.addOutstanding(-1)
+	c.processedURLs.Remove(entry.URL)
+	if u, err := url.Parse(entry.URL); err == nil {
+		c.releaseDomain(u.Host)
+	}
+	c.stats.IncrementDropped()
+	if errors.Is(err, ErrFrontierFull) {
+		c.logger.Warn("queue full, dropped url", slog.String("url", entry.URL))
+	} else {
+		c.logger.Warn("failed to queue url",
+			slog.String("url", entry.URL),
+			slog.String("error", err.Error()))
+	}
+	return false
+}
+
+// reserveDomain counts a URL against its host's limit, reporting false if
+// the host has already reached Options.MaxURLsPerDomain.
+func (c *Crawler) reserveDomain(host string) bool {
+	if c.maxURLsPerDomain <= 0 {
+		return true
+	}
+	value, _ := c.domainCounts.LoadOrStore(host, new(int64))
+	count := value.(*int64)
+	if atomic.AddInt64(count, 1) > int64(c.maxURLsPerDomain) {
+		atomic.AddInt64(count, -1)
+		return false
+	}
+	return true
+}
+
+// releaseDomain returns a URL reserved with reserveDomain that was not queued.
+func (c *Crawler) releaseDomain(host string) {
+	if c.maxURLsPerDomain <= 0 {
+		return
+	}
+	if value, ok := c.domainCounts.Load(host); ok {
+		atomic.AddInt64(value.(*int64), -1)
+	}
+}
+
+func (c *Crawler) worker(ctx, popCtx context.Context, wg *sync.WaitGroup, stop <-chan struct{}, callback Callback) {
 	defer wg.Done()
 	for {
+		// Give a stop request priority over any queued work
 		select {
-		case <-ctx.Done():
+		case <-stop:
 			return
-		case rawURL, ok := <-c.queue:
-			if !ok {
-				return
+		default:
+		}
+		if err := c.waitWhilePaused(popCtx); err != nil {
+			return
+		}
+		// The only worker knows the crawl is done when nothing is queued
+		if c.deterministic && c.isDone() {
+			c.logger.Info("no more work available, stopping crawler")
+			c.stats.SetStopReason(ReasonIdle)
+			return
+		}
+		entry, err := c.frontier.Pop(popCtx)
+		if err != nil {
+			if popCtx.Err() == nil {
+				c.logger.Error("failed to read from frontier",
+					slog.String("error", err.Error()))
 			}
-			c.incrementActiveWorkers()
-			c.processURL(ctx, rawURL, callback)
-			c.decrementActiveWorkers()
-			if c.requestDelay > 0 {
-				time.Sleep(c.requestDelay)
+			return
+		}
+		// An entry popped as the crawl was paused waits for it to be
+		// unpaused. If the crawl ends first, the entry is returned to the
+		// frontier.
+		if err := c.waitWhilePaused(popCtx); err != nil {
+			if err := c.frontier.Push(entry); err != nil {
+				c.logger.Warn("failed to return url to frontier",
+					slog.String("url", entry.URL),
+					slog.String("error", err.Error()))
 			}
+			if completer, ok := c.frontier.(FrontierCompleter); ok {
+				completer.Complete(entry)
+			}
+			return
+		}
+		c.inFlight.Store(entry.URL, entry)
+		atomic.AddInt64(&c.active, 1)
+		c.processURL(ctx, entry, callback)
+		atomic.AddInt64(&c.active, -1)
+		// URLs interrupted by cancellation remain in flight so that the
+		// final checkpoint includes them
+		if ctx.Err() == nil {
+			c.inFlight.CompareAndDelete(entry.URL, entry)
+		}
+		if completer, ok := c.frontier.(FrontierCompleter); ok {
+			completer.Complete(entry)
+		}
+		c.addOutstanding(-1)
+		if c.requestDelay > 0 || c.delayJitter > 0 {
+			time.Sleep(jittered(c.requestDelay, c.delayJitter))
 		}
 	}
 }
 
-func (c *Crawler) processURL(ctx context.Context, rawURL string, callback Callback) {
-	c.stats.IncrementProcessed()
+func (c *Crawler) processURL(ctx context.Context, entry FrontierEntry, callback Callback) {
+	rawURL := entry.URL
 
 	// Parse the url to get its domain
 	parsedURL, err := url.Parse(rawURL)
 	if err != nil {
+		c.stats.IncrementProcessed()
 		c.logger.Warn("invalid url",
 			slog.String("url", rawURL),
 			slog.String("error", err.Error()))
 		return
 	}
 	domain := parsedURL.Hostname()
+	var parentURL *url.URL
+	if entry.Parent != "" {
+		parentURL, _ = url.Parse(entry.Parent)
+	}
+
+	// Skip URLs disallowed by robots.txt. They are counted separately, so
+	// that they do not count towards MaxURLs.
+	if c.respectRobots {
+		if err := c.robotsAllowed(ctx, parsedURL); err != nil {
+			c.logger.Debug("disallowed by robots.txt",
+				slog.String("url", rawURL),
+				slog.String("error", err.Error()))
+			callback(ctx, &Result{
+				URL:       parsedURL,
+				Error:     err,
+				Depth:     entry.Depth,
+				ParentURL: parentURL,
+			})
+			c.stats.IncrementRobotsDenied()
+			return
+		}
+	}
+	// Entries requeued after a Retry-After were counted on their first
+	// attempt, as retries with backoff are
+	if entry.Requeues == 0 {
+		c.stats.IncrementProcessed()
+		c.stats.IncrementDomainProcessed(domain)
+	}
 
 	// Check cache first if one is enabled
-	var response *fetch.Response
+	var response, cached *fetch.Response
+	var summary urlSummary
+	if c.logURLs {
+		defer func() { c.logURL(ctx, rawURL, response, summary) }()
+	}
 	if c.cache != nil {
-		if cachedHTML, err := c.cache.Get(ctx, rawURL); err == nil {
+		if value, err := c.cache.Get(ctx, rawURL); err == nil {
+			response = decodeCachedResponse(rawURL, value)
+		}
+		if response != nil {
 			c.logger.Debug("cache hit", slog.String("url", rawURL))
-			response = &fetch.Response{
-				URL:  rawURL,
-				HTML: string(cachedHTML),
-			}
+			c.stats.IncrementCacheHits()
+		} else {
+			c.stats.IncrementCacheMisses()
+		}
+	}
+
+	// Report URLs known to be dead without fetching them again
+	if response != nil && response.StatusCode >= 400 {
+		if !c.cacheErrors {
+			response = nil
+		} else {
+			c.logger.Debug("known dead url",
+				slog.String("url", rawURL),
+				slog.Int("status_code", response.StatusCode))
+			callback(ctx, &Result{
+				URL:        parsedURL,
+				StatusCode: response.StatusCode,
+				Response:   response,
+				Error:      statusError(response),
+				Depth:      entry.Depth,
+				ParentURL:  parentURL,
+			})
+			c.stats.IncrementFailed()
+			c.stats.IncrementDomainFailed(domain)
+			return
 		}
 	}
 
+	// Choose the fetcher for the URL
+	fetcher, fetcherName, err := c.fetcherFor(parsedURL)
+	if err != nil {
+		callback(ctx, &Result{
+			URL:       parsedURL,
+			Error:     err,
+			Depth:     entry.Depth,
+			ParentURL: parentURL,
+		})
+		c.stats.IncrementFailed()
+		c.stats.IncrementDomainFailed(domain)
+		return
+	}
+
 	// Create fetch request
 	req := &fetch.Request{
 		URL:             rawURL,
 		Prettify:        false,
 		OnlyMainContent: false,
-		Fetcher:         c.getFetcherName(),
+		Fetcher:         fetcherName,
+		MaxBodySize:     c.maxResponseBytes,
+		TruncateBody:    c.truncateLarge,
+	}
+	req.Headers = c.requestHeaders(domain)
+
+	// Revalidate the cached page if the server supports conditional requests
+	if response != nil && c.revalidateCache {
+		req.ETag = response.GetHeader("ETag")
+		req.LastModified = response.GetHeader("Last-Modified")
+		if req.ETag != "" || req.LastModified != "" {
+			cached, response = response, nil
+		}
+	}
+
+	// Check the type and size of the resource before downloading it
+	if response == nil && c.preflightHead {
+		if head, err := c.preflight(ctx, fetcher, req, parsedURL.Host); err != nil {
+			c.logger.Debug("skipped after preflight",
+				slog.String("url", rawURL),
+				slog.String("error", err.Error()))
+			result := &Result{
+				URL:       parsedURL,
+				Response:  head,
+				Error:     err,
+				Depth:     entry.Depth,
+				ParentURL: parentURL,
+			}
+			if head != nil {
+				result.StatusCode = head.StatusCode
+			}
+			callback(ctx, result)
+			if errors.Is(err, fetch.ErrResponseTooLarge) {
+				c.stats.IncrementOversized()
+				c.stats.IncrementFailed()
+				c.stats.IncrementDomainFailed(domain)
+			} else {
+				c.stats.IncrementSkipped()
+			}
+			return
+		}
 	}
 
 	// Fetch if there was not a cache hit
 	if response == nil {
 		c.logger.Debug("fetching", slog.String("url", rawURL))
-		response, err = c.fetcher.Fetch(ctx, req)
+		if c.onFetchStart != nil {
+			c.onFetchStart(rawURL)
+		}
+		started := time.Now()
+		response, err = c.fetchWithRetry(ctx, fetcher, req, parsedURL.Host)
+		summary.fetched = true
+		summary.duration = time.Since(started)
+		if c.onFetchDone != nil {
+			c.onFetchDone(rawURL, response, err, summary.duration)
+		}
+		if response != nil && response.StatusCode > 0 {
+			c.stats.RecordStatusCode(response.StatusCode)
+		}
+		if delay, limited := c.retryAfter(response); limited && entry.Requeues < c.maxRateLimitRetries {
+			c.logger.Debug("rate limited, requeueing url",
+				slog.String("url", rawURL),
+				slog.Duration("delay", delay))
+			c.hostLimiter.Delay(parsedURL.Host, delay)
+			c.requeue(ctx, entry, delay)
+			return
+		}
+		// A fetch interrupted by the crawl being cancelled is not a failure.
+		// The URL is left in flight, so that a checkpoint includes it.
+		if err != nil && ctx.Err() != nil {
+			c.logger.Debug("fetch cancelled",
+				slog.String("url", rawURL),
+				slog.String("error", err.Error()))
+			c.stats.IncrementCancelled()
+			return
+		}
 		if err != nil {
-			callback(ctx, &Result{URL: parsedURL, Error: err})
+			result := &Result{
+				URL:       parsedURL,
+				Response:  response,
+				Error:     err,
+				Depth:     entry.Depth,
+				ParentURL: parentURL,
+			}
+			if response != nil {
+				result.StatusCode = response.StatusCode
+			}
+			if errors.Is(err, fetch.ErrResponseTooLarge) {
+				c.stats.IncrementOversized()
+			}
+			if c.cacheErrors && c.cache != nil && response != nil && isDeadStatus(response.StatusCode) {
+				c.storeDeadURL(ctx, rawURL, response.StatusCode)
+			}
+			callback(ctx, result)
 			c.stats.IncrementFailed()
+			c.stats.IncrementDomainFailed(domain)
+			return
+		}
+		if cached != nil && response.StatusCode == http.StatusNotModified {
+			c.logger.Debug("not modified", slog.String("url", rawURL))
+			c.stats.IncrementNotModified()
+			response = cached
+		} else {
+			if response.Truncated {
+				c.stats.IncrementOversized()
+			}
+			c.stats.AddBytesDownloaded(int64(len(response.HTML)))
+			c.stats.AddDomainBytes(domain, int64(len(response.HTML)))
+			if c.cache != nil && response.HTML != "" &&
+				fetch.ContentTypeAllowed(response.ContentType, c.allowedContentTypes) {
+				c.storeResponse(ctx, rawURL, response)
+			}
+		}
+	}
+
+	// Handle redirects. The final URL is marked as processed so that it is
+	// not fetched again if it is linked to directly.
+	pageURL := parsedURL
+	var finalURL *url.URL
+	if response.FinalURL != "" {
+		if u, err := c.normalizeURL(response.FinalURL); err == nil && u.String() != rawURL {
+			finalURL = u
+		}
+	}
+	if finalURL != nil {
+		if !c.followsRedirect(parsedURL, finalURL, entry.Seed) || !c.allowsLink(finalURL, entry.Seed) ||
+			c.isBlockedDomain(finalURL) || c.isTrap(finalURL) {
+			c.logger.Debug("redirect not followed",
+				slog.String("url", rawURL),
+				slog.String("final_url", finalURL.String()))
+			callback(ctx, &Result{
+				URL:        parsedURL,
+				StatusCode: response.StatusCode,
+				Response:   response,
+				Error:      ErrRedirectNotFollowed,
+				Depth:      entry.Depth,
+				ParentURL:  parentURL,
+				FinalURL:   finalURL,
+				Redirects:  response.Redirects,
+			})
+			c.stats.IncrementSkipped()
+			return
+		}
+		// A page that redirects to a queued or processed URL is a duplicate,
+		// unless that URL redirected back to this one, in which case neither
+		// page would otherwise be processed. The redirect is recorded first
+		// so that two pages processed at once still see each other.
+		c.redirectedTo.Store(rawURL, finalURL.String())
+		if !c.processedURLs.Add(finalURL.String()) && !c.redirectsTo(finalURL.String(), rawURL) {
+			c.logger.Debug("redirected to a queued url",
+				slog.String("url", rawURL),
+				slog.String("final_url", finalURL.String()))
+			callback(ctx, &Result{
+				URL:         parsedURL,
+				StatusCode:  response.StatusCode,
+				Response:    response,
+				Depth:       entry.Depth,
+				ParentURL:   parentURL,
+				Duplicate:   true,
+				DuplicateOf: finalURL,
+				FinalURL:    finalURL,
+				Redirects:   response.Redirects,
+			})
+			c.stats.IncrementDuplicates()
 			return
 		}
-		if c.cache != nil && response.HTML != "" {
-			if err := c.cache.Set(ctx, rawURL, []byte(response.HTML)); err != nil {
-				c.logger.Warn("failed to cache html",
+		pageURL = finalURL
+	}
+
+	// Skip content that is not a page
+	if !fetch.ContentTypeAllowed(response.ContentType, c.allowedContentTypes) {
+		c.logger.Debug("content type not allowed",
+			slog.String("url", rawURL),
+			slog.String("content_type", response.ContentType))
+		callback(ctx, &Result{
+			URL:        parsedURL,
+			StatusCode: response.StatusCode,
+			Response:   response,
+			Error:      ErrContentTypeNotAllowed,
+			Depth:      entry.Depth,
+			ParentURL:  parentURL,
+			FinalURL:   finalURL,
+			Redirects:  response.Redirects,
+		})
+		c.stats.IncrementSkipped()
+		return
+	}
+
+	// Collapse pages onto their declared canonical URL
+	var canonicalURL *url.URL
+	if c.respectCanonical {
+		canonicalURL = c.canonicalURL(pageURL, response)
+		if canonicalURL != nil && canonicalURL.String() != pageURL.String() {
+			c.logger.Debug("non-canonical url",
+				slog.String("url", rawURL),
+				slog.String("canonical", canonicalURL.String()))
+			callback(ctx, &Result{
+				URL:         parsedURL,
+				StatusCode:  response.StatusCode,
+				Response:    response,
+				Depth:       entry.Depth,
+				ParentURL:   parentURL,
+				Duplicate:   true,
+				DuplicateOf: canonicalURL,
+				Canonical:   canonicalURL,
+				FinalURL:    finalURL,
+				Redirects:   response.Redirects,
+			})
+			c.stats.IncrementDuplicates()
+			canonicalLinks := c.filterLinks(ctx, pageURL, []string{canonicalURL.String()}, entry.Seed)
+			if _, err := c.enqueueURLs(ctx, canonicalLinks, parentURL, entry.Depth, entry.Seed); err != nil {
+				c.logger.Warn("failed to enqueue canonical url",
 					slog.String("url", rawURL),
 					slog.String("error", err.Error()))
 			}
+			return
+		}
+	}
+
+	// Skip pages whose content has already been seen
+	if c.deduplicateContent {
+		if original, duplicate := c.checkDuplicate(parsedURL, response); duplicate {
+			c.logger.Debug("duplicate content",
+				slog.String("url", rawURL),
+				slog.String("original", original.String()))
+			callback(ctx, &Result{
+				URL:         parsedURL,
+				StatusCode:  response.StatusCode,
+				Response:    response,
+				Depth:       entry.Depth,
+				ParentURL:   parentURL,
+				Duplicate:   true,
+				DuplicateOf: original,
+				FinalURL:    finalURL,
+				Redirects:   response.Redirects,
+			})
+			c.stats.IncrementDuplicates()
+			return
 		}
 	}
 
+	// Honor the page's robots meta tag
+	var noIndex, noFollow bool
+	if c.respectMetaRobots {
+		noIndex, noFollow = metaRobots(response)
+	}
+
 	// Parse if a parser exists for the domain
 	var parsed any
 	var parseErr error
-	parser, exists := c.getParser(domain)
-	if exists {
+	parser, exists := c.getParser(parsedURL)
+	if exists && !noIndex && !c.fetchOnly {
 		c.logger.Info("parsing with domain parser",
 			slog.String("url", rawURL),
 			slog.String("domain", domain))
 		parsed, parseErr = parser.Parse(ctx, response)
 		if parseErr != nil {
+			c.stats.RecordParseError(rawURL, parseErr)
 			c.logger.Error("failed to parse",
 				slog.String("url", rawURL),
 				slog.String("error", parseErr.Error()))
 		}
 	}
 
-	// Extract URLs from the page
+	// Extract URLs from the page and choose the ones to follow. Links found
+	// at the maximum depth are not followed.
 	var discoveredLinks []string
-	if response.Links != nil {
-		discoveredLinks = c.extractURLs(response.Links, domain)
-	}
-	callback(ctx, &Result{
-		URL:      parsedURL,
-		Parsed:   parsed,
-		Links:    discoveredLinks,
-		Response: response,
-		Error:    parseErr,
-	})
+	var linkDetails []LinkDetail
+	if response.Links != nil && !noFollow {
+		discoveredLinks, linkDetails = c.extractURLs(response.Links, pageURL.Host)
+	}
+	summary.discovered = len(discoveredLinks)
+	var filteredURLs []*url.URL
+	if maxDepth := c.maxDepthFor(entry.Seed); maxDepth <= 0 || entry.Depth < maxDepth {
+		filteredURLs = c.filterLinks(ctx, pageURL, discoveredLinks, entry.Seed)
+		c.markFollowed(linkDetails, response.Links, filteredURLs)
+	}
+	result := &Result{
+		URL:         parsedURL,
+		StatusCode:  response.StatusCode,
+		Parsed:      parsed,
+		Links:       discoveredLinks,
+		Response:    response,
+		Error:       parseErr,
+		Depth:       entry.Depth,
+		ParentURL:   parentURL,
+		Canonical:   canonicalURL,
+		NoIndex:     noIndex,
+		FinalURL:    finalURL,
+		Redirects:   response.Redirects,
+		LinkDetails: linkDetails,
+	}
+	if c.fetchOnly {
+		result.Response = nil
+		result.LinkDetails = nil
+	}
+	callback(ctx, result)
 	c.stats.IncrementSucceeded()
-
-	filteredURLs := c.filterLinks(parsedURL, discoveredLinks)
+	c.stats.IncrementDomainSucceeded(domain)
+	if c.graph != nil {
+		c.recordLinks(parsedURL, discoveredLinks)
+	}
+	if len(filteredURLs) == 0 {
+		return
+	}
 	filteredCount := len(filteredURLs)
-	enqueuedCount, err := c.enqueue(ctx, filteredURLs)
+	enqueuedCount, err := c.enqueueURLs(ctx, filteredURLs, parsedURL, entry.Depth+1, entry.Seed)
+	summary.enqueued = enqueuedCount
 	if err != nil {
 		c.logger.Warn("failed to enqueue discovered urls",
 			slog.String("url", rawURL),
 			slog.String("error", err.Error()))
 	}
 	if enqueuedCount < filteredCount {
-		c.logger.Warn("failed to enqueue all discovered urls",
+		c.logger.Debug("some discovered urls were not enqueued",
 			slog.String("url", rawURL),
 			slog.Int("filtered", filteredCount),
 			slog.Int("enqueued", enqueuedCount))
 	}
 }
 
-func (c *Crawler) getParser(domain string) (Parser, bool) {
-	if parser, exists := c.parsers[domain]; exists {
+// preflight sends a HEAD request for the fetch request and returns an error if
+// the resource should not be downloaded: ErrContentTypeNotAllowed if its
+// content type is not allowed, or fetch.ErrResponseTooLarge if it is larger
+// than MaxResponseBytes and is not to be truncated. The HEAD response, if
+// any, is returned with the error. Other failed HEAD requests return no
+// error, so that the URL is fetched as usual.
+func (c *Crawler) preflight(ctx context.Context, fetcher fetch.Fetcher, req *fetch.Request, host string) (*fetch.Response, error) {
+	head := *req
+	head.Method = http.MethodHead
+	response, err := c.fetch(ctx, fetcher, &head, host)
+	if errors.Is(err, fetch.ErrResponseTooLarge) {
+		return nil, err
+	}
+	if err != nil || response == nil {
+		return nil, nil
+	}
+	if !fetch.ContentTypeAllowed(response.ContentType, c.allowedContentTypes) {
+		return response, ErrContentTypeNotAllowed
+	}
+	if c.maxResponseBytes > 0 && !c.truncateLarge {
+		size, err := strconv.ParseInt(response.GetHeader("Content-Length"), 10, 64)
+		if err == nil && size > c.maxResponseBytes {
+			return response, fmt.Errorf("%w: %d bytes exceeds limit of %d bytes",
+				fetch.ErrResponseTooLarge, size, c.maxResponseBytes)
+		}
+	}
+	return nil, nil
+}
+
+// urlSummary describes how a URL was processed, for Options.LogURLs.
+type urlSummary struct {
+	fetched    bool
+	duration   time.Duration
+	discovered int
+	enqueued   int
+}
+
+// logURL logs the summary of a processed URL at debug level. URLs served from
+// the cache are logged without a fetch duration.
+func (c *Crawler) logURL(ctx context.Context, rawURL string, response *fetch.Response, summary urlSummary) {
+	if !c.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("url", rawURL),
+		slog.Bool("cached", !summary.fetched),
+	}
+	if summary.fetched {
+		attrs = append(attrs, slog.Duration("duration", summary.duration))
+	}
+	if response != nil {
+		attrs = append(attrs,
+			slog.Int("status_code", response.StatusCode),
+			slog.Int("bytes", len(response.HTML)))
+	}
+	attrs = append(attrs,
+		slog.Int("links", summary.discovered),
+		slog.Int("enqueued", summary.enqueued))
+	c.logger.LogAttrs(ctx, slog.LevelDebug, "processed url", attrs...)
+}
+
+// requestHeaders returns the headers to send with requests to the host, or nil
+// if there are none.
+func (c *Crawler) requestHeaders(host string) map[string]string {
+	hostHeaders := c.hostHeaders[host]
+	if len(c.defaultHeaders) == 0 && len(hostHeaders) == 0 && c.userAgent == "" {
+		return nil
+	}
+	headers := make(map[string]string, len(c.defaultHeaders)+len(hostHeaders)+1)
+	// Keys are canonicalized so that overrides apply regardless of case
+	for key, value := range c.defaultHeaders {
+		headers[http.CanonicalHeaderKey(key)] = value
+	}
+	for key, value := range hostHeaders {
+		headers[http.CanonicalHeaderKey(key)] = value
+	}
+	if c.userAgent != "" {
+		headers["User-Agent"] = c.userAgent
+	}
+	return headers
+}
+
+// storeResponse saves the response in the cache.
+func (c *Crawler) storeResponse(ctx context.Context, rawURL string, response *fetch.Response) {
+	value, err := encodeCachedResponse(response)
+	if err == nil {
+		err = cache.SetWithTTL(ctx, c.cache, rawURL, value, c.cacheTTL)
+	}
+	if err == nil {
+		c.stats.IncrementCacheStores()
+	} else {
+		c.logger.Warn("failed to cache response",
+			slog.String("url", rawURL),
+			slog.String("error", err.Error()))
+	}
+}
+
+// storeDeadURL saves a marker in the cache for a URL that failed with a
+// terminal status.
+func (c *Crawler) storeDeadURL(ctx context.Context, rawURL string, statusCode int) {
+	value, err := encodeDeadURL(rawURL, statusCode, time.Now().Add(c.cacheErrorTTL))
+	if err == nil {
+		err = cache.SetWithTTL(ctx, c.cache, rawURL, value, c.cacheErrorTTL)
+	}
+	if err == nil {
+		c.stats.IncrementCacheStores()
+	} else {
+		c.logger.Warn("failed to cache dead url",
+			slog.String("url", rawURL),
+			slog.String("error", err.Error()))
+	}
+}
+
+// canonicalURL returns the normalized canonical URL declared by the page,
+// resolving relative URLs against the page URL. It returns nil if the page
+// does not declare a valid canonical URL.
+func (c *Crawler) canonicalURL(pageURL *url.URL, response *fetch.Response) *url.URL {
+	href := strings.TrimSpace(response.Metadata.CanonicalURL)
+	if href == "" {
+		return nil
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return nil
+	}
+	resolved := pageURL.ResolveReference(ref)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return nil
+	}
+	canonical, err := c.normalizeURL(resolved.String())
+	if err != nil {
+		return nil
+	}
+	return canonical
+}
+
+// checkDuplicate records the hash of the response body and reports whether
+// the same content was already seen, along with the URL it was first seen at.
+// Empty bodies are never considered duplicates.
+func (c *Crawler) checkDuplicate(u *url.URL, response *fetch.Response) (*url.URL, bool) {
+	body := strings.TrimSpace(response.HTML)
+	if body == "" {
+		return nil, false
+	}
+	hash := sha256.Sum256([]byte(body))
+	original, exists := c.contentHashes.LoadOrStore(hash, u)
+	if !exists {
+		return nil, false
+	}
+	return original.(*url.URL), true
+}
+
+// robotsAllowed checks the URL against the robots.txt rules for its host,
+// returning ErrRobotsDisallowed if they disallow it, or ErrRobotsUnavailable
+// if they could not be loaded. The host's Crawl-delay, if any, is applied to
+// its rate limiter.
+func (c *Crawler) robotsAllowed(ctx context.Context, u *url.URL) error {
+	rules, err := c.robots.Get(ctx, u)
+	if err != nil {
+		c.logger.Warn("failed to load robots.txt",
+			slog.String("host", u.Host),
+			slog.String("error", err.Error()))
+		return err
+	}
+	if rules == nil {
+		return nil
+	}
+	if delay := rules.CrawlDelay(c.userAgent); delay > 0 {
+		c.hostLimiter.SetMinInterval(u.Host, delay)
+	}
+	if !rules.Allowed(c.userAgent, u) {
+		return ErrRobotsDisallowed
+	}
+	return nil
+}
+
+// getParser returns the parser for the URL. The first matching rule in
+// Options.ParserRules applies, then the parser for the URL's host in
+// Options.Parsers, and then the default parser.
+func (c *Crawler) getParser(u *url.URL) (Parser, bool) {
+	for _, rule := range c.parserRules {
+		if rule.matches(u) {
+			return rule.Parser, rule.Parser != nil
+		}
+	}
+	if parser, exists := c.parsers[u.Hostname()]; exists {
 		return parser, true
 	}
 	if c.defaultParser != nil {
@@ -346,48 +1958,304 @@ func (c *Crawler) getParser(domain string) (Parser, bool) {
 	return nil, false
 }
 
-func (c *Crawler) filterLinks(pageURL *url.URL, links []string) []string {
-	if c.followBehavior == FollowNone {
-		return nil
+// normalizeURL normalizes a URL with Options.Normalizer if one is set, or
+// according to Options.Normalize otherwise, and then applies
+// Options.RewriteURL.
+func (c *Crawler) normalizeURL(rawURL string) (*url.URL, error) {
+	u, err := c.normalizeRawURL(rawURL)
+	if err != nil || c.rewriteURL == nil {
+		return u, err
+	}
+	if u = c.rewriteURL(u); u == nil {
+		return nil, ErrURLDropped
+	}
+	return u, nil
+}
+
+// normalizeRawURL normalizes a URL without rewriting it.
+func (c *Crawler) normalizeRawURL(rawURL string) (*url.URL, error) {
+	if c.normalizer == nil {
+		return web.NormalizeURLWith(rawURL, c.normalize)
+	}
+	normalized, err := c.normalizer(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("invalid normalized url %q: %w", normalized, err)
+	}
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("invalid normalized url %q: not absolute", normalized)
 	}
-	var filtered []string
+	return u, nil
+}
+
+// recordLinks adds the links found on a page to the link graph.
+func (c *Crawler) recordLinks(pageURL *url.URL, links []string) {
 	for _, rawURL := range links {
-		u, err := web.NormalizeURL(rawURL)
+		if u, err := c.normalizeURL(rawURL); err == nil {
+			c.graph.AddEdge(pageURL.String(), u.String())
+		}
+	}
+}
+
+// followsRedirect reports whether the follow behavior for the seed's scope
+// allows a redirect from the page URL to u. Redirects within the page's host
+// are always followed, including with FollowNone.
+func (c *Crawler) followsRedirect(pageURL, u *url.URL, seed *SeedConfig) bool {
+	if c.isAllowedDomain(u) {
+		return true
+	}
+	switch c.followBehaviorFor(seed) {
+	case FollowAny:
+		return true
+	case FollowRelatedSubdomains:
+		return web.AreRelatedHosts(u, pageURL)
+	default:
+		return c.isSameHost(u, pageURL)
+	}
+}
+
+// isSameHost reports whether the URLs have the same host, ignoring a leading
+// "www." when Options.IgnoreWWW is set.
+func (c *Crawler) isSameHost(u, pageURL *url.URL) bool {
+	if c.ignoreWWW {
+		return web.AreSameHostIgnoringWWW(u, pageURL)
+	}
+	return web.AreSameHost(u, pageURL)
+}
+
+// filterLinks returns the links found on a page that should be followed,
+// using the scope of the seed the page was reached from. The links must
+// already be normalized, as extractURLs does, so that they are only parsed
+// here. Links that were already queued are skipped before the other checks.
+func (c *Crawler) filterLinks(ctx context.Context, pageURL *url.URL, links []string, seed *SeedConfig) []*url.URL {
+	followBehavior := c.followBehaviorFor(seed)
+	if followBehavior == FollowNone {
+		return nil
+	}
+	var filtered []*url.URL
+	for _, link := range links {
+		if c.processedURLs.Contains(link) {
+			continue
+		}
+		u, err := url.Parse(link)
 		if err != nil {
 			continue
 		}
-		switch c.followBehavior {
+		var follow bool
+		switch followBehavior {
 		case FollowAny:
-			filtered = append(filtered, rawURL)
+			follow = true
 		case FollowSameDomain:
-			if web.AreSameHost(u, pageURL) {
-				filtered = append(filtered, rawURL)
-			}
+			follow = c.isSameHost(u, pageURL)
 		case FollowRelatedSubdomains:
-			if web.AreRelatedHosts(u, pageURL) {
-				filtered = append(filtered, rawURL)
-			}
+			follow = web.AreRelatedHosts(u, pageURL)
 		}
+		if !(follow || c.isAllowedDomain(u)) || !c.allowsLink(u, seed) {
+			continue
+		}
+		if c.shouldFollow != nil && !c.shouldFollow(ctx, pageURL, u.String()) {
+			continue
+		}
+		filtered = append(filtered, u)
 	}
 	return filtered
 }
 
-func (c *Crawler) extractURLs(links []*fetch.Link, domain string) []string {
+// redirectsTo reports whether the redirects recorded by processURL lead from
+// one URL to another, following chains of redirects.
+func (c *Crawler) redirectsTo(from, to string) bool {
+	seen := map[string]bool{}
+	for !seen[from] {
+		seen[from] = true
+		next, ok := c.redirectedTo.Load(from)
+		if !ok {
+			return false
+		}
+		from = next.(string)
+		if from == to {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowedDomain reports whether the URL is on one of the domains in
+// Options.AllowedDomains, or a subdomain of one.
+func (c *Crawler) isAllowedDomain(u *url.URL) bool {
+	for _, domain := range c.allowedDomains {
+		if matchesDomainPattern(u, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlockedDomain reports whether the URL is on one of the domains in
+// Options.BlockedDomains.
+func (c *Crawler) isBlockedDomain(u *url.URL) bool {
+	for _, domain := range c.blockedDomains {
+		if matchesDomainPattern(u, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesDomainPattern reports whether the URL's host matches a domain
+// pattern. A pattern of the form "*.example.com" matches only the subdomains
+// of example.com, and any other pattern matches the domain and its subdomains.
+func matchesDomainPattern(u *url.URL, pattern string) bool {
+	if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(strings.ToLower(u.Hostname()), "."+domain)
+	}
+	return matchesDomain(u, pattern)
+}
+
+// normalizeDomains returns the hostnames of the domains, which may be given
+// as hostnames or URLs, normalized as links are. A leading "*." wildcard is
+// kept. Invalid entries are ignored.
+func normalizeDomains(domains []string, opts web.NormalizeOptions) []string {
+	var hostnames []string
+	for _, domain := range domains {
+		domain, wildcard := strings.CutPrefix(strings.TrimSpace(domain), "*.")
+		u, err := web.NormalizeURLWith(domain, opts)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		hostname := strings.ToLower(u.Hostname())
+		if wildcard {
+			hostname = "*." + hostname
+		}
+		hostnames = append(hostnames, hostname)
+	}
+	return hostnames
+}
+
+// allowsLink reports whether the URL passes the link filters that apply in
+// addition to the follow behavior.
+func (c *Crawler) allowsLink(u *url.URL, seed *SeedConfig) bool {
+	return c.hasAllowedPath(u, seed) && !c.hasExcludedExtension(u) &&
+		c.isIncluded(u.String()) && !c.isExcluded(u.String())
+}
+
+// hasAllowedPath returns true if the URL's path begins with one of the seed's
+// path prefixes. For seeds without path prefixes, it returns true if the
+// URL's host has no path prefixes configured or its path begins with one of
+// them.
+func (c *Crawler) hasAllowedPath(u *url.URL, seed *SeedConfig) bool {
+	if seed != nil && len(seed.PathPrefixes) > 0 {
+		return hasPathPrefix(u.Path, seed.PathPrefixes)
+	}
+	prefixes, ok := c.pathPrefixes[u.Hostname()]
+	if !ok {
+		return true
+	}
+	return hasPathPrefix(u.Path, prefixes)
+}
+
+// hasExcludedExtension returns true if the URL's path ends with one of the
+// excluded file extensions.
+func (c *Crawler) hasExcludedExtension(u *url.URL) bool {
+	if len(c.excludeExtensions) == 0 {
+		return false
+	}
+	return c.excludeExtensions[strings.ToLower(path.Ext(u.Path))]
+}
+
+// extensionSet returns the lowercased extensions, each with a leading dot.
+func extensionSet(extensions []string) map[string]bool {
+	set := make(map[string]bool, len(extensions))
+	for _, ext := range extensions {
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		set[strings.ToLower(ext)] = true
+	}
+	return set
+}
+
+// isIncluded returns true if no include patterns are configured or the URL
+// matches at least one of them.
+func (c *Crawler) isIncluded(value string) bool {
+	if len(c.includePatterns) == 0 {
+		return true
+	}
+	for _, pattern := range c.includePatterns {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcluded returns true if the URL matches any exclude pattern.
+func (c *Crawler) isExcluded(value string) bool {
+	for _, pattern := range c.excludePatterns {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractURLs resolves the links found on a page of the given domain. It
+// returns the distinct URLs that may be followed, sorted, along with the
+// details of every link in page order.
+func (c *Crawler) extractURLs(links []*fetch.Link, domain string) ([]string, []LinkDetail) {
 	urlMap := make(map[string]bool)
-	for _, link := range links {
-		if url, ok := ResolveLink(domain, link.URL); ok {
-			urlMap[url] = true
+	details := make([]LinkDetail, len(links))
+	for i, link := range links {
+		details[i].Raw = link.URL
+		url, ok := resolveLink(domain, link.URL, c.normalizeURL)
+		if !ok {
+			continue
 		}
+		details[i].Resolved = url
+		if c.respectNofollow && (*web.Link)(link).HasRel("nofollow") {
+			continue
+		}
+		urlMap[url] = true
 	}
 	var results []string
 	for url := range urlMap {
 		results = append(results, url)
 	}
 	sort.Strings(results)
-	return results
+	return results, details
+}
+
+// markFollowed marks the details of the links that were filtered for
+// following as followed, along with those for links that had already been
+// queued. The details must be in the same order as the links. Links marked
+// nofollow are never followed.
+func (c *Crawler) markFollowed(details []LinkDetail, links []*fetch.Link, filtered []*url.URL) {
+	followed := make(map[string]bool, len(filtered))
+	for _, u := range filtered {
+		followed[u.String()] = true
+	}
+	for i := range details {
+		resolved := details[i].Resolved
+		if resolved == "" || c.respectNofollow && (*web.Link)(links[i]).HasRel("nofollow") {
+			continue
+		}
+		details[i].Followed = followed[resolved] || c.processedURLs.Contains(resolved)
+	}
 }
 
+// ResolveLink resolves a link found on a page of the given domain to a
+// normalized absolute URL. It reports false for invalid links and links with
+// schemes other than http and https.
 func ResolveLink(domain, value string) (string, bool) {
+	return resolveLink(domain, value, web.NormalizeURL)
+}
+
+func resolveLink(domain, value string, normalize func(string) (*url.URL, error)) (string, bool) {
 	// Parse the input URL
 	parsedURL, err := url.Parse(value)
 	if err != nil {
@@ -404,7 +2272,7 @@ func ResolveLink(domain, value string) (string, bool) {
 			return "", false
 		}
 		// Normalize and return
-		normalizedURL, err := web.NormalizeURL(parsedURL.String())
+		normalizedURL, err := normalize(parsedURL.String())
 		if err != nil {
 			return "", false
 		}
@@ -428,47 +2296,133 @@ func ResolveLink(domain, value string) (string, bool) {
 	resolvedURL := baseURL.ResolveReference(parsedURL)
 
 	// Normalize and return
-	normalizedURL, err := web.NormalizeURL(resolvedURL.String())
+	normalizedURL, err := normalize(resolvedURL.String())
 	if err != nil {
 		return "", false
 	}
 	return normalizedURL.String(), true
 }
 
+// progressReporter periodically logs the crawl statistics along with the
+// rate pages were processed at since the previous report, the queue length,
+// the number of active workers and, when MaxURLs is set, an estimate of the
+// time remaining at that rate.
 func (c *Crawler) progressReporter(ctx context.Context) {
 	ticker := time.NewTicker(c.showProgressInterval)
 	defer ticker.Stop()
+	lastProcessed := c.stats.GetProcessed()
+	lastTick := time.Now()
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			c.logger.Info("crawl progress",
-				slog.Int64("processed", c.stats.GetProcessed()),
-				slog.Int64("succeeded", c.stats.GetSucceeded()),
-				slog.Int64("failed", c.stats.GetFailed()))
+		case now := <-ticker.C:
+			stats := c.stats.Snapshot()
+			rate := pageRate(stats.Processed-lastProcessed, now.Sub(lastTick))
+			lastProcessed, lastTick = stats.Processed, now
+			attrs := []slog.Attr{
+				slog.Float64("pages_per_second", rate),
+				slog.Int("queue_length", c.QueueLen()),
+				slog.Int64("active_workers", c.InFlight()),
+			}
+			if c.maxURLs > 0 {
+				if eta, ok := estimateRemaining(int64(c.maxURLs)-stats.Processed, rate); ok {
+					attrs = append(attrs, slog.Duration("eta", eta))
+				}
+			}
+			c.logger.LogAttrs(ctx, slog.LevelInfo, "crawl progress", append(attrs,
+				slog.Int64("processed", stats.Processed),
+				slog.Int64("succeeded", stats.Succeeded),
+				slog.Int64("failed", stats.Failed),
+				slog.Int64("skipped", stats.Skipped),
+				slog.Int64("dropped", stats.Dropped),
+				slog.Int64("domain_limited", stats.DomainLimited),
+				slog.Int64("duplicates", stats.Duplicates),
+				slog.Int64("retried", stats.Retried),
+				slog.Int64("oversized", stats.Oversized),
+				slog.Int64("not_modified", stats.NotModified),
+				slog.Int64("traps", stats.Traps),
+				slog.Int64("blocked", stats.Blocked),
+				slog.Int64("robots_denied", stats.RobotsDenied),
+				slog.Int64("cancelled", stats.Cancelled),
+				slog.Int64("cache_hits", stats.CacheHits),
+				slog.Int64("cache_misses", stats.CacheMisses),
+				slog.Int64("cache_stores", stats.CacheStores),
+				slog.Int64("bytes_downloaded", stats.BytesDownloaded),
+				slog.Duration("latency_mean", stats.Latency.Mean),
+				slog.Duration("latency_p95", stats.Latency.P95))...)
 		}
 	}
 }
 
+// pageRate returns the number of pages processed per second over the elapsed
+// time.
+func pageRate(pages int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(pages) / elapsed.Seconds()
+}
+
+// estimateRemaining returns the time needed to process the remaining pages at
+// the given rate, rounded to the second. It returns false if the rate is too
+// low to make an estimate.
+func estimateRemaining(remaining int64, rate float64) (time.Duration, bool) {
+	if remaining <= 0 {
+		return 0, true
+	}
+	if rate <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(remaining) / rate * float64(time.Second)).Round(time.Second), true
+}
+
+// Graph returns the links recorded between pages, or nil if
+// Options.RecordGraph is not set. The graph may be read while the crawl runs.
+func (c *Crawler) Graph() *LinkGraph {
+	return c.graph
+}
+
 // GetStats returns the current crawling statistics
 func (c *Crawler) GetStats() *CrawlerStats {
 	return c.stats
 }
 
+// QueueLen returns the number of URLs waiting in the frontier. It is safe to
+// call while the crawl runs, for example to monitor its progress.
+func (c *Crawler) QueueLen() int {
+	c.mutex.Lock()
+	frontier := c.frontier
+	c.mutex.Unlock()
+	return frontier.Len()
+}
+
+// InFlight returns the number of URLs currently being processed by workers.
+// It is safe to call while the crawl runs.
+func (c *Crawler) InFlight() int64 {
+	return atomic.LoadInt64(&c.active)
+}
+
 func (c *Crawler) idleMonitor(ctx context.Context, cancel context.CancelFunc) {
-	// Check every second for idle state
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := time.NewTicker(idleCheckInterval)
 	defer ticker.Stop()
 
+	// Require the crawl to be idle on consecutive checks, in case work is
+	// being added to the frontier from outside the crawler
+	var idleChecks int
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			// Check if we're idle: no active workers and queue is empty
-			if c.getActiveWorkers() == 0 && len(c.queue) == 0 {
+			if c.Paused() || !c.isDone() {
+				idleChecks = 0
+				continue
+			}
+			idleChecks++
+			if idleChecks >= idleCheckCount {
 				c.logger.Info("no more work available, stopping crawler")
+				c.stats.SetStopReason(ReasonIdle)
 				cancel() // Cancel context to stop all workers
 				return
 			}
@@ -1,15 +1,28 @@
 package crawler
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/myzie/web"
 	"github.com/myzie/web/cache"
+	weberrors "github.com/myzie/web/errors"
 	"github.com/myzie/web/fetch"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -166,6 +179,7 @@ func TestCrawler_BasicCrawl(t *testing.T) {
 
 	stats := crawler.GetStats()
 	assert.Greater(t, stats.GetProcessed(), int64(0))
+	assert.Equal(t, ReasonIdle, stats.GetStopReason())
 }
 
 func TestCrawler_WithParser(t *testing.T) {
@@ -533,4 +547,2565 @@ func TestCrawler_MaxURLsLimit(t *testing.T) {
 
 	stats := crawler.GetStats()
 	assert.LessOrEqual(t, stats.GetProcessed(), int64(3))
+	assert.Equal(t, ReasonMaxURLs, stats.GetStopReason())
+}
+
+func TestCrawler_MaxDepth(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+
+	// A chain of pages: / -> /1 -> /2 -> /3
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL:   "https://example.com",
+		Links: []*fetch.Link{{URL: "/1"}},
+	})
+	for i := 1; i <= 3; i++ {
+		url := fmt.Sprintf("https://example.com/%d", i)
+		mockFetcher.AddResponse(url, &fetch.Response{
+			URL:   url,
+			Links: []*fetch.Link{{URL: fmt.Sprintf("/%d", i+1)}},
+		})
+	}
+
+	crawler := New(Options{
+		MaxURLs:        10,
+		MaxDepth:       2,
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+	})
+
+	depths := map[string]int{}
+	parents := map[string]string{}
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		depths[result.URL.String()] = result.Depth
+		if result.ParentURL != nil {
+			parents[result.URL.String()] = result.ParentURL.String()
+		}
+	}
+
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, callback)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int{
+		"https://example.com":   0,
+		"https://example.com/1": 1,
+		"https://example.com/2": 2,
+	}, depths)
+	assert.Equal(t, map[string]string{
+		"https://example.com/1": "https://example.com",
+		"https://example.com/2": "https://example.com/1",
+	}, parents)
+}
+
+func TestCrawler_CrawlChan(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL:   "https://example.com",
+		Links: []*fetch.Link{{URL: "/1"}, {URL: "/2"}},
+	})
+	mockFetcher.AddResponse("https://example.com/1", &fetch.Response{
+		URL: "https://example.com/1",
+	})
+	mockFetcher.AddError("https://example.com/2", fmt.Errorf("boom"))
+
+	crawler := New(Options{
+		MaxURLs:        10,
+		Workers:        2,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+	})
+
+	results, err := crawler.CrawlChan(context.Background(), []string{"https://example.com"})
+	require.NoError(t, err)
+
+	// The crawler cannot be started again while results are being read
+	_, err = crawler.CrawlChan(context.Background(), nil)
+	require.Error(t, err)
+
+	failed := map[string]bool{}
+	for result := range results {
+		failed[result.URL.String()] = result.Error != nil
+	}
+	assert.Equal(t, map[string]bool{
+		"https://example.com":   false,
+		"https://example.com/1": false,
+		"https://example.com/2": true,
+	}, failed)
+}
+
+func TestCrawler_MaxURLsPerDomain(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	var links []*fetch.Link
+	for i := 1; i <= 5; i++ {
+		url := fmt.Sprintf("https://a.com/%d", i)
+		links = append(links, &fetch.Link{URL: url})
+		mockFetcher.AddResponse(url, &fetch.Response{URL: url})
+	}
+	links = append(links, &fetch.Link{URL: "https://b.com/1"})
+	mockFetcher.AddResponse("https://a.com", &fetch.Response{
+		URL:   "https://a.com",
+		Links: links,
+	})
+	mockFetcher.AddResponse("https://b.com", &fetch.Response{URL: "https://b.com"})
+	mockFetcher.AddResponse("https://b.com/1", &fetch.Response{URL: "https://b.com/1"})
+
+	crawler := New(Options{
+		MaxURLs:          20,
+		MaxURLsPerDomain: 3,
+		Workers:          1,
+		Fetcher:          mockFetcher,
+		FollowBehavior:   FollowAny,
+	})
+
+	counts := map[string]int{}
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[result.URL.Host]++
+	}
+
+	err := crawler.Crawl(context.Background(), []string{"https://a.com", "https://b.com"}, callback)
+	require.NoError(t, err)
+
+	// Seeds count against the limit, and other hosts are unaffected
+	assert.Equal(t, map[string]int{"a.com": 3, "b.com": 2}, counts)
+	assert.Equal(t, int64(3), crawler.GetStats().GetDomainLimited())
+}
+
+func TestCrawler_DomainStats(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://a.com", &fetch.Response{
+		URL:   "https://a.com",
+		HTML:  "<p>hello</p>",
+		Links: []*fetch.Link{{URL: "/1"}, {URL: "https://b.com"}},
+	})
+	mockFetcher.AddError("https://a.com/1", fmt.Errorf("boom"))
+	mockFetcher.AddResponse("https://b.com", &fetch.Response{
+		URL:  "https://b.com",
+		HTML: "<p>hi</p>",
+	})
+
+	crawler := New(Options{
+		MaxURLs:        10,
+		Workers:        2,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowAny,
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://a.com"}, func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+
+	byDomain := crawler.GetStats().ByDomain()
+	assert.Equal(t, map[string]DomainStats{
+		"a.com": {Processed: 2, Succeeded: 1, Failed: 1, Bytes: 12},
+		"b.com": {Processed: 1, Succeeded: 1, Bytes: 9},
+	}, byDomain)
+	assert.Equal(t, "processed=1 succeeded=1 failed=0 bytes=9", byDomain["b.com"].String())
+
+	stats := crawler.GetStats().Snapshot()
+	assert.Equal(t, int64(21), stats.BytesDownloaded)
+	assert.Equal(t, int64(3), stats.Latency.Count)
+}
+
+func TestCrawler_NormalizeQuery(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com/list", &fetch.Response{
+		URL: "https://example.com/list",
+		Links: []*fetch.Link{
+			{URL: "/list?utm_source=newsletter"},
+			{URL: "/list?page=2&utm_source=newsletter"},
+			{URL: "/list?page=2&fbclid=abc"},
+		},
+	})
+	mockFetcher.AddResponse("https://example.com/list?page=2", &fetch.Response{
+		URL: "https://example.com/list?page=2",
+	})
+
+	crawler := New(Options{
+		MaxURLs:        10,
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		Normalize:      web.NormalizeOptions{KeepQuery: true},
+	})
+
+	var visited []string
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		visited = append(visited, result.URL.String())
+	}
+
+	err := crawler.Crawl(context.Background(), []string{"https://example.com/list?utm_medium=email"}, callback)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"https://example.com/list",
+		"https://example.com/list?page=2",
+	}, visited)
+}
+
+func TestCrawler_MockFetcherRequests(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/a", "/b", "mailto:test@example.com")
+	mockFetcher.AddPage("https://example.com/a")
+	mockFetcher.AddStatus("https://example.com/b", 503)
+
+	crawler := New(Options{
+		MaxURLs:        10,
+		MaxRetries:     1,
+		RetryBackoff:   time.Millisecond,
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+
+	// The failing page is retried once
+	assert.Equal(t, []string{
+		"https://example.com",
+		"https://example.com/a",
+		"https://example.com/b",
+		"https://example.com/b",
+	}, mockFetcher.RequestedURLs())
+	assert.Equal(t, "http", mockFetcher.Requests()[0].Fetcher)
+}
+
+func TestCrawler_Deterministic(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/c", "/a", "/b")
+	mockFetcher.AddPage("https://example.com/a", "/a/2", "/a/1")
+	mockFetcher.AddPage("https://example.com/b", "/a/1", "/b/1")
+	mockFetcher.AddPage("https://example.com/c")
+	mockFetcher.AddPage("https://example.com/a/1")
+	mockFetcher.AddPage("https://example.com/a/2")
+	mockFetcher.AddPage("https://example.com/b/1")
+	mockFetcher.AddPage("https://example.com/other")
+
+	for i := 0; i < 3; i++ {
+		crawler := New(Options{
+			MaxURLs:        20,
+			Workers:        4,
+			Fetcher:        mockFetcher,
+			FollowBehavior: FollowSameDomain,
+			Deterministic:  true,
+		})
+
+		var visited []string
+		callback := func(ctx context.Context, result *Result) {
+			visited = append(visited, result.URL.String())
+		}
+		seeds := []string{"https://example.com", "https://example.com/other"}
+		err := crawler.Crawl(context.Background(), seeds, callback)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"https://example.com",
+			"https://example.com/other",
+			"https://example.com/a",
+			"https://example.com/b",
+			"https://example.com/c",
+			"https://example.com/a/1",
+			"https://example.com/a/2",
+			"https://example.com/b/1",
+		}, visited)
+	}
+}
+
+func TestCrawler_RequestHeaders(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "https://internal.example.com")
+	mockFetcher.AddPage("https://internal.example.com")
+
+	crawler := New(Options{
+		MaxURLs:        10,
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowAny,
+		UserAgent:      "testbot",
+		DefaultHeaders: map[string]string{
+			"accept-language": "en",
+			"user-agent":      "ignored",
+		},
+		HostHeaders: map[string]map[string]string{
+			"internal.example.com": {"Authorization": "Bearer token"},
+		},
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+
+	requests := mockFetcher.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, map[string]string{
+		"Accept-Language": "en",
+		"User-Agent":      "testbot",
+	}, requests[0].Headers)
+	assert.Equal(t, map[string]string{
+		"Accept-Language": "en",
+		"Authorization":   "Bearer token",
+		"User-Agent":      "testbot",
+	}, requests[1].Headers)
+}
+
+func TestCrawler_ErrorStatus(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/missing")
+	mockFetcher.AddStatus("https://example.com/missing", 404)
+	memoryCache := cache.NewInMemoryCache()
+
+	crawler := New(Options{
+		MaxURLs:        10,
+		Workers:        1,
+		Cache:          memoryCache,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		DefaultParser:  NewMockParser(),
+	})
+
+	results := map[string]*Result{}
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.URL.String()] = result
+	}
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, callback)
+	require.NoError(t, err)
+
+	assert.Equal(t, 200, results["https://example.com"].StatusCode)
+
+	// Error pages are reported as failures without being parsed or cached
+	missing := results["https://example.com/missing"]
+	require.Error(t, missing.Error)
+	assert.Equal(t, 404, missing.StatusCode)
+	assert.Nil(t, missing.Parsed)
+	_, err = memoryCache.Get(context.Background(), "https://example.com/missing")
+	assert.True(t, cache.IsNotFound(err))
+	assert.Equal(t, int64(1), crawler.GetStats().GetFailed())
+	assert.Equal(t, int64(0), crawler.GetStats().GetRetried())
+}
+
+func TestCrawler_AllowedContentTypes(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/report.pdf", "/page")
+	mockFetcher.AddResponse("https://example.com/report.pdf", &fetch.Response{
+		URL:         "https://example.com/report.pdf",
+		StatusCode:  200,
+		ContentType: "application/pdf",
+		Links:       []*fetch.Link{{URL: "/hidden"}},
+	})
+	page := fetch.NewMockResponse("https://example.com/page")
+	page.ContentType = "application/xhtml+xml; charset=utf-8"
+	mockFetcher.AddResponse("https://example.com/page", page)
+
+	crawler := New(Options{
+		MaxURLs:        10,
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		DefaultParser:  NewMockParser(),
+	})
+
+	results := map[string]*Result{}
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.URL.String()] = result
+	}
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, callback)
+	require.NoError(t, err)
+
+	require.Len(t, results, 3)
+	pdf := results["https://example.com/report.pdf"]
+	assert.ErrorIs(t, pdf.Error, ErrContentTypeNotAllowed)
+	assert.Nil(t, pdf.Parsed)
+	assert.NotNil(t, results["https://example.com/page"].Parsed)
+	assert.Equal(t, int64(1), crawler.GetStats().GetSkipped())
+}
+
+func TestCrawler_Stop(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+
+	// The home page links to many pages, which in turn link back home
+	var links []*fetch.Link
+	for i := 0; i < 50; i++ {
+		url := fmt.Sprintf("https://example.com/%d", i)
+		links = append(links, &fetch.Link{URL: url})
+		mockFetcher.AddResponse(url, &fetch.Response{URL: url})
+	}
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL:   "https://example.com",
+		Links: links,
+	})
+
+	crawler := New(Options{
+		Workers:        2,
+		RequestDelay:   time.Millisecond * 10,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+	})
+
+	var count int64
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		count++
+		if count == 3 {
+			crawler.Stop()
+			crawler.Stop() // Repeated calls are a no-op
+		}
+	}
+
+	start := time.Now()
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, callback)
+	require.NoError(t, err)
+
+	// Crawl returns promptly, without waiting for the idle monitor
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Less(t, crawler.GetStats().GetProcessed(), int64(51))
+	assert.Equal(t, ReasonStopped, crawler.GetStats().GetStopReason())
+
+	// Stopping a crawler that is not running has no effect
+	crawler.Stop()
+}
+
+func TestCrawler_ContextCancelled(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	for i := 0; i < 50; i++ {
+		mockFetcher.AddPage(fmt.Sprintf("https://example.com/%d", i), fmt.Sprintf("/%d", i+1))
+	}
+
+	crawler := New(Options{
+		Workers:        1,
+		RequestDelay:   10 * time.Millisecond,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := crawler.Crawl(ctx, []string{"https://example.com/0"},
+		func(ctx context.Context, result *Result) {
+			if result.URL.Path == "/2" {
+				cancel()
+			}
+		})
+	require.NoError(t, err)
+	assert.Less(t, crawler.GetStats().GetProcessed(), int64(50))
+	assert.Equal(t, ReasonContextCancelled, crawler.GetStats().GetStopReason())
+}
+
+func TestCrawler_QueueFullRediscovery(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+
+	// The home page links to more pages than fit in the queue. The pages that
+	// are dropped are linked to again from the pages that were queued.
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL:   "https://example.com",
+		Links: []*fetch.Link{{URL: "/1"}, {URL: "/2"}, {URL: "/3"}, {URL: "/4"}},
+	})
+	mockFetcher.AddResponse("https://example.com/1", &fetch.Response{
+		URL:   "https://example.com/1",
+		Links: []*fetch.Link{{URL: "/3"}},
+	})
+	mockFetcher.AddResponse("https://example.com/2", &fetch.Response{
+		URL:   "https://example.com/2",
+		Links: []*fetch.Link{{URL: "/4"}},
+	})
+	mockFetcher.AddResponse("https://example.com/3", &fetch.Response{URL: "https://example.com/3"})
+	mockFetcher.AddResponse("https://example.com/4", &fetch.Response{URL: "https://example.com/4"})
+
+	crawler := New(Options{
+		Workers:        1,
+		QueueSize:      2,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+	})
+
+	var processedURLs []string
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		processedURLs = append(processedURLs, result.URL.String())
+	}
+
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, callback)
+	require.NoError(t, err)
+
+	assert.Len(t, processedURLs, 5)
+	assert.Equal(t, int64(2), crawler.GetStats().GetDropped())
+}
+
+// recordingFetcher wraps a Fetcher and records when each URL was fetched.
+type recordingFetcher struct {
+	fetch.Fetcher
+	mutex sync.Mutex
+	times map[string]time.Time
+}
+
+func (r *recordingFetcher) Fetch(ctx context.Context, req *fetch.Request) (*fetch.Response, error) {
+	r.mutex.Lock()
+	r.times[req.URL] = time.Now()
+	r.mutex.Unlock()
+	return r.Fetcher.Fetch(ctx, req)
+}
+
+func TestCrawler_PerHostDelay(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	urls := []string{
+		"https://a.com/1",
+		"https://a.com/2",
+		"https://a.com/3",
+		"https://b.com/1",
+		"https://c.com/1",
+	}
+	for _, url := range urls {
+		mockFetcher.AddResponse(url, &fetch.Response{URL: url})
+	}
+	fetcher := &recordingFetcher{Fetcher: mockFetcher, times: map[string]time.Time{}}
+
+	crawler := New(Options{
+		Workers:        5,
+		Fetcher:        fetcher,
+		FollowBehavior: FollowNone,
+		PerHostDelay:   100 * time.Millisecond,
+	})
+
+	start := time.Now()
+	err := crawler.Crawl(context.Background(), urls, func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+	require.Len(t, fetcher.times, 5)
+
+	// Requests to the same host are spaced out
+	var hostA []time.Time
+	for _, url := range urls[:3] {
+		hostA = append(hostA, fetcher.times[url])
+	}
+	sort.Slice(hostA, func(i, j int) bool { return hostA[i].Before(hostA[j]) })
+	assert.GreaterOrEqual(t, hostA[2].Sub(hostA[0]), 190*time.Millisecond)
+
+	// Other hosts are not held up
+	assert.Less(t, fetcher.times["https://b.com/1"].Sub(start), 50*time.Millisecond)
+	assert.Less(t, fetcher.times["https://c.com/1"].Sub(start), 50*time.Millisecond)
+}
+
+func TestCrawler_GlobalRPS(t *testing.T) {
+	requestTimes := func(t *testing.T, opts Options, urls []string) []time.Time {
+		mockFetcher := fetch.NewMockFetcher()
+		for _, url := range urls {
+			mockFetcher.AddResponse(url, &fetch.Response{URL: url})
+		}
+		fetcher := &recordingFetcher{Fetcher: mockFetcher, times: map[string]time.Time{}}
+		opts.Fetcher = fetcher
+		opts.FollowBehavior = FollowNone
+		err := New(opts).Crawl(context.Background(), urls, func(ctx context.Context, result *Result) {})
+		require.NoError(t, err)
+		require.Len(t, fetcher.times, len(urls))
+		var times []time.Time
+		for _, t := range fetcher.times {
+			times = append(times, t)
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+		return times
+	}
+
+	t.Run("aggregate rate", func(t *testing.T) {
+		var urls []string
+		for i := range 21 {
+			urls = append(urls, fmt.Sprintf("https://host%d.com/%d", i%5, i))
+		}
+		times := requestTimes(t, Options{Workers: 8, GlobalRPS: 50}, urls)
+
+		// 21 requests at 50 per second take at least 400ms across all hosts
+		elapsed := times[len(times)-1].Sub(times[0])
+		assert.GreaterOrEqual(t, elapsed, 390*time.Millisecond)
+		rate := float64(len(times)-1) / elapsed.Seconds()
+		assert.LessOrEqual(t, rate, 50*1.05)
+	})
+
+	t.Run("per-host limit is more restrictive", func(t *testing.T) {
+		urls := []string{"https://a.com/1", "https://a.com/2", "https://a.com/3"}
+		times := requestTimes(t, Options{
+			Workers:      3,
+			GlobalRPS:    100,
+			PerHostDelay: 100 * time.Millisecond,
+		}, urls)
+		assert.GreaterOrEqual(t, times[2].Sub(times[0]), 190*time.Millisecond)
+	})
+}
+
+func TestJittered(t *testing.T) {
+	assert.Equal(t, time.Second, jittered(time.Second, 0))
+	seen := map[time.Duration]bool{}
+	for range 100 {
+		delay := jittered(time.Second, 500*time.Millisecond)
+		assert.GreaterOrEqual(t, delay, time.Second)
+		assert.LessOrEqual(t, delay, 1500*time.Millisecond)
+		seen[delay] = true
+	}
+	assert.Greater(t, len(seen), 1)
+}
+
+func TestRateLimiter_Jitter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Each Wait reserves the next slot, which is spaced by the interval plus
+	// up to the jitter
+	limiter := newHostLimiter(time.Second, 500*time.Millisecond).get("example.com")
+	var slots []time.Time
+	for range 20 {
+		limiter.Wait(ctx)
+		slots = append(slots, limiter.next)
+	}
+	for i := 1; i < len(slots); i++ {
+		gap := slots[i].Sub(slots[i-1])
+		assert.GreaterOrEqual(t, gap, time.Second)
+		assert.LessOrEqual(t, gap, 1500*time.Millisecond)
+	}
+
+	// Without an interval the jitter is not applied
+	limiter = newHostLimiter(0, 500*time.Millisecond).get("example.com")
+	require.NoError(t, limiter.Wait(ctx))
+	require.NoError(t, limiter.Wait(ctx))
+}
+
+func TestCrawler_IncludeExcludePatterns(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL: "https://example.com",
+		Links: []*fetch.Link{
+			{URL: "/docs/intro"},
+			{URL: "/docs/print/intro"},
+			{URL: "/blog/post"},
+		},
+	})
+	mockFetcher.AddResponse("https://example.com/docs/intro", &fetch.Response{URL: "https://example.com/docs/intro"})
+	mockFetcher.AddResponse("https://example.com/docs/print/intro", &fetch.Response{URL: "https://example.com/docs/print/intro"})
+	mockFetcher.AddResponse("https://example.com/blog/post", &fetch.Response{URL: "https://example.com/blog/post"})
+	mockFetcher.AddResponse("https://example.com/calendar", &fetch.Response{URL: "https://example.com/calendar"})
+
+	crawler := New(Options{
+		Workers:         1,
+		Fetcher:         mockFetcher,
+		FollowBehavior:  FollowSameDomain,
+		IncludePatterns: []*regexp.Regexp{regexp.MustCompile(`/docs/`)},
+		ExcludePatterns: []*regexp.Regexp{
+			regexp.MustCompile(`/print/`),
+			regexp.MustCompile(`[?&]date=`),
+		},
+	})
+
+	var processedURLs []string
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		processedURLs = append(processedURLs, result.URL.String())
+	}
+
+	// The home page seed bypasses the include patterns, while the calendar
+	// seed is excluded by its query string
+	seeds := []string{"https://example.com", "https://example.com/calendar?view=month&date=2024-01"}
+	err := crawler.Crawl(context.Background(), seeds, callback)
+	require.NoError(t, err)
+
+	sort.Strings(processedURLs)
+	assert.Equal(t, []string{
+		"https://example.com",
+		"https://example.com/docs/intro",
+	}, processedURLs)
+}
+
+func TestCrawler_RespectNofollow(t *testing.T) {
+	for _, respect := range []bool{false, true} {
+		t.Run(fmt.Sprintf("respect=%t", respect), func(t *testing.T) {
+			mockFetcher := fetch.NewMockFetcher()
+			mockFetcher.AddResponse("https://example.com", &fetch.Response{
+				URL: "https://example.com",
+				Links: []*fetch.Link{
+					{URL: "/about"},
+					{URL: "/spam", Rel: "nofollow"},
+				},
+			})
+			mockFetcher.AddResponse("https://example.com/about", &fetch.Response{URL: "https://example.com/about"})
+			mockFetcher.AddResponse("https://example.com/spam", &fetch.Response{URL: "https://example.com/spam"})
+
+			crawler := New(Options{
+				Workers:         1,
+				Fetcher:         mockFetcher,
+				FollowBehavior:  FollowSameDomain,
+				RespectNofollow: respect,
+			})
+			err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+				func(ctx context.Context, result *Result) {})
+			require.NoError(t, err)
+
+			expected := int64(3)
+			if respect {
+				expected = 2
+			}
+			assert.Equal(t, expected, crawler.GetStats().GetProcessed())
+		})
+	}
+}
+
+func TestCrawler_DeduplicateContent(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	page := "<html><body><h1>Same</h1></body></html>"
+	mockFetcher.AddResponse("https://example.com/a", &fetch.Response{
+		URL:   "https://example.com/a",
+		HTML:  page,
+		Links: []*fetch.Link{{URL: "/c"}},
+	})
+	mockFetcher.AddResponse("https://example.com/b", &fetch.Response{
+		URL:   "https://example.com/b",
+		HTML:  "\n" + page + "\n",
+		Links: []*fetch.Link{{URL: "/d"}},
+	})
+	mockFetcher.AddResponse("https://example.com/c", &fetch.Response{
+		URL:  "https://example.com/c",
+		HTML: "<html><body><h1>Other</h1></body></html>",
+	})
+
+	crawler := New(Options{
+		Workers:            1,
+		Fetcher:            mockFetcher,
+		DefaultParser:      NewMockParser(),
+		FollowBehavior:     FollowSameDomain,
+		DeduplicateContent: true,
+	})
+
+	results := map[string]*Result{}
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.URL.String()] = result
+	}
+
+	seeds := []string{"https://example.com/a", "https://example.com/b"}
+	err := crawler.Crawl(context.Background(), seeds, callback)
+	require.NoError(t, err)
+
+	// The duplicate page is reported but neither parsed nor followed
+	require.Len(t, results, 3)
+	duplicate := results["https://example.com/b"]
+	assert.True(t, duplicate.Duplicate)
+	assert.Equal(t, "https://example.com/a", duplicate.DuplicateOf.String())
+	assert.Nil(t, duplicate.Parsed)
+	assert.False(t, results["https://example.com/a"].Duplicate)
+	assert.NotNil(t, results["https://example.com/a"].Parsed)
+	assert.Equal(t, int64(1), crawler.GetStats().GetDuplicates())
+}
+
+func TestCrawler_RespectCanonical(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com/print", &fetch.Response{
+		URL:      "https://example.com/print",
+		Metadata: fetch.Metadata{CanonicalURL: "/article"},
+		Links:    []*fetch.Link{{URL: "/other"}},
+	})
+	mockFetcher.AddResponse("https://example.com/article", &fetch.Response{
+		URL:      "https://example.com/article",
+		Metadata: fetch.Metadata{CanonicalURL: "https://example.com/article"},
+	})
+
+	crawler := New(Options{
+		MaxURLs:          10,
+		Workers:          1,
+		Fetcher:          mockFetcher,
+		FollowBehavior:   FollowSameDomain,
+		RespectCanonical: true,
+	})
+
+	results := map[string]*Result{}
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.URL.String()] = result
+	}
+
+	err := crawler.Crawl(context.Background(), []string{"https://example.com/print"}, callback)
+	require.NoError(t, err)
+
+	// The non-canonical page's links are not followed
+	require.Len(t, results, 2)
+
+	variant := results["https://example.com/print"]
+	assert.True(t, variant.Duplicate)
+	assert.Equal(t, "https://example.com/article", variant.Canonical.String())
+	assert.Equal(t, "https://example.com/article", variant.DuplicateOf.String())
+
+	canonical := results["https://example.com/article"]
+	assert.False(t, canonical.Duplicate)
+	assert.Equal(t, "https://example.com/article", canonical.Canonical.String())
+	assert.Equal(t, 0, canonical.Depth)
+	assert.Equal(t, int64(1), crawler.GetStats().GetDuplicates())
+}
+
+func TestCrawler_CacheTTL(t *testing.T) {
+	htmlCache := cache.NewInMemoryCache()
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL:  "https://example.com",
+		HTML: "<html><body><h1>Fresh</h1></body></html>",
+	})
+
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		Cache:          htmlCache,
+		CacheTTL:       100 * time.Millisecond,
+		FollowBehavior: FollowNone,
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+
+	// The entry expires once the TTL has elapsed
+	time.Sleep(150 * time.Millisecond)
+	_, err = htmlCache.Get(context.Background(), "https://example.com")
+	assert.True(t, cache.IsNotFound(err))
+
+	// Without a TTL the entry is retained
+	require.NoError(t, cache.SetWithTTL(context.Background(), htmlCache, "key", []byte("value"), 0))
+	value, err := htmlCache.Get(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+}
+
+func TestCrawler_CacheErrors(t *testing.T) {
+	htmlCache := cache.NewInMemoryCache()
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/gone", "/busy")
+	mockFetcher.AddStatus("https://example.com/gone", http.StatusGone)
+	mockFetcher.AddStatus("https://example.com/busy", http.StatusTooManyRequests)
+
+	crawl := func() map[string]*Result {
+		crawler := New(Options{
+			Workers:             1,
+			Fetcher:             mockFetcher,
+			Cache:               htmlCache,
+			CacheErrors:         true,
+			FollowBehavior:      FollowSameDomain,
+			MaxRetryAfter:       time.Millisecond,
+			MaxRateLimitRetries: 1,
+		})
+		results := map[string]*Result{}
+		mu := sync.Mutex{}
+		err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+			func(ctx context.Context, result *Result) {
+				mu.Lock()
+				defer mu.Unlock()
+				results[result.URL.String()] = result
+			})
+		require.NoError(t, err)
+		return results
+	}
+
+	requests := func(url string) int {
+		count := 0
+		for _, requested := range mockFetcher.RequestedURLs() {
+			if requested == url {
+				count++
+			}
+		}
+		return count
+	}
+
+	results := crawl()
+	assert.Equal(t, http.StatusGone, results["https://example.com/gone"].StatusCode)
+	assert.Equal(t, 1, requests("https://example.com/gone"))
+	busy := requests("https://example.com/busy")
+	assert.Positive(t, busy)
+
+	// The dead URL is reported from the cache without being fetched, while
+	// rate limited URLs are fetched again
+	results = crawl()
+	require.Contains(t, results, "https://example.com/gone")
+	var reqErr *weberrors.RequestError
+	require.ErrorAs(t, results["https://example.com/gone"].Error, &reqErr)
+	assert.Equal(t, http.StatusGone, reqErr.StatusCode())
+	assert.Equal(t, http.StatusGone, results["https://example.com/gone"].StatusCode)
+	assert.Equal(t, 1, requests("https://example.com/gone"))
+	assert.Equal(t, 2*busy, requests("https://example.com/busy"))
+}
+
+func TestCrawler_CacheErrorsExpire(t *testing.T) {
+	// The filesystem cache cannot expire entries itself
+	fsCache := cache.NewFilesystem(t.TempDir())
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddStatus("https://example.com/gone", http.StatusGone)
+	mockFetcher.AddStatus("https://example.com/expired", http.StatusGone)
+
+	for rawURL, expires := range map[string]time.Time{
+		"https://example.com/gone":    time.Now().Add(time.Hour),
+		"https://example.com/expired": time.Now().Add(-time.Second),
+	} {
+		value, err := encodeDeadURL(rawURL, http.StatusGone, expires)
+		require.NoError(t, err)
+		require.NoError(t, fsCache.Set(context.Background(), rawURL, value))
+	}
+
+	crawler := New(Options{
+		Workers:     1,
+		Fetcher:     mockFetcher,
+		Cache:       fsCache,
+		CacheErrors: true,
+	})
+	err := crawler.Crawl(context.Background(),
+		[]string{"https://example.com/gone", "https://example.com/expired"},
+		func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+
+	// Only the URL whose marker has expired is checked again
+	assert.Equal(t, []string{"https://example.com/expired"}, mockFetcher.RequestedURLs())
+	assert.Equal(t, int64(1), crawler.GetStats().GetCacheHits())
+	assert.Equal(t, int64(1), crawler.GetStats().GetCacheMisses())
+}
+
+func TestIsDeadStatus(t *testing.T) {
+	assert.True(t, isDeadStatus(http.StatusNotFound))
+	assert.True(t, isDeadStatus(http.StatusGone))
+	assert.True(t, isDeadStatus(http.StatusForbidden))
+	assert.False(t, isDeadStatus(http.StatusRequestTimeout))
+	assert.False(t, isDeadStatus(http.StatusTooManyRequests))
+	assert.False(t, isDeadStatus(http.StatusInternalServerError))
+	assert.False(t, isDeadStatus(http.StatusOK))
+}
+
+func TestCrawler_CacheFullResponse(t *testing.T) {
+	htmlCache := cache.NewInMemoryCache()
+
+	// The first crawl fetches the pages and populates the cache
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL:        "https://example.com",
+		StatusCode: 200,
+		Headers:    map[string]string{"Content-Type": "text/html"},
+		HTML:       "<html><body><a href=\"/about\">About</a></body></html>",
+		Links:      []*fetch.Link{{URL: "/about"}},
+	})
+	mockFetcher.AddResponse("https://example.com/about", &fetch.Response{
+		URL:  "https://example.com/about",
+		HTML: "<html><body>About</body></html>",
+	})
+	opts := Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		Cache:          htmlCache,
+		FollowBehavior: FollowSameDomain,
+	}
+	crawler := New(opts)
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+	stats := crawler.GetStats()
+	assert.Equal(t, int64(0), stats.GetCacheHits())
+	assert.Equal(t, int64(2), stats.GetCacheMisses())
+	assert.Equal(t, int64(2), stats.GetCacheStores())
+
+	// The second crawl is served entirely from the cache, including links
+	opts.Fetcher = fetch.NewMockFetcher()
+	results := map[string]*Result{}
+	mu := sync.Mutex{}
+	crawler = New(opts)
+	err = crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			results[result.URL.String()] = result
+		})
+	require.NoError(t, err)
+
+	require.Len(t, results, 2)
+	home := results["https://example.com"]
+	require.NoError(t, home.Error)
+	assert.Equal(t, 200, home.Response.StatusCode)
+	assert.Equal(t, "text/html", home.Response.Headers["Content-Type"])
+	assert.Equal(t, []string{"https://example.com/about"}, home.Links)
+	assert.NoError(t, results["https://example.com/about"].Error)
+	stats = crawler.GetStats()
+	assert.Equal(t, int64(2), stats.GetCacheHits())
+	assert.Equal(t, int64(0), stats.GetCacheMisses())
+	assert.Equal(t, int64(0), stats.GetCacheStores())
+}
+
+func TestCrawler_Invalidate(t *testing.T) {
+	htmlCache := cache.NewInMemoryCache()
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL:  "https://example.com",
+		HTML: "<p>Old</p>",
+	})
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		Cache:          htmlCache,
+		FollowBehavior: FollowNone,
+	})
+	var pages []string
+	callback := func(ctx context.Context, result *Result) {
+		pages = append(pages, result.Response.HTML)
+	}
+	ctx := context.Background()
+	require.NoError(t, crawler.Crawl(ctx, []string{"https://example.com"}, callback))
+
+	// The URL was already processed, so a second crawl does nothing
+	require.NoError(t, crawler.Crawl(ctx, []string{"https://example.com"}, callback))
+	require.Equal(t, []string{"<p>Old</p>"}, pages)
+
+	// After invalidating, the URL is fetched again rather than read from the
+	// cache
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL:  "https://example.com",
+		HTML: "<p>New</p>",
+	})
+	require.NoError(t, crawler.Invalidate(ctx, "https://example.com/"))
+	require.NoError(t, crawler.Crawl(ctx, []string{"https://example.com"}, callback))
+	assert.Equal(t, []string{"<p>Old</p>", "<p>New</p>"}, pages)
+	assert.Len(t, mockFetcher.RequestedURLs(), 2)
+
+	// Invalidating a URL that was never cached is not an error
+	assert.NoError(t, crawler.Invalidate(ctx, "https://example.com/missing"))
+}
+
+func TestDecodeCachedResponse(t *testing.T) {
+	value, err := encodeCachedResponse(&fetch.Response{
+		URL:         "https://example.com",
+		HTML:        "<p>Hi</p>",
+		ContentType: "text/html; charset=utf-8",
+		Headers: map[string]string{
+			"Etag":          `"abc"`,
+			"Last-Modified": "Wed, 21 Oct 2015 07:28:00 GMT",
+		},
+	})
+	require.NoError(t, err)
+	response := decodeCachedResponse("https://example.com", value)
+	assert.Equal(t, "<p>Hi</p>", response.HTML)
+	assert.Equal(t, "text/html; charset=utf-8", response.ContentType)
+	assert.Equal(t, `"abc"`, response.GetHeader("ETag"))
+	assert.Equal(t, "Wed, 21 Oct 2015 07:28:00 GMT", response.GetHeader("last-modified"))
+
+	// Legacy values hold only the HTML
+	response = decodeCachedResponse("https://example.com", []byte("<p>Legacy</p>"))
+	assert.Equal(t, "https://example.com", response.URL)
+	assert.Equal(t, "<p>Legacy</p>", response.HTML)
+
+	// Dead URL markers hold only the URL and status
+	value, err = encodeDeadURL("https://example.com/gone", http.StatusGone, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	response = decodeCachedResponse("https://example.com/gone", value)
+	assert.Equal(t, http.StatusGone, response.StatusCode)
+	assert.Empty(t, response.HTML)
+
+	// Expired markers are ignored
+	value, err = encodeDeadURL("https://example.com/gone", http.StatusGone, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	assert.Nil(t, decodeCachedResponse("https://example.com/gone", value))
+}
+
+func TestCrawler_MaxResponseBytes(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/large", "/truncated")
+	mockFetcher.AddError("https://example.com/large", fetch.ErrResponseTooLarge)
+	truncated := fetch.NewMockResponse("https://example.com/truncated")
+	truncated.Truncated = true
+	mockFetcher.AddResponse("https://example.com/truncated", truncated)
+
+	crawler := New(Options{
+		MaxURLs:                10,
+		Workers:                1,
+		Fetcher:                mockFetcher,
+		FollowBehavior:         FollowSameDomain,
+		MaxResponseBytes:       1024,
+		TruncateLargeResponses: true,
+	})
+
+	results := map[string]*Result{}
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.URL.String()] = result
+	}
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, callback)
+	require.NoError(t, err)
+
+	for _, req := range mockFetcher.Requests() {
+		assert.Equal(t, int64(1024), req.MaxBodySize)
+		assert.True(t, req.TruncateBody)
+	}
+	assert.ErrorIs(t, results["https://example.com/large"].Error, fetch.ErrResponseTooLarge)
+	assert.NoError(t, results["https://example.com/truncated"].Error)
+	assert.Equal(t, int64(2), crawler.GetStats().GetOversized())
+	assert.Equal(t, int64(1), crawler.GetStats().GetFailed())
+}
+
+func TestCrawler_PreflightHead(t *testing.T) {
+	var mu sync.Mutex
+	gets := map[string]int{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			gets[r.URL.Path]++
+			mu.Unlock()
+		}
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/report.pdf">a</a><a href="/large">b</a><a href="/nohead">c</a>`))
+		case "/report.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write([]byte("%PDF-1.4"))
+		case "/large":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(strings.Repeat("x", 2048)))
+		case "/nohead":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<p>no head</p>"))
+		}
+	}))
+	defer server.Close()
+
+	crawler := New(Options{
+		MaxURLs:          10,
+		Workers:          1,
+		Fetcher:          fetch.NewHTTPFetcher(fetch.HTTPFetcherOptions{Client: server.Client()}),
+		FollowBehavior:   FollowSameDomain,
+		MaxResponseBytes: 1024,
+		PreflightHead:    true,
+	})
+
+	results := map[string]error{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.URL.Path] = result.Error
+	}
+	err := crawler.Crawl(context.Background(), []string{server.URL}, callback)
+	require.NoError(t, err)
+
+	assert.Len(t, results, 4)
+	assert.NoError(t, results[""])
+	assert.ErrorIs(t, results["/report.pdf"], ErrContentTypeNotAllowed)
+	assert.ErrorIs(t, results["/large"], fetch.ErrResponseTooLarge)
+	assert.NoError(t, results["/nohead"])
+	assert.Equal(t, map[string]int{"/": 1, "/nohead": 1}, gets)
+
+	stats := crawler.GetStats()
+	assert.Equal(t, int64(1), stats.GetSkipped())
+	assert.Equal(t, int64(1), stats.GetOversized())
+	assert.Equal(t, int64(2), stats.GetSucceeded())
+}
+
+func TestCrawler_StatusCodes(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/about", "/missing", "/gone", "/broken")
+	mockFetcher.AddPage("https://example.com/about")
+	mockFetcher.AddStatus("https://example.com/missing", 404)
+	mockFetcher.AddStatus("https://example.com/gone", 404)
+	mockFetcher.AddStatus("https://example.com/broken", 500)
+
+	crawler := New(Options{
+		Workers:        2,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		MaxRetries:     1,
+		RetryBackoff:   time.Millisecond,
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+
+	// Retried requests are counted once, by their final response
+	assert.Equal(t, map[int]int64{200: 2, 404: 2, 500: 1}, crawler.GetStats().StatusCodes())
+	assert.Equal(t, int64(1), crawler.GetStats().GetRetried())
+}
+
+func TestCrawler_RevalidateCache(t *testing.T) {
+	memoryCache := cache.NewInMemoryCache()
+	page := fetch.NewMockResponse("https://example.com", "/about")
+	page.HTML = "<p>home</p>"
+	page.Headers["Etag"] = `"v1"`
+	page.Headers["Last-Modified"] = "Wed, 14 Oct 2026 10:00:00 GMT"
+	about := fetch.NewMockResponse("https://example.com/about")
+	about.HTML = "<p>about</p>"
+
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com", page)
+	mockFetcher.AddResponse("https://example.com/about", about)
+	opts := Options{
+		MaxURLs:         10,
+		Workers:         1,
+		Cache:           memoryCache,
+		Fetcher:         mockFetcher,
+		FollowBehavior:  FollowSameDomain,
+		RevalidateCache: true,
+	}
+	err := New(opts).Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+
+	// The page is unchanged, so the cached copy is used
+	mockFetcher = fetch.NewMockFetcher()
+	mockFetcher.AddStatus("https://example.com", 304)
+	opts.Fetcher = mockFetcher
+	crawler := New(opts)
+
+	results := map[string]*Result{}
+	mu := sync.Mutex{}
+	err = crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			results[result.URL.String()] = result
+		})
+	require.NoError(t, err)
+
+	// Pages without validators are served from the cache without a request
+	requests := mockFetcher.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, `"v1"`, requests[0].ETag)
+	assert.Equal(t, "Wed, 14 Oct 2026 10:00:00 GMT", requests[0].LastModified)
+
+	home := results["https://example.com"]
+	require.NoError(t, home.Error)
+	assert.Equal(t, 200, home.StatusCode)
+	assert.Equal(t, "<p>home</p>", home.Response.HTML)
+	assert.Equal(t, []string{"https://example.com/about"}, home.Links)
+	assert.NoError(t, results["https://example.com/about"].Error)
+	assert.Equal(t, int64(1), crawler.GetStats().GetNotModified())
+	assert.Equal(t, int64(0), crawler.GetStats().GetBytesDownloaded())
+}
+
+func TestCrawler_FetcherRules(t *testing.T) {
+	httpFetcher := fetch.NewMockFetcher()
+	httpFetcher.AddPage("https://example.com", "/app/home", "https://app.example.com", "/about")
+	httpFetcher.AddPage("https://example.com/about")
+	browserFetcher := fetch.NewMockFetcher()
+	browserFetcher.AddPage("https://example.com/app/home")
+	browserFetcher.AddPage("https://app.example.com")
+
+	crawler := New(Options{
+		MaxURLs:        10,
+		Workers:        1,
+		Fetcher:        httpFetcher,
+		FollowBehavior: FollowAny,
+		Fetchers:       map[string]fetch.Fetcher{"browser": browserFetcher},
+		FetcherRules: []FetcherRule{
+			{Domain: "app.example.com", Fetcher: "browser"},
+			{Pattern: regexp.MustCompile(`/app/`), Fetcher: "browser"},
+		},
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			assert.NoError(t, result.Error)
+		})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"https://example.com", "https://example.com/about"},
+		httpFetcher.RequestedURLs())
+	assert.ElementsMatch(t, []string{"https://example.com/app/home", "https://app.example.com"},
+		browserFetcher.RequestedURLs())
+	for _, req := range browserFetcher.Requests() {
+		assert.Equal(t, "browser", req.Fetcher)
+	}
+}
+
+func TestCrawler_MaxDuration(t *testing.T) {
+	// A long chain of pages: /0 -> /1 -> /2 ...
+	mockFetcher := fetch.NewMockFetcher()
+	for i := 0; i < 100; i++ {
+		mockFetcher.AddPage(fmt.Sprintf("https://example.com/%d", i), fmt.Sprintf("/%d", i+1))
+	}
+
+	crawler := New(Options{
+		Workers:        1,
+		RequestDelay:   20 * time.Millisecond,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		MaxDuration:    200 * time.Millisecond,
+	})
+
+	var count int64
+	started := time.Now()
+	err := crawler.Crawl(context.Background(), []string{"https://example.com/0"},
+		func(ctx context.Context, result *Result) {
+			assert.NoError(t, result.Error)
+			atomic.AddInt64(&count, 1)
+		})
+	require.NoError(t, err)
+
+	// The crawl stops promptly, and completed pages are still delivered
+	assert.Less(t, time.Since(started), time.Second)
+	assert.Greater(t, count, int64(0))
+	assert.Less(t, count, int64(100))
+	assert.Equal(t, count, crawler.GetStats().GetProcessed())
+	assert.Equal(t, ReasonTimeout, crawler.GetStats().GetStopReason())
+}
+
+func TestCrawler_PathPrefixes(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/docs/", "/pricing", "/docsearch", "https://other.com/blog")
+	mockFetcher.AddPage("https://example.com/docs", "/docs/intro", "/about")
+	mockFetcher.AddPage("https://example.com/docs/intro")
+	mockFetcher.AddPage("https://other.com/blog")
+
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowAny,
+		PathPrefixes:   map[string][]string{"example.com": {"/docs/"}},
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			assert.NoError(t, result.Error)
+		})
+	require.NoError(t, err)
+
+	// The seed is crawled even though it is outside the prefix
+	assert.ElementsMatch(t, []string{
+		"https://example.com",
+		"https://example.com/docs",
+		"https://example.com/docs/intro",
+		"https://other.com/blog",
+	}, mockFetcher.RequestedURLs())
+}
+
+func TestCrawler_ExcludeExtensions(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com",
+		"/report.PDF", "/photo.jpg?size=large", "/archive.zip", "/page.html", "/docs")
+	mockFetcher.AddPage("https://example.com/page.html")
+	mockFetcher.AddPage("https://example.com/docs")
+
+	crawler := New(Options{
+		Workers:           1,
+		Fetcher:           mockFetcher,
+		FollowBehavior:    FollowSameDomain,
+		Normalize:         web.NormalizeOptions{KeepQuery: true},
+		ExcludeExtensions: []string{".pdf", "JPG", ".zip"},
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			assert.NoError(t, result.Error)
+		})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		"https://example.com",
+		"https://example.com/page.html",
+		"https://example.com/docs",
+	}, mockFetcher.RequestedURLs())
+}
+
+func TestCrawler_Hooks(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/missing")
+	mockFetcher.AddStatus("https://example.com/missing", 404)
+
+	var events []string
+	mu := sync.Mutex{}
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		OnEnqueue: func(url string, depth int) {
+			record(fmt.Sprintf("enqueue %s %d", url, depth))
+		},
+		OnFetchStart: func(url string) {
+			record("start " + url)
+		},
+		OnFetchDone: func(url string, resp *fetch.Response, err error, dur time.Duration) {
+			assert.GreaterOrEqual(t, dur, time.Duration(0))
+			record(fmt.Sprintf("done %s %d %t", url, resp.StatusCode, err != nil))
+		},
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"enqueue https://example.com 0",
+		"start https://example.com",
+		"done https://example.com 200 false",
+		"enqueue https://example.com/missing 1",
+		"start https://example.com/missing",
+		"done https://example.com/missing 404 true",
+	}, events)
+}
+
+func TestCrawler_AlreadyRunning(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com")
+
+	crawler := New(Options{Workers: 1, Fetcher: mockFetcher})
+
+	// Hold the first crawl open from its callback
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- crawler.Crawl(context.Background(), []string{"https://example.com"},
+			func(ctx context.Context, result *Result) {
+				close(started)
+				<-release
+			})
+	}()
+	<-started
+
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, func(ctx context.Context, result *Result) {})
+	assert.ErrorIs(t, err, ErrAlreadyRunning)
+	_, err = crawler.CrawlChan(context.Background(), []string{"https://example.com"})
+	assert.ErrorIs(t, err, ErrAlreadyRunning)
+
+	close(release)
+	require.NoError(t, <-done)
+
+	// The crawler may run again once the first crawl has finished
+	err = crawler.Crawl(context.Background(), []string{"https://example.com"}, func(ctx context.Context, result *Result) {})
+	assert.NoError(t, err)
+}
+
+func TestCrawler_Reset(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/1")
+	mockFetcher.AddPage("https://example.com/1")
+
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+	})
+	var count int
+	callback := func(ctx context.Context, result *Result) {
+		count++
+	}
+
+	seeds := []string{"https://example.com"}
+	require.NoError(t, crawler.Crawl(context.Background(), seeds, callback))
+	assert.Equal(t, 2, count)
+
+	// URLs seen by the first crawl are not crawled again
+	require.NoError(t, crawler.Crawl(context.Background(), seeds, callback))
+	assert.Equal(t, 2, count)
+	assert.Equal(t, int64(2), crawler.GetStats().GetProcessed())
+
+	require.NoError(t, crawler.Reset())
+	assert.Equal(t, int64(0), crawler.GetStats().GetProcessed())
+	assert.Equal(t, int64(0), crawler.GetStats().GetLatency().Count)
+	assert.Empty(t, crawler.GetStats().GetStopReason())
+
+	require.NoError(t, crawler.Crawl(context.Background(), seeds, callback))
+	assert.Equal(t, 4, count)
+	assert.Equal(t, int64(2), crawler.GetStats().GetProcessed())
+}
+
+func TestCrawler_AddURLs(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/1")
+	mockFetcher.AddPage("https://example.com/1")
+	mockFetcher.AddPage("https://other.com", "/2")
+	mockFetcher.AddPage("https://other.com/2")
+
+	crawler := New(Options{
+		Workers:         2,
+		Fetcher:         mockFetcher,
+		FollowBehavior:  FollowSameDomain,
+		ExcludePatterns: []*regexp.Regexp{regexp.MustCompile(`/private`)},
+	})
+
+	_, err := crawler.AddURLs(context.Background(), []string{"https://other.com"})
+	require.ErrorIs(t, err, ErrNotRunning)
+
+	var once sync.Once
+	var added int
+	var addErr error
+	err = crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			assert.NoError(t, result.Error)
+			once.Do(func() {
+				// Known and excluded URLs are ignored
+				added, addErr = crawler.AddURLs(ctx, []string{
+					"https://example.com",
+					"https://other.com",
+					"https://other.com/private",
+				})
+			})
+		})
+	require.NoError(t, err)
+	require.NoError(t, addErr)
+
+	assert.Equal(t, 1, added)
+	assert.ElementsMatch(t, []string{
+		"https://example.com",
+		"https://example.com/1",
+		"https://other.com",
+		"https://other.com/2",
+	}, mockFetcher.RequestedURLs())
+}
+
+func TestCrawler_AddURLsBeforeRun(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com/1")
+	mockFetcher.AddPage("https://example.com/2")
+	mockFetcher.AddPage("https://example.com/3")
+
+	crawler := New(Options{Workers: 1, Fetcher: mockFetcher})
+	require.NoError(t, crawler.frontier.Push(FrontierEntry{URL: "https://example.com/1"}))
+	require.NoError(t, crawler.frontier.Push(FrontierEntry{URL: "https://example.com/2"}))
+
+	// Entries left in the frontier are outstanding as soon as the crawler is
+	// running, and URLs added before the workers start are counted on top
+	stop, err := crawler.start()
+	require.NoError(t, err)
+	defer crawler.finish()
+	assert.Equal(t, int64(2), crawler.getOutstanding())
+	count, err := crawler.AddURLs(context.Background(), []string{"https://example.com/3"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, int64(3), crawler.getOutstanding())
+
+	err = crawler.run(context.Background(), stop, nil, func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+	assert.Len(t, mockFetcher.RequestedURLs(), 3)
+	assert.Equal(t, int64(0), crawler.getOutstanding())
+}
+
+func TestCrawler_StayAlive(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com")
+	mockFetcher.AddPage("https://other.com")
+
+	crawler := New(Options{
+		Workers:        2,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		StayAlive:      true,
+	})
+	crawled := make(chan string, 10)
+	done := make(chan error)
+	go func() {
+		done <- crawler.Crawl(context.Background(), []string{"https://example.com"},
+			func(ctx context.Context, result *Result) {
+				crawled <- result.URL.String()
+			})
+	}()
+	assert.Equal(t, "https://example.com", <-crawled)
+
+	// The idle crawl keeps running and crawls URLs added later
+	select {
+	case <-done:
+		t.Fatal("crawl returned while idle")
+	case <-time.After(3 * idleCheckInterval):
+	}
+	added, err := crawler.AddURLs(context.Background(), []string{"https://other.com"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+	assert.Equal(t, "https://other.com", <-crawled)
+
+	crawler.Stop()
+	require.NoError(t, <-done)
+	assert.Equal(t, ReasonStopped, crawler.GetStats().GetStopReason())
+}
+
+func TestCrawler_StayAliveMaxURLs(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/1", "/2")
+	mockFetcher.AddPage("https://example.com/1")
+	mockFetcher.AddPage("https://example.com/2")
+
+	// A crawl that reaches MaxURLs ends once idle, as nothing more can be
+	// crawled
+	crawler := New(Options{
+		MaxURLs:        2,
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		StayAlive:      true,
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+	assert.Len(t, mockFetcher.RequestedURLs(), 2)
+	assert.Equal(t, ReasonMaxURLs, crawler.GetStats().GetStopReason())
+}
+
+func TestCrawler_ParseErrors(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/bad")
+	mockFetcher.AddPage("https://example.com/bad")
+
+	parser := NewMockParser()
+	parser.SetParseFunc(func(ctx context.Context, page *fetch.Response) (any, error) {
+		if strings.HasSuffix(page.URL, "/bad") {
+			return nil, errors.New("unexpected layout")
+		}
+		return "ok", nil
+	})
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		DefaultParser:  parser,
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+
+	assert.Equal(t, []ParseError{
+		{URL: "https://example.com/bad", Error: "unexpected layout"},
+	}, crawler.GetStats().ParseErrors())
+	assert.Equal(t, int64(0), crawler.GetStats().GetParseErrorsDropped())
+}
+
+func TestCrawler_Redirects(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com/old", &fetch.Response{
+		URL:       "https://example.com/old",
+		FinalURL:  "https://example.com/new",
+		Redirects: []string{"https://example.com/old"},
+		Links:     []*fetch.Link{{URL: "/new"}, {URL: "/page"}},
+	})
+	mockFetcher.AddPage("https://example.com/page", "/new", "/moved")
+	mockFetcher.AddResponse("https://example.com/away", &fetch.Response{
+		URL:       "https://example.com/away",
+		FinalURL:  "https://other.com/",
+		Redirects: []string{"https://example.com/away"},
+		Links:     []*fetch.Link{{URL: "/elsewhere"}},
+	})
+	mockFetcher.AddResponse("https://example.com/moved", &fetch.Response{
+		URL:       "https://example.com/moved",
+		FinalURL:  "https://example.com/new",
+		Redirects: []string{"https://example.com/moved"},
+	})
+
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+	})
+
+	results := map[string]*Result{}
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.URL.String()] = result
+	}
+	seeds := []string{"https://example.com/old", "https://example.com/away"}
+	require.NoError(t, crawler.Crawl(context.Background(), seeds, callback))
+
+	// The final URL is not fetched again when linked to directly
+	assert.ElementsMatch(t, []string{
+		"https://example.com/old",
+		"https://example.com/away",
+		"https://example.com/page",
+		"https://example.com/moved",
+	}, mockFetcher.RequestedURLs())
+	require.Len(t, results, 4)
+
+	old := results["https://example.com/old"]
+	require.NoError(t, old.Error)
+	assert.Equal(t, "https://example.com/new", old.FinalURL.String())
+	assert.Equal(t, []string{"https://example.com/old"}, old.Redirects)
+	assert.Equal(t, []string{"https://example.com/new", "https://example.com/page"}, old.Links)
+
+	away := results["https://example.com/away"]
+	assert.ErrorIs(t, away.Error, ErrRedirectNotFollowed)
+	assert.Equal(t, "https://other.com", away.FinalURL.String())
+	assert.Nil(t, results["https://other.com/elsewhere"])
+
+	page := results["https://example.com/page"]
+	assert.Nil(t, page.FinalURL)
+	assert.Empty(t, page.Redirects)
+	assert.Equal(t, int64(1), crawler.GetStats().GetSkipped())
+
+	// A page that redirects to a URL that was already crawled is a duplicate
+	moved := results["https://example.com/moved"]
+	assert.True(t, moved.Duplicate)
+	assert.Equal(t, "https://example.com/new", moved.DuplicateOf.String())
+	assert.Equal(t, int64(1), crawler.GetStats().GetDuplicates())
+}
+
+func TestCrawler_RedirectFollowRules(t *testing.T) {
+	tests := []struct {
+		name            string
+		followBehavior  FollowBehavior
+		finalURL        string
+		excludePatterns []*regexp.Regexp
+		followed        bool
+	}{
+		{"same domain, same host", FollowSameDomain, "https://example.com/new", nil, true},
+		{"same domain, other host", FollowSameDomain, "https://other.com/new", nil, false},
+		{"same domain, subdomain", FollowSameDomain, "https://www.example.com/new", nil, false},
+		{"related subdomains, subdomain", FollowRelatedSubdomains, "https://www.example.com/new", nil, true},
+		{"related subdomains, other host", FollowRelatedSubdomains, "https://other.com/new", nil, false},
+		{"any, other host", FollowAny, "https://other.com/new", nil, true},
+		{"none, same host", FollowNone, "https://example.com/new", nil, true},
+		{"none, other host", FollowNone, "https://other.com/new", nil, false},
+		{"excluded target", FollowSameDomain, "https://example.com/private",
+			[]*regexp.Regexp{regexp.MustCompile(`/private`)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFetcher := fetch.NewMockFetcher()
+			mockFetcher.AddResponse("https://example.com/old", &fetch.Response{
+				URL:       "https://example.com/old",
+				FinalURL:  tt.finalURL,
+				Redirects: []string{"https://example.com/old"},
+				Links:     []*fetch.Link{{URL: "/next"}},
+			})
+			crawler := New(Options{
+				Workers:         1,
+				Fetcher:         mockFetcher,
+				FollowBehavior:  tt.followBehavior,
+				ExcludePatterns: tt.excludePatterns,
+			})
+
+			var results []*Result
+			mu := sync.Mutex{}
+			callback := func(ctx context.Context, result *Result) {
+				mu.Lock()
+				defer mu.Unlock()
+				results = append(results, result)
+			}
+			err := crawler.Crawl(context.Background(), []string{"https://example.com/old"}, callback)
+			require.NoError(t, err)
+
+			require.NotEmpty(t, results)
+			result := results[0]
+			assert.Equal(t, tt.finalURL, result.FinalURL.String())
+			if tt.followed {
+				assert.NoError(t, result.Error)
+				assert.NotEmpty(t, result.Links)
+			} else {
+				assert.ErrorIs(t, result.Error, ErrRedirectNotFollowed)
+				assert.Empty(t, result.Links)
+				assert.Len(t, results, 1)
+			}
+		})
+	}
+}
+
+func TestCrawler_Pause(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/a", "/b", "/c")
+	mockFetcher.AddPage("https://example.com/a")
+	mockFetcher.AddPage("https://example.com/b")
+	mockFetcher.AddPage("https://example.com/c")
+
+	crawler := New(Options{
+		Workers:        2,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+	})
+
+	// Pause from the callback for the seed, before its links are queued
+	paused := make(chan struct{})
+	callback := func(ctx context.Context, result *Result) {
+		if result.URL.String() == "https://example.com" {
+			crawler.Pause()
+			crawler.Pause()
+			close(paused)
+		}
+	}
+	done := make(chan error)
+	go func() {
+		done <- crawler.Crawl(context.Background(), []string{"https://example.com"}, callback)
+	}()
+
+	<-paused
+	assert.True(t, crawler.Paused())
+
+	// The paused crawl is not considered idle and fetches nothing more
+	select {
+	case <-done:
+		t.Fatal("crawl returned while paused")
+	case <-time.After(3 * idleCheckInterval):
+	}
+	assert.Equal(t, []string{"https://example.com"}, mockFetcher.RequestedURLs())
+
+	crawler.Unpause()
+	crawler.Unpause()
+	assert.False(t, crawler.Paused())
+	require.NoError(t, <-done)
+	assert.Len(t, mockFetcher.RequestedURLs(), 4)
+	assert.Equal(t, ReasonIdle, crawler.GetStats().GetStopReason())
+}
+
+func TestCrawler_QueueLenAndInFlight(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/a", "/b", "/c")
+	mockFetcher.AddPage("https://example.com/a")
+	mockFetcher.AddPage("https://example.com/b")
+	mockFetcher.AddPage("https://example.com/c")
+
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+	})
+
+	// The callback runs while its URL is in flight, before its links are
+	// queued
+	var queued []int
+	var inFlight []int64
+	callback := func(ctx context.Context, result *Result) {
+		queued = append(queued, crawler.QueueLen())
+		inFlight = append(inFlight, crawler.InFlight())
+	}
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, callback)
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{0, 2, 1, 0}, queued)
+	assert.Equal(t, []int64{1, 1, 1, 1}, inFlight)
+	assert.Equal(t, 0, crawler.QueueLen())
+	assert.Equal(t, int64(0), crawler.InFlight())
+}
+
+func TestCrawler_StopWhilePaused(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com")
+	mockFetcher.AddPage("https://example.com/a")
+
+	crawler := New(Options{
+		Workers:        2,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+	})
+
+	// A crawl started while paused fetches nothing until it is unpaused
+	crawler.Pause()
+	done := make(chan error)
+	go func() {
+		done <- crawler.Crawl(context.Background(),
+			[]string{"https://example.com", "https://example.com/a"},
+			func(ctx context.Context, result *Result) {})
+	}()
+	time.Sleep(3 * idleCheckInterval)
+	crawler.Stop()
+	require.NoError(t, <-done)
+
+	// The queued URLs are left in the frontier for the next crawl
+	assert.Empty(t, mockFetcher.RequestedURLs())
+	assert.Equal(t, 2, crawler.frontier.Len())
+	assert.Equal(t, ReasonStopped, crawler.GetStats().GetStopReason())
+
+	crawler.Unpause()
+	require.NoError(t, crawler.Crawl(context.Background(), nil, func(ctx context.Context, result *Result) {}))
+	assert.Len(t, mockFetcher.RequestedURLs(), 2)
+}
+
+func TestCrawler_AllowedDomains(t *testing.T) {
+	tests := []struct {
+		name           string
+		followBehavior FollowBehavior
+		allowedDomains []string
+		expected       []string
+	}{
+		{"same domain only", FollowSameDomain, nil,
+			[]string{"https://example.com/about"}},
+		{"allowed domain", FollowSameDomain, []string{"cdn.example.net"},
+			[]string{"https://example.com/about", "https://cdn.example.net/app.html"}},
+		{"allowed subdomain", FollowSameDomain, []string{"example.net"},
+			[]string{"https://example.com/about", "https://cdn.example.net/app.html"}},
+		{"allowed domain as url", FollowSameDomain, []string{"https://CDN.Example.NET/"},
+			[]string{"https://example.com/about", "https://cdn.example.net/app.html"}},
+		{"internationalized domain", FollowSameDomain, []string{"bücher.example"},
+			[]string{"https://example.com/about", "https://xn--bcher-kva.example/books"}},
+		{"none follows nothing", FollowNone, []string{"cdn.example.net"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crawler := New(Options{
+				FollowBehavior: tt.followBehavior,
+				AllowedDomains: tt.allowedDomains,
+			})
+			pageURL, err := url.Parse("https://example.com/")
+			require.NoError(t, err)
+			links := crawler.filterLinks(context.Background(), pageURL, []string{
+				"https://example.com/about",
+				"https://cdn.example.net/app.html",
+				"https://xn--bcher-kva.example/books",
+				"https://other.com",
+			}, nil)
+			var followed []string
+			for _, link := range links {
+				followed = append(followed, link.String())
+			}
+			assert.ElementsMatch(t, tt.expected, followed)
+		})
+	}
+}
+
+func TestCrawler_BlockedDomains(t *testing.T) {
+	tests := []struct {
+		name           string
+		blockedDomains []string
+		expected       []string
+		blocked        int64
+	}{
+		{"nothing blocked", nil, []string{
+			"https://example.com",
+			"https://ads.doubleclick.net/ad",
+			"https://doubleclick.net/home",
+			"https://tracker.com/pixel",
+		}, 0},
+		{"domain and subdomains", []string{"doubleclick.net", "TRACKER.com"}, []string{
+			"https://example.com",
+		}, 3},
+		{"wildcard blocks subdomains only", []string{"*.doubleclick.net"}, []string{
+			"https://example.com",
+			"https://doubleclick.net/home",
+			"https://tracker.com/pixel",
+		}, 1},
+		{"seed on a blocked domain", []string{"example.com"}, nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFetcher := fetch.NewMockFetcher()
+			mockFetcher.AddPage("https://example.com",
+				"https://ads.doubleclick.net/ad",
+				"https://doubleclick.net/home",
+				"https://tracker.com/pixel",
+				"https://ads.doubleclick.net/ad")
+			mockFetcher.AddPage("https://ads.doubleclick.net/ad")
+			mockFetcher.AddPage("https://doubleclick.net/home")
+			mockFetcher.AddPage("https://tracker.com/pixel")
+
+			crawler := New(Options{
+				Workers:        1,
+				Fetcher:        mockFetcher,
+				FollowBehavior: FollowAny,
+				AllowedDomains: []string{"tracker.com"},
+				BlockedDomains: tt.blockedDomains,
+			})
+
+			var urls []string
+			mu := sync.Mutex{}
+			callback := func(ctx context.Context, result *Result) {
+				mu.Lock()
+				defer mu.Unlock()
+				urls = append(urls, result.URL.String())
+			}
+			err := crawler.Crawl(context.Background(), []string{"https://example.com"}, callback)
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, tt.expected, urls)
+			// Each blocked URL is counted once, however often it is linked
+			assert.Equal(t, tt.blocked, crawler.GetStats().GetBlocked())
+		})
+	}
+}
+
+func TestCrawler_RedirectToBlockedDomain(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com/out", &fetch.Response{
+		URL:       "https://example.com/out",
+		FinalURL:  "https://ads.doubleclick.net/landing",
+		Redirects: []string{"https://example.com/out"},
+	})
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowAny,
+		BlockedDomains: []string{"doubleclick.net"},
+	})
+
+	var results []*Result
+	err := crawler.Crawl(context.Background(), []string{"https://example.com/out"}, func(ctx context.Context, result *Result) {
+		results = append(results, result)
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.ErrorIs(t, results[0].Error, ErrRedirectNotFollowed)
+}
+
+func TestCrawler_RedirectingSeeds(t *testing.T) {
+	tests := []struct {
+		name      string
+		redirects map[string]string
+		processed []string
+		dupes     map[string]string
+	}{
+		{
+			name:      "a redirects to b",
+			redirects: map[string]string{"https://example.com/a": "https://example.com/b"},
+			processed: []string{"https://example.com/b"},
+			dupes:     map[string]string{"https://example.com/a": "https://example.com/b"},
+		},
+		{
+			name:      "b redirects to a",
+			redirects: map[string]string{"https://example.com/b": "https://example.com/a"},
+			processed: []string{"https://example.com/a"},
+			dupes:     map[string]string{"https://example.com/b": "https://example.com/a"},
+		},
+		{
+			name: "both redirect to the same page",
+			redirects: map[string]string{
+				"https://example.com/a": "https://example.com/c",
+				"https://example.com/b": "https://example.com/c",
+			},
+			processed: []string{"https://example.com/a"},
+			dupes:     map[string]string{"https://example.com/b": "https://example.com/c"},
+		},
+		{
+			name: "a and b redirect to each other",
+			redirects: map[string]string{
+				"https://example.com/a": "https://example.com/b",
+				"https://example.com/b": "https://example.com/a",
+			},
+			processed: []string{"https://example.com/b"},
+			dupes:     map[string]string{"https://example.com/a": "https://example.com/b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFetcher := fetch.NewMockFetcher()
+			for _, rawURL := range []string{"https://example.com/a", "https://example.com/b"} {
+				response := &fetch.Response{
+					URL:   rawURL,
+					Links: []*fetch.Link{{URL: rawURL + "/child"}},
+				}
+				if final, ok := tt.redirects[rawURL]; ok {
+					response.FinalURL = final
+					response.Redirects = []string{rawURL}
+				}
+				mockFetcher.AddResponse(rawURL, response)
+			}
+
+			crawler := New(Options{
+				Workers:        1,
+				Fetcher:        mockFetcher,
+				FollowBehavior: FollowSameDomain,
+				MaxDepth:       1,
+			})
+
+			results := map[string]*Result{}
+			mu := sync.Mutex{}
+			callback := func(ctx context.Context, result *Result) {
+				mu.Lock()
+				defer mu.Unlock()
+				results[result.URL.String()] = result
+			}
+			seeds := []string{"https://example.com/a", "https://example.com/b"}
+			require.NoError(t, crawler.Crawl(context.Background(), seeds, callback))
+
+			// Each page's content is processed once
+			var processed []string
+			for rawURL, result := range results {
+				if strings.HasSuffix(rawURL, "/child") {
+					continue
+				}
+				require.NoError(t, result.Error)
+				if result.Duplicate {
+					assert.Equal(t, tt.dupes[rawURL], result.DuplicateOf.String(), rawURL)
+					assert.Empty(t, result.Links, rawURL)
+				} else {
+					processed = append(processed, rawURL)
+				}
+			}
+			assert.ElementsMatch(t, tt.processed, processed)
+			assert.Equal(t, int64(len(tt.dupes)), crawler.GetStats().GetDuplicates())
+			_, fetched := results[tt.processed[0]+"/child"]
+			assert.True(t, fetched)
+		})
+	}
+}
+
+func TestCrawler_LogURLs(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	home := fetch.NewMockResponse("https://example.com", "/about", "https://other.com/")
+	home.HTML = "<html><body>Home</body></html>"
+	mockFetcher.AddResponse("https://example.com", home)
+	mockFetcher.AddPage("https://example.com/about")
+
+	tests := []struct {
+		name    string
+		logURLs bool
+		level   slog.Level
+		logged  bool
+	}{
+		{"enabled", true, slog.LevelDebug, true},
+		{"disabled", false, slog.LevelDebug, false},
+		{"debug level not enabled", true, slog.LevelInfo, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: tt.level}))
+			crawler := New(Options{
+				Workers:        1,
+				Fetcher:        mockFetcher,
+				FollowBehavior: FollowSameDomain,
+				Logger:         logger,
+				LogURLs:        tt.logURLs,
+			})
+			require.NoError(t, crawler.Crawl(context.Background(), []string{"https://example.com"}, func(ctx context.Context, result *Result) {}))
+
+			entries := map[string]map[string]any{}
+			for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+				var entry map[string]any
+				require.NoError(t, json.Unmarshal(line, &entry))
+				if entry["msg"] == "processed url" {
+					entries[entry["url"].(string)] = entry
+				}
+			}
+			if !tt.logged {
+				assert.Empty(t, entries)
+				return
+			}
+			require.Len(t, entries, 2)
+			entry := entries["https://example.com"]
+			assert.Equal(t, "DEBUG", entry["level"])
+			assert.Equal(t, false, entry["cached"])
+			assert.Contains(t, entry, "duration")
+			assert.Equal(t, float64(200), entry["status_code"])
+			assert.Equal(t, float64(len(home.HTML)), entry["bytes"])
+			assert.Equal(t, float64(2), entry["links"])
+			assert.Equal(t, float64(1), entry["enqueued"])
+		})
+	}
+}
+
+// lockedBuffer is a bytes.Buffer that is safe for concurrent use, for logs
+// written by background goroutines.
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
+
+func TestCrawler_ProgressReport(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	delays := map[string]time.Duration{}
+	var links []string
+	for i := 0; i < 20; i++ {
+		link := fmt.Sprintf("https://example.com/%d", i)
+		links = append(links, link)
+		mockFetcher.AddPage(link)
+		delays[link] = 20 * time.Millisecond
+	}
+	mockFetcher.AddPage("https://example.com", links...)
+
+	var logs lockedBuffer
+	crawler := New(Options{
+		Workers:              2,
+		MaxURLs:              15,
+		Fetcher:              &slowFetcher{Fetcher: mockFetcher, delays: delays},
+		FollowBehavior:       FollowSameDomain,
+		Logger:               slog.New(slog.NewJSONHandler(&logs, nil)),
+		ShowProgress:         true,
+		ShowProgressInterval: 50 * time.Millisecond,
+	})
+	require.NoError(t, crawler.Crawl(context.Background(), []string{"https://example.com"}, func(ctx context.Context, result *Result) {}))
+
+	var reports []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(line, &entry))
+		if entry["msg"] == "crawl progress" {
+			reports = append(reports, entry)
+		}
+	}
+	require.NotEmpty(t, reports)
+	report := reports[0]
+	assert.Greater(t, report["pages_per_second"], float64(0))
+	assert.Contains(t, report, "queue_length")
+	assert.Contains(t, report, "active_workers")
+	assert.LessOrEqual(t, report["active_workers"], float64(2))
+	assert.Contains(t, report, "eta")
+	assert.Contains(t, report, "processed")
+}
+
+func TestEstimateRemaining(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining int64
+		rate      float64
+		expected  time.Duration
+		ok        bool
+	}{
+		{"steady rate", 100, 10, 10 * time.Second, true},
+		{"rounded to the second", 10, 3, 3 * time.Second, true},
+		{"nothing remaining", 0, 0, 0, true},
+		{"past the limit", -5, 10, 0, true},
+		{"no progress", 100, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eta, ok := estimateRemaining(tt.remaining, tt.rate)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, eta)
+		})
+	}
+	assert.Equal(t, 4.0, pageRate(2, 500*time.Millisecond))
+	assert.Equal(t, 0.0, pageRate(2, 0))
+}
+
+func TestCrawler_ShouldFollow(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/docs", "/blog", "/private")
+	mockFetcher.AddPage("https://example.com/docs", "/docs/intro", "/docs/api")
+	mockFetcher.AddPage("https://example.com/blog", "/blog/post-1", "/blog/post-2")
+	mockFetcher.AddPage("https://example.com/docs/intro")
+	mockFetcher.AddPage("https://example.com/docs/api")
+	mockFetcher.AddPage("https://example.com/private")
+
+	var mu sync.Mutex
+	consulted := map[string]string{}
+	crawler := New(Options{
+		Workers:         1,
+		Fetcher:         mockFetcher,
+		FollowBehavior:  FollowSameDomain,
+		ExcludePatterns: []*regexp.Regexp{regexp.MustCompile(`/private`)},
+		ShouldFollow: func(ctx context.Context, from *url.URL, link string) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			consulted[link] = from.String()
+			// Stop descending into the blog
+			return from.Path != "/blog"
+		},
+	})
+	require.NoError(t, crawler.Crawl(context.Background(), []string{"https://example.com"}, func(ctx context.Context, result *Result) {}))
+
+	assert.ElementsMatch(t, []string{
+		"https://example.com",
+		"https://example.com/docs",
+		"https://example.com/blog",
+		"https://example.com/docs/intro",
+		"https://example.com/docs/api",
+	}, mockFetcher.RequestedURLs())
+
+	// The hook sees the discovering page, and only links that pass the
+	// built-in filters
+	assert.Equal(t, "https://example.com/blog", consulted["https://example.com/blog/post-1"])
+	assert.Equal(t, "https://example.com", consulted["https://example.com/docs"])
+	assert.NotContains(t, consulted, "https://example.com/private")
+}
+
+func TestCrawler_LinkNormalization(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com",
+		"/about",
+		"/about/",
+		"/about#team",
+		"http://example.com/about",
+		"https://example.com",
+		"/contact")
+	mockFetcher.AddPage("https://example.com/about", "/", "/contact/")
+	mockFetcher.AddPage("https://example.com/contact")
+
+	var enqueued []string
+	var mu sync.Mutex
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		OnEnqueue: func(url string, depth int) {
+			mu.Lock()
+			defer mu.Unlock()
+			enqueued = append(enqueued, url)
+		},
+	})
+	results := map[string]*Result{}
+	require.NoError(t, crawler.Crawl(context.Background(), []string{"https://example.com"}, func(ctx context.Context, result *Result) {
+		results[result.URL.String()] = result
+	}))
+
+	// Each link is reported and queued once, under its normalized form
+	assert.Equal(t, []string{
+		"https://example.com",
+		"https://example.com/about",
+		"https://example.com/contact",
+	}, results["https://example.com"].Links)
+	assert.ElementsMatch(t, []string{
+		"https://example.com",
+		"https://example.com/about",
+		"https://example.com/contact",
+	}, enqueued)
+	assert.ElementsMatch(t, enqueued, mockFetcher.RequestedURLs())
+}
+
+func TestCrawler_LinkDetails(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL: "https://example.com",
+		Links: []*fetch.Link{
+			{URL: "/about"},
+			{URL: "/about/#team"},
+			{URL: "https://other.com/page"},
+			{URL: "mailto:team@example.com"},
+			{URL: "/spam", Rel: "nofollow"},
+			{URL: "/"},
+		},
+	})
+	mockFetcher.AddResponse("https://example.com/about", &fetch.Response{
+		URL:   "https://example.com/about",
+		Links: []*fetch.Link{{URL: "/contact"}},
+	})
+
+	crawler := New(Options{
+		Workers:         1,
+		Fetcher:         mockFetcher,
+		FollowBehavior:  FollowSameDomain,
+		RespectNofollow: true,
+		MaxDepth:        1,
+	})
+	results := map[string]*Result{}
+	require.NoError(t, crawler.Crawl(context.Background(), []string{"https://example.com"}, func(ctx context.Context, result *Result) {
+		results[result.URL.String()] = result
+	}))
+
+	// Each link is described in page order, whether or not it was followed
+	assert.Equal(t, []LinkDetail{
+		{Raw: "/about", Resolved: "https://example.com/about", Followed: true},
+		{Raw: "/about/#team", Resolved: "https://example.com/about", Followed: true},
+		{Raw: "https://other.com/page", Resolved: "https://other.com/page"},
+		{Raw: "mailto:team@example.com"},
+		{Raw: "/spam", Resolved: "https://example.com/spam"},
+		{Raw: "/", Resolved: "https://example.com", Followed: true},
+	}, results["https://example.com"].LinkDetails)
+	assert.Equal(t, []string{
+		"https://example.com",
+		"https://example.com/about",
+		"https://other.com/page",
+	}, results["https://example.com"].Links)
+
+	// Links on pages at the maximum depth are not followed
+	assert.Equal(t, []LinkDetail{
+		{Raw: "/contact", Resolved: "https://example.com/contact"},
+	}, results["https://example.com/about"].LinkDetails)
+}
+
+func TestCrawler_RewriteURL(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com",
+		"/news/story/amp",
+		"/news/story",
+		"https://staging.example.com/pricing",
+		"/logout")
+	mockFetcher.AddPage("https://example.com/news/story")
+	mockFetcher.AddPage("https://example.com/pricing")
+
+	rewrite := func(u *url.URL) *url.URL {
+		if u.Path == "/logout" {
+			return nil
+		}
+		if u.Host == "staging.example.com" {
+			u.Host = "example.com"
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/amp")
+		return u
+	}
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		RewriteURL:     rewrite,
+	})
+	results := map[string]*Result{}
+	err := crawler.Crawl(context.Background(), []string{"https://staging.example.com"},
+		func(ctx context.Context, result *Result) {
+			results[result.URL.String()] = result
+		})
+	require.NoError(t, err)
+
+	// The rewritten URLs are filtered, deduplicated and fetched
+	assert.Equal(t, []string{
+		"https://example.com",
+		"https://example.com/news/story",
+		"https://example.com/pricing",
+	}, mockFetcher.RequestedURLs())
+	assert.Equal(t, []string{
+		"https://example.com/news/story",
+		"https://example.com/pricing",
+	}, results["https://example.com"].Links)
+}
+
+func TestCrawler_IgnoreWWW(t *testing.T) {
+	tests := []struct {
+		name      string
+		ignoreWWW bool
+		stripWWW  bool
+		expected  []string
+	}{
+		{
+			name:     "hosts differ by default",
+			expected: []string{"https://example.com"},
+		},
+		{
+			name:      "www followed",
+			ignoreWWW: true,
+			expected: []string{
+				"https://example.com",
+				"https://www.example.com/about",
+				"https://example.com/contact",
+			},
+		},
+		{
+			name:      "www stripped",
+			ignoreWWW: true,
+			stripWWW:  true,
+			expected: []string{
+				"https://example.com",
+				"https://example.com/about",
+				"https://example.com/contact",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFetcher := fetch.NewMockFetcher()
+			mockFetcher.AddPage("https://example.com",
+				"https://www.example.com/about",
+				"https://www.blog.example.com")
+			mockFetcher.AddPage("https://www.example.com/about", "https://example.com/contact")
+			mockFetcher.AddPage("https://example.com/about", "https://example.com/contact")
+			mockFetcher.AddPage("https://example.com/contact")
+
+			crawler := New(Options{
+				Workers:        1,
+				Fetcher:        mockFetcher,
+				FollowBehavior: FollowSameDomain,
+				IgnoreWWW:      tt.ignoreWWW,
+				Normalize:      web.NormalizeOptions{StripWWW: tt.stripWWW},
+			})
+			err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+				func(ctx context.Context, result *Result) {})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, mockFetcher.RequestedURLs())
+		})
+	}
+}
+
+func TestCrawler_Normalizer(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com",
+		"/Docs?b=2&a=1",
+		"/Docs?b=2&a=1#intro",
+		"/docs?a=1&b=2",
+		"/private")
+	mockFetcher.AddPage("https://example.com/Docs?b=2&a=1")
+	mockFetcher.AddPage("https://example.com/docs?a=1&b=2")
+
+	// Keep the case of paths and the order of query parameters
+	normalizer := func(raw string) (string, error) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", err
+		}
+		if u.Path == "/private" {
+			return "", errors.New("private")
+		}
+		u.Scheme = "https"
+		u.Fragment = ""
+		return u.String(), nil
+	}
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		Normalizer:     normalizer,
+	})
+	require.NoError(t, crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {}))
+
+	assert.ElementsMatch(t, []string{
+		"https://example.com",
+		"https://example.com/Docs?b=2&a=1",
+		"https://example.com/docs?a=1&b=2",
+	}, mockFetcher.RequestedURLs())
+}
+
+func TestCrawler_FetchOnly(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	pages := map[string][]string{
+		"https://example.com":   {"/a", "/b"},
+		"https://example.com/a": {"/b"},
+		"https://example.com/b": nil,
+	}
+	for rawURL, links := range pages {
+		response := fetch.NewMockResponse(rawURL, links...)
+		response.HTML = "<html><body>" + rawURL + "</body></html>"
+		mockFetcher.AddResponse(rawURL, response)
+	}
+
+	mockParser := NewMockParser()
+	mockParser.SetParseFunc(func(ctx context.Context, page *fetch.Response) (any, error) {
+		t.Errorf("unexpected parse of %s", page.URL)
+		return nil, nil
+	})
+	htmlCache := cache.NewInMemoryCache()
+	opts := Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		Cache:          htmlCache,
+		DefaultParser:  mockParser,
+		FollowBehavior: FollowSameDomain,
+		FetchOnly:      true,
+	}
+
+	results := map[string]*Result{}
+	err := New(opts).Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			results[result.URL.String()] = result
+		})
+	require.NoError(t, err)
+
+	// Every page is fetched and cached, and the results carry no content
+	require.Len(t, results, 3)
+	for rawURL, result := range results {
+		assert.Equal(t, 200, result.StatusCode)
+		assert.Nil(t, result.Parsed)
+		assert.Nil(t, result.Response)
+		_, err := htmlCache.Get(context.Background(), rawURL)
+		assert.NoError(t, err, rawURL)
+	}
+	assert.Equal(t, []string{"https://example.com/a", "https://example.com/b"},
+		results["https://example.com"].Links)
+
+	// The callback is optional
+	htmlCache = cache.NewInMemoryCache()
+	opts.Cache = htmlCache
+	require.NoError(t, New(opts).Crawl(context.Background(), []string{"https://example.com/b"}, nil))
+	_, err = htmlCache.Get(context.Background(), "https://example.com/b")
+	assert.NoError(t, err)
 }
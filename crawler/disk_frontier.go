@@ -0,0 +1,318 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultDiskSegmentSize is the number of entries stored in each segment file
+// of a DiskFrontier when no segment size is given.
+const DefaultDiskSegmentSize = 10000
+
+// segmentExt is the file extension of DiskFrontier segment files.
+const segmentExt = ".jsonl"
+
+// DiskFrontier is a FIFO Frontier that spills its entries to segment files in
+// a directory, so that it holds any number of URLs using bounded memory. At
+// most two segments are held in memory: the oldest, which entries are popped
+// from, and the newest, which entries are pushed to. Push never returns
+// ErrFrontierFull, but returns an error if a segment cannot be written.
+//
+// Close writes the entries held in memory to the directory. A DiskFrontier
+// created on the same directory afterwards continues with the remaining
+// entries, so it does not implement FrontierSnapshotter.
+type DiskFrontier struct {
+	mutex       sync.Mutex
+	dir         string
+	segmentSize int
+	segments    []uint64
+	nextSegment uint64
+	head        []FrontierEntry
+	headSegment uint64
+	tail        []FrontierEntry
+	count       int
+	ready       chan struct{}
+}
+
+// NewDiskFrontier creates a Frontier that stores segments of segmentSize
+// entries in dir, which is created if needed. Entries left in dir by a
+// previous DiskFrontier are popped first.
+func NewDiskFrontier(dir string, segmentSize int) (*DiskFrontier, error) {
+	if segmentSize <= 0 {
+		segmentSize = DefaultDiskSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create frontier directory: %w", err)
+	}
+	f := &DiskFrontier{
+		dir:         dir,
+		segmentSize: segmentSize,
+		nextSegment: 1,
+		ready:       make(chan struct{}, 1),
+	}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Push adds an entry, writing the newest segment to disk once it is full.
+func (f *DiskFrontier) Push(entry FrontierEntry) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.tail)+1 >= f.segmentSize {
+		if err := f.writeSegment(f.nextSegment, append(f.tail, entry)); err != nil {
+			return err
+		}
+		f.segments = append(f.segments, f.nextSegment)
+		f.nextSegment++
+		f.tail = nil
+	} else {
+		f.tail = append(f.tail, entry)
+	}
+	f.count++
+	f.signal()
+	return nil
+}
+
+// Pop returns the oldest entry, blocking until one is available. It returns
+// an error if a segment cannot be read.
+func (f *DiskFrontier) Pop(ctx context.Context) (FrontierEntry, error) {
+	for {
+		f.mutex.Lock()
+		entry, ok, err := f.next()
+		if ok && f.count > 0 {
+			f.signal()
+		}
+		f.mutex.Unlock()
+		if ok || err != nil {
+			return entry, err
+		}
+		select {
+		case <-f.ready:
+		case <-ctx.Done():
+			return FrontierEntry{}, ctx.Err()
+		}
+	}
+}
+
+// Len returns the number of entries waiting in the frontier, including those
+// on disk.
+func (f *DiskFrontier) Len() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.count
+}
+
+// Close writes the entries held in memory to disk. The frontier may still be
+// used afterwards.
+func (f *DiskFrontier) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.headSegment != 0 {
+		if len(f.head) > 0 {
+			if err := f.writeSegment(f.headSegment, f.head); err != nil {
+				return err
+			}
+			f.segments = append([]uint64{f.headSegment}, f.segments...)
+		} else if err := f.removeSegment(f.headSegment); err != nil {
+			return err
+		}
+		f.head = nil
+		f.headSegment = 0
+	}
+	if len(f.tail) > 0 {
+		if err := f.writeSegment(f.nextSegment, f.tail); err != nil {
+			return err
+		}
+		f.segments = append(f.segments, f.nextSegment)
+		f.nextSegment++
+		f.tail = nil
+	}
+	return nil
+}
+
+// next removes and returns the oldest entry, reading the next segment once
+// the current one is drained. The caller must hold the mutex.
+func (f *DiskFrontier) next() (FrontierEntry, bool, error) {
+	if len(f.head) == 0 {
+		if err := f.advance(); err != nil {
+			return FrontierEntry{}, false, err
+		}
+		if len(f.head) == 0 {
+			return FrontierEntry{}, false, nil
+		}
+	}
+	entry := f.head[0]
+	f.head = f.head[1:]
+	f.count--
+	return entry, true, nil
+}
+
+// advance replaces the drained head segment with the oldest segment on disk,
+// or with the newest segment when none are on disk. A segment's file is kept
+// until it is drained, so that Close only has to rewrite it. The caller must
+// hold the mutex.
+func (f *DiskFrontier) advance() error {
+	if f.headSegment != 0 {
+		if err := f.removeSegment(f.headSegment); err != nil {
+			return err
+		}
+		f.headSegment = 0
+	}
+	switch {
+	case len(f.segments) > 0:
+		entries, err := f.readSegment(f.segments[0])
+		if err != nil {
+			return err
+		}
+		f.head = entries
+		f.headSegment = f.segments[0]
+		f.segments = f.segments[1:]
+	case len(f.tail) > 0:
+		// The newest entries are only written to disk on Close, under a
+		// number reserved now to keep them ahead of later segments
+		f.head = f.tail
+		f.headSegment = f.nextSegment
+		f.nextSegment++
+		f.tail = nil
+	}
+	return nil
+}
+
+// load finds the segments left in the directory and counts their entries.
+func (f *DiskFrontier) load() error {
+	files, err := os.ReadDir(f.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read frontier directory: %w", err)
+	}
+	for _, file := range files {
+		name, ok := strings.CutSuffix(file.Name(), segmentExt)
+		if !ok || file.IsDir() {
+			continue
+		}
+		segment, err := strconv.ParseUint(name, 10, 64)
+		if err != nil || segment == 0 {
+			continue
+		}
+		count, err := f.countSegment(segment)
+		if err != nil {
+			return err
+		}
+		f.segments = append(f.segments, segment)
+		f.count += count
+	}
+	sort.Slice(f.segments, func(i, j int) bool { return f.segments[i] < f.segments[j] })
+	if len(f.segments) > 0 {
+		f.nextSegment = f.segments[len(f.segments)-1] + 1
+	}
+	return nil
+}
+
+// segmentPath returns the path of a segment file. Numbers are zero padded so
+// that the files sort in order.
+func (f *DiskFrontier) segmentPath(segment uint64) string {
+	return filepath.Join(f.dir, fmt.Sprintf("%020d%s", segment, segmentExt))
+}
+
+// writeSegment writes entries to a segment file, one JSON object per line.
+// The file is replaced atomically so that a crash leaves no partial segment.
+func (f *DiskFrontier) writeSegment(segment uint64, entries []FrontierEntry) error {
+	path := f.segmentPath(segment)
+	file, err := os.CreateTemp(f.dir, ".segment-*")
+	if err != nil {
+		return fmt.Errorf("failed to create frontier segment: %w", err)
+	}
+	defer os.Remove(file.Name())
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to encode frontier entry: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write frontier segment: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write frontier segment: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to write frontier segment: %w", err)
+	}
+	return nil
+}
+
+// readSegment reads the entries of a segment file.
+func (f *DiskFrontier) readSegment(segment uint64) ([]FrontierEntry, error) {
+	file, err := os.Open(f.segmentPath(segment))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open frontier segment: %w", err)
+	}
+	defer file.Close()
+	var entries []FrontierEntry
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for {
+		var entry FrontierEntry
+		if err := decoder.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return entries, nil
+			}
+			return nil, fmt.Errorf("failed to decode frontier segment: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// countSegment returns the number of entries in a segment file without
+// decoding them.
+func (f *DiskFrontier) countSegment(segment uint64) (int, error) {
+	file, err := os.Open(f.segmentPath(segment))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open frontier segment: %w", err)
+	}
+	defer file.Close()
+	var count int
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := file.Read(buf)
+		count += bytes.Count(buf[:n], []byte{'\n'})
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read frontier segment: %w", err)
+		}
+	}
+}
+
+// removeSegment deletes a segment file that has been drained.
+func (f *DiskFrontier) removeSegment(segment uint64) error {
+	err := os.Remove(f.segmentPath(segment))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove frontier segment: %w", err)
+	}
+	return nil
+}
+
+// signal notifies a waiting Pop that an entry is available. The caller must
+// hold the mutex.
+func (f *DiskFrontier) signal() {
+	select {
+	case f.ready <- struct{}{}:
+	default:
+	}
+}
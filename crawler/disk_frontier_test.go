@@ -0,0 +1,144 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskFrontier(t *testing.T) {
+	dir := t.TempDir()
+	frontier, err := NewDiskFrontier(dir, 3)
+	require.NoError(t, err)
+	require.Equal(t, 0, frontier.Len())
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, frontier.Push(FrontierEntry{URL: fmt.Sprintf("https://example.com/%d", i), Depth: i}))
+	}
+	require.Equal(t, 10, frontier.Len())
+
+	// Full segments are written to disk
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	for i := 0; i < 10; i++ {
+		entry, err := frontier.Pop(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, FrontierEntry{URL: fmt.Sprintf("https://example.com/%d", i), Depth: i}, entry)
+		require.Equal(t, 9-i, frontier.Len())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = frontier.Pop(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestDiskFrontier_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	frontier, err := NewDiskFrontier(dir, 3)
+	require.NoError(t, err)
+	for i := 0; i < 8; i++ {
+		require.NoError(t, frontier.Push(FrontierEntry{URL: fmt.Sprintf("https://example.com/%d", i)}))
+	}
+	// Leave a partly drained segment and a partly filled one in memory
+	for i := 0; i < 4; i++ {
+		_, err := frontier.Pop(context.Background())
+		require.NoError(t, err)
+	}
+	require.NoError(t, frontier.Close())
+
+	reopened, err := NewDiskFrontier(dir, 3)
+	require.NoError(t, err)
+	require.Equal(t, 4, reopened.Len())
+	require.NoError(t, reopened.Push(FrontierEntry{URL: "https://example.com/8"}))
+	for i := 4; i < 9; i++ {
+		entry, err := reopened.Pop(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("https://example.com/%d", i), entry.URL)
+	}
+	require.NoError(t, reopened.Close())
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestDiskFrontier_Concurrent(t *testing.T) {
+	frontier, err := NewDiskFrontier(t.TempDir(), 7)
+	require.NoError(t, err)
+
+	const producers, perProducer = 4, 250
+	var producersDone sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		producersDone.Add(1)
+		go func() {
+			defer producersDone.Done()
+			for i := 0; i < perProducer; i++ {
+				assert.NoError(t, frontier.Push(FrontierEntry{URL: fmt.Sprintf("https://example.com/%d/%d", p, i)}))
+			}
+		}()
+	}
+
+	var mutex sync.Mutex
+	seen := map[string]bool{}
+	var consumersDone sync.WaitGroup
+	for c := 0; c < 4; c++ {
+		consumersDone.Add(1)
+		go func() {
+			defer consumersDone.Done()
+			for {
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				entry, err := frontier.Pop(ctx)
+				cancel()
+				if err != nil {
+					return
+				}
+				mutex.Lock()
+				assert.False(t, seen[entry.URL], entry.URL)
+				seen[entry.URL] = true
+				mutex.Unlock()
+			}
+		}()
+	}
+	producersDone.Wait()
+	consumersDone.Wait()
+
+	assert.Len(t, seen, producers*perProducer)
+	assert.Equal(t, 0, frontier.Len())
+}
+
+func TestCrawler_DiskFrontier(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	links := make([]string, 20)
+	for i := range links {
+		links[i] = fmt.Sprintf("/%d", i)
+		mockFetcher.AddPage(fmt.Sprintf("https://example.com/%d", i))
+	}
+	mockFetcher.AddPage("https://example.com", links...)
+
+	frontier, err := NewDiskFrontier(t.TempDir(), 4)
+	require.NoError(t, err)
+	crawler := New(Options{
+		Workers:        3,
+		Fetcher:        mockFetcher,
+		Frontier:       frontier,
+		FollowBehavior: FollowSameDomain,
+	})
+	err = crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			assert.NoError(t, result.Error)
+		})
+	require.NoError(t, err)
+
+	assert.Len(t, mockFetcher.RequestedURLs(), 21)
+	assert.Equal(t, 0, frontier.Len())
+}
@@ -0,0 +1,56 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/myzie/web/fetch"
+)
+
+// FetcherRule selects the fetcher used for matching URLs. A rule matches a
+// URL when its Domain and Pattern, if set, both match. A rule with neither
+// set matches every URL.
+type FetcherRule struct {
+	// Domain matches URLs on the host and its subdomains.
+	Domain string
+
+	// Pattern matches against the full URL.
+	Pattern *regexp.Regexp
+
+	// Fetcher is the name of the fetcher in Options.Fetchers.
+	Fetcher string
+}
+
+// matches reports whether the rule applies to the URL.
+func (r FetcherRule) matches(u *url.URL) bool {
+	if r.Domain != "" && !matchesDomain(u, r.Domain) {
+		return false
+	}
+	return r.Pattern == nil || r.Pattern.MatchString(u.String())
+}
+
+// matchesDomain reports whether the URL is on the domain or a subdomain.
+func matchesDomain(u *url.URL, domain string) bool {
+	host := strings.ToLower(u.Hostname())
+	domain = strings.ToLower(domain)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// fetcherFor returns the fetcher for the URL along with its name. The first
+// matching rule chooses a fetcher from Options.Fetchers, and the default
+// fetcher is used when no rule matches.
+func (c *Crawler) fetcherFor(u *url.URL) (fetch.Fetcher, string, error) {
+	for _, rule := range c.fetcherRules {
+		if !rule.matches(u) {
+			continue
+		}
+		fetcher, ok := c.fetchers[rule.Fetcher]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown fetcher: %q", rule.Fetcher)
+		}
+		return fetcher, rule.Fetcher, nil
+	}
+	return c.fetcher, c.getFetcherName(), nil
+}
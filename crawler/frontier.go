@@ -0,0 +1,373 @@
+package crawler
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrFrontierFull is returned by Frontier.Push when there is no room for
+// another entry.
+var ErrFrontierFull = errors.New("frontier is full")
+
+// FrontierEntry is a URL waiting to be crawled, along with the information
+// needed to crawl it. Seed is the scope of the seed given to CrawlSeeds that
+// the URL was reached from, or nil if the Options apply.
+type FrontierEntry struct {
+	URL      string      `json:"url"`
+	Depth    int         `json:"depth,omitempty"`
+	Parent   string      `json:"parent,omitempty"`
+	Requeues int         `json:"requeues,omitempty"`
+	Seed     *SeedConfig `json:"seed,omitempty"`
+}
+
+// Frontier holds the URLs waiting to be crawled. Implementations must be safe
+// for concurrent use by multiple workers. Supplying a persistent or shared
+// Frontier via Options.Frontier allows crawls to be resumed or distributed.
+type Frontier interface {
+	// Push adds an entry to the frontier. It returns ErrFrontierFull if the
+	// frontier cannot accept more entries.
+	Push(entry FrontierEntry) error
+
+	// Pop removes and returns the next entry, blocking until one is available
+	// or the context is done.
+	Pop(ctx context.Context) (FrontierEntry, error)
+
+	// Len returns the number of entries waiting in the frontier.
+	Len() int
+}
+
+// FrontierSnapshotter is implemented by frontiers that can list their pending
+// entries so that they are included in checkpoints. Frontiers that persist
+// their own state need not implement it.
+type FrontierSnapshotter interface {
+	// Snapshot returns a copy of the entries waiting in the frontier.
+	Snapshot() []FrontierEntry
+}
+
+// FrontierCompleter is implemented by frontiers that need to know when a
+// popped entry has finished being processed, such as BFSFrontier. The crawler
+// calls Complete once for each entry returned by Pop.
+type FrontierCompleter interface {
+	// Complete reports that the entry has been processed.
+	Complete(entry FrontierEntry)
+}
+
+// ChannelFrontier is an in-memory Frontier backed by a buffered channel. It is
+// the default when no Frontier is configured.
+type ChannelFrontier struct {
+	mutex   sync.Mutex
+	entries chan FrontierEntry
+}
+
+// NewChannelFrontier creates a Frontier that holds up to size entries.
+func NewChannelFrontier(size int) *ChannelFrontier {
+	return &ChannelFrontier{entries: make(chan FrontierEntry, size)}
+}
+
+// Push adds an entry, returning ErrFrontierFull if the channel is full.
+func (f *ChannelFrontier) Push(entry FrontierEntry) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	select {
+	case f.entries <- entry:
+		return nil
+	default:
+		return ErrFrontierFull
+	}
+}
+
+// Pop returns the next entry, blocking until one is available.
+func (f *ChannelFrontier) Pop(ctx context.Context) (FrontierEntry, error) {
+	select {
+	case entry := <-f.entries:
+		return entry, nil
+	case <-ctx.Done():
+		return FrontierEntry{}, ctx.Err()
+	}
+}
+
+// Len returns the number of entries waiting in the channel.
+func (f *ChannelFrontier) Len() int {
+	return len(f.entries)
+}
+
+// Snapshot returns the entries waiting in the channel, in order. The channel
+// is drained and refilled, so entries popped concurrently are not included.
+func (f *ChannelFrontier) Snapshot() []FrontierEntry {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var entries []FrontierEntry
+	for {
+		select {
+		case entry := <-f.entries:
+			entries = append(entries, entry)
+		default:
+			for _, entry := range entries {
+				f.entries <- entry
+			}
+			return entries
+		}
+	}
+}
+
+// PriorityFunc scores a URL found at the given depth. URLs with higher scores
+// are crawled first.
+type PriorityFunc func(url string, depth int) int
+
+// PriorityFrontier is an in-memory Frontier that pops the entry with the
+// highest priority first. Entries with equal priority are popped in the order
+// they were pushed. It is the default when Options.Priority is set.
+type PriorityFrontier struct {
+	mutex    sync.Mutex
+	entries  priorityHeap
+	size     int
+	sequence int64
+	priority PriorityFunc
+	ready    chan struct{}
+}
+
+// NewPriorityFrontier creates a Frontier that holds up to size entries,
+// ordered by the scores given by the priority function.
+func NewPriorityFrontier(size int, priority PriorityFunc) *PriorityFrontier {
+	return &PriorityFrontier{
+		size:     size,
+		priority: priority,
+		ready:    make(chan struct{}, 1),
+	}
+}
+
+// Push adds an entry, returning ErrFrontierFull if the frontier is full.
+func (f *PriorityFrontier) Push(entry FrontierEntry) error {
+	score := f.priority(entry.URL, entry.Depth)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.entries) >= f.size {
+		return ErrFrontierFull
+	}
+	heap.Push(&f.entries, priorityItem{entry: entry, score: score, sequence: f.sequence})
+	f.sequence++
+	f.signal()
+	return nil
+}
+
+// Pop returns the entry with the highest priority, blocking until one is
+// available.
+func (f *PriorityFrontier) Pop(ctx context.Context) (FrontierEntry, error) {
+	for {
+		f.mutex.Lock()
+		if len(f.entries) > 0 {
+			item := heap.Pop(&f.entries).(priorityItem)
+			// Wake another waiting worker if entries remain
+			if len(f.entries) > 0 {
+				f.signal()
+			}
+			f.mutex.Unlock()
+			return item.entry, nil
+		}
+		f.mutex.Unlock()
+		select {
+		case <-f.ready:
+		case <-ctx.Done():
+			return FrontierEntry{}, ctx.Err()
+		}
+	}
+}
+
+// Len returns the number of entries waiting in the frontier.
+func (f *PriorityFrontier) Len() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.entries)
+}
+
+// Snapshot returns the entries waiting in the frontier, in the order they
+// would be popped.
+func (f *PriorityFrontier) Snapshot() []FrontierEntry {
+	f.mutex.Lock()
+	items := append(priorityHeap{}, f.entries...)
+	f.mutex.Unlock()
+	sort.Slice(items, items.Less)
+	entries := make([]FrontierEntry, len(items))
+	for i, item := range items {
+		entries[i] = item.entry
+	}
+	return entries
+}
+
+// signal notifies a waiting Pop that an entry is available. The caller must
+// hold the mutex.
+func (f *PriorityFrontier) signal() {
+	select {
+	case f.ready <- struct{}{}:
+	default:
+	}
+}
+
+// priorityItem is an entry in a PriorityFrontier.
+type priorityItem struct {
+	entry    FrontierEntry
+	score    int
+	sequence int64
+}
+
+// priorityHeap implements heap.Interface, ordering items by descending score
+// and then by ascending sequence.
+type priorityHeap []priorityItem
+
+func (h priorityHeap) Len() int { return len(h) }
+
+func (h priorityHeap) Less(i, j int) bool {
+	if h[i].score != h[j].score {
+		return h[i].score > h[j].score
+	}
+	return h[i].sequence < h[j].sequence
+}
+
+func (h priorityHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *priorityHeap) Push(x any) { *h = append(*h, x.(priorityItem)) }
+
+func (h *priorityHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// BFSFrontier is an in-memory Frontier that crawls breadth-first: every URL
+// at one depth is processed before any URL at the next depth is released.
+// The next depth is released only once the current depth's queue is empty
+// and every popped entry has been completed. URLs requeued after a rate limit
+// are released when they become due, even if a deeper level has started. It
+// is the default when Options.Strategy is StrategyBFS.
+type BFSFrontier struct {
+	mutex  sync.Mutex
+	levels map[int][]FrontierEntry
+	depth  int
+	count  int
+	active int
+	size   int
+	ready  chan struct{}
+}
+
+// NewBFSFrontier creates a breadth-first Frontier that holds up to size
+// entries.
+func NewBFSFrontier(size int) *BFSFrontier {
+	return &BFSFrontier{
+		levels: make(map[int][]FrontierEntry),
+		size:   size,
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// Push adds an entry, returning ErrFrontierFull if the frontier is full.
+func (f *BFSFrontier) Push(entry FrontierEntry) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.count >= f.size {
+		return ErrFrontierFull
+	}
+	f.levels[entry.Depth] = append(f.levels[entry.Depth], entry)
+	f.count++
+	f.signal()
+	return nil
+}
+
+// Pop returns the next entry at the current depth, blocking until one is
+// available or the next depth is released.
+func (f *BFSFrontier) Pop(ctx context.Context) (FrontierEntry, error) {
+	for {
+		f.mutex.Lock()
+		if entry, ok := f.next(); ok {
+			f.active++
+			if f.count > 0 {
+				f.signal()
+			}
+			f.mutex.Unlock()
+			return entry, nil
+		}
+		f.mutex.Unlock()
+		select {
+		case <-f.ready:
+		case <-ctx.Done():
+			return FrontierEntry{}, ctx.Err()
+		}
+	}
+}
+
+// Complete records that a popped entry has been processed, which may release
+// the next depth.
+func (f *BFSFrontier) Complete(entry FrontierEntry) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.active--
+	if f.active == 0 && f.count > 0 {
+		f.signal()
+	}
+}
+
+// Len returns the number of entries waiting in the frontier.
+func (f *BFSFrontier) Len() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.count
+}
+
+// Snapshot returns the entries waiting in the frontier, shallowest first.
+func (f *BFSFrontier) Snapshot() []FrontierEntry {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var entries []FrontierEntry
+	for _, depth := range f.depths() {
+		entries = append(entries, f.levels[depth]...)
+	}
+	return entries
+}
+
+// next removes and returns the first entry at the shallowest depth, provided
+// that depth has been released. The caller must hold the mutex.
+func (f *BFSFrontier) next() (FrontierEntry, bool) {
+	depths := f.depths()
+	if len(depths) == 0 {
+		return FrontierEntry{}, false
+	}
+	depth := depths[0]
+	if depth > f.depth {
+		// Deeper entries wait until the current depth is finished
+		if f.active > 0 {
+			return FrontierEntry{}, false
+		}
+		f.depth = depth
+	}
+	entry := f.levels[depth][0]
+	if len(f.levels[depth]) == 1 {
+		delete(f.levels, depth)
+	} else {
+		f.levels[depth] = f.levels[depth][1:]
+	}
+	f.count--
+	return entry, true
+}
+
+// depths returns the depths with waiting entries in ascending order. The
+// caller must hold the mutex.
+func (f *BFSFrontier) depths() []int {
+	depths := make([]int, 0, len(f.levels))
+	for depth := range f.levels {
+		depths = append(depths, depth)
+	}
+	sort.Ints(depths)
+	return depths
+}
+
+// signal notifies a waiting Pop that an entry may be available. The caller
+// must hold the mutex.
+func (f *BFSFrontier) signal() {
+	select {
+	case f.ready <- struct{}{}:
+	default:
+	}
+}
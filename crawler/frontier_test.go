@@ -0,0 +1,298 @@
+package crawler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingFrontier wraps a ChannelFrontier and records every pushed entry.
+type recordingFrontier struct {
+	*ChannelFrontier
+	mutex  sync.Mutex
+	pushed []FrontierEntry
+}
+
+func (f *recordingFrontier) Push(entry FrontierEntry) error {
+	f.mutex.Lock()
+	f.pushed = append(f.pushed, entry)
+	f.mutex.Unlock()
+	return f.ChannelFrontier.Push(entry)
+}
+
+func TestChannelFrontier(t *testing.T) {
+	frontier := NewChannelFrontier(1)
+	require.Equal(t, 0, frontier.Len())
+
+	require.NoError(t, frontier.Push(FrontierEntry{URL: "https://example.com"}))
+	require.ErrorIs(t, frontier.Push(FrontierEntry{URL: "https://example.com/1"}), ErrFrontierFull)
+	require.Equal(t, 1, frontier.Len())
+
+	entry, err := frontier.Pop(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", entry.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = frontier.Pop(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestChannelFrontier_Snapshot(t *testing.T) {
+	frontier := NewChannelFrontier(3)
+	require.NoError(t, frontier.Push(FrontierEntry{URL: "https://example.com/1"}))
+	require.NoError(t, frontier.Push(FrontierEntry{URL: "https://example.com/2"}))
+
+	expected := []FrontierEntry{
+		{URL: "https://example.com/1"},
+		{URL: "https://example.com/2"},
+	}
+	assert.Equal(t, expected, frontier.Snapshot())
+
+	// The snapshot leaves the frontier intact
+	require.Equal(t, 2, frontier.Len())
+	entry, err := frontier.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, expected[0], entry)
+}
+
+func TestCrawler_CustomFrontier(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL:   "https://example.com",
+		Links: []*fetch.Link{{URL: "/1"}},
+	})
+	mockFetcher.AddResponse("https://example.com/1", &fetch.Response{
+		URL: "https://example.com/1",
+	})
+
+	frontier := &recordingFrontier{ChannelFrontier: NewChannelFrontier(10)}
+	crawler := New(Options{
+		MaxURLs:        10,
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		Frontier:       frontier,
+		FollowBehavior: FollowSameDomain,
+	})
+
+	var parent string
+	callback := func(ctx context.Context, result *Result) {
+		if result.URL.String() == "https://example.com/1" && result.ParentURL != nil {
+			parent = result.ParentURL.String()
+		}
+	}
+
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, callback)
+	require.NoError(t, err)
+
+	assert.Equal(t, []FrontierEntry{
+		{URL: "https://example.com"},
+		{URL: "https://example.com/1", Depth: 1, Parent: "https://example.com"},
+	}, frontier.pushed)
+	assert.Equal(t, "https://example.com", parent)
+}
+
+func TestPriorityFrontier(t *testing.T) {
+	// Shorter URLs have higher priority
+	frontier := NewPriorityFrontier(4, func(url string, depth int) int {
+		return -len(url)
+	})
+	for _, url := range []string{
+		"https://example.com/long/path",
+		"https://example.com/a",
+		"https://example.com/bb",
+		"https://example.com/c",
+	} {
+		require.NoError(t, frontier.Push(FrontierEntry{URL: url}))
+	}
+	require.ErrorIs(t, frontier.Push(FrontierEntry{URL: "https://example.com/d"}), ErrFrontierFull)
+	require.Equal(t, 4, frontier.Len())
+
+	// Ties are popped in the order pushed
+	expected := []FrontierEntry{
+		{URL: "https://example.com/a"},
+		{URL: "https://example.com/c"},
+		{URL: "https://example.com/bb"},
+		{URL: "https://example.com/long/path"},
+	}
+	assert.Equal(t, expected, frontier.Snapshot())
+	for _, want := range expected {
+		entry, err := frontier.Pop(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, want, entry)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := frontier.Pop(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// A blocked Pop receives entries pushed later
+	done := make(chan FrontierEntry)
+	go func() {
+		entry, _ := frontier.Pop(context.Background())
+		done <- entry
+	}()
+	require.NoError(t, frontier.Push(FrontierEntry{URL: "https://example.com/e"}))
+	assert.Equal(t, "https://example.com/e", (<-done).URL)
+}
+
+func TestCrawler_Priority(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/blog/2020/old-post", "/docs", "/blog", "/docs/guide")
+	mockFetcher.AddPage("https://example.com/blog/2020/old-post")
+	mockFetcher.AddPage("https://example.com/docs")
+	mockFetcher.AddPage("https://example.com/blog")
+	mockFetcher.AddPage("https://example.com/docs/guide")
+
+	// Documentation is crawled first, then shorter paths
+	crawler := New(Options{
+		Deterministic:  true,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		Priority: func(url string, depth int) int {
+			score := -len(url)
+			if strings.Contains(url, "/docs") {
+				score += 1000
+			}
+			return score
+		},
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"https://example.com",
+		"https://example.com/docs",
+		"https://example.com/docs/guide",
+		"https://example.com/blog",
+		"https://example.com/blog/2020/old-post",
+	}, mockFetcher.RequestedURLs())
+}
+
+// slowFetcher delays fetches of particular URLs.
+type slowFetcher struct {
+	fetch.Fetcher
+	delays map[string]time.Duration
+}
+
+func (f *slowFetcher) Fetch(ctx context.Context, req *fetch.Request) (*fetch.Response, error) {
+	time.Sleep(f.delays[req.URL])
+	return f.Fetcher.Fetch(ctx, req)
+}
+
+func TestBFSFrontier(t *testing.T) {
+	frontier := NewBFSFrontier(3)
+	require.NoError(t, frontier.Push(FrontierEntry{URL: "https://example.com"}))
+	require.NoError(t, frontier.Push(FrontierEntry{URL: "https://example.com/2", Depth: 1}))
+	require.NoError(t, frontier.Push(FrontierEntry{URL: "https://example.com/1", Depth: 1}))
+	require.ErrorIs(t, frontier.Push(FrontierEntry{URL: "https://example.com/3"}), ErrFrontierFull)
+	assert.Equal(t, []string{"https://example.com", "https://example.com/2", "https://example.com/1"},
+		entryURLs(frontier.Snapshot()))
+
+	seed, err := frontier.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", seed.URL)
+
+	// Depth 1 is held back until the seed is complete
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = frontier.Pop(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	done := make(chan FrontierEntry)
+	go func() {
+		entry, _ := frontier.Pop(context.Background())
+		done <- entry
+	}()
+	frontier.Complete(seed)
+	assert.Equal(t, "https://example.com/2", (<-done).URL)
+	assert.Equal(t, 1, frontier.Len())
+}
+
+func entryURLs(entries []FrontierEntry) []string {
+	urls := make([]string, len(entries))
+	for i, entry := range entries {
+		urls[i] = entry.URL
+	}
+	return urls
+}
+
+func TestCrawler_StrategyBFS(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/a", "/b")
+	mockFetcher.AddPage("https://example.com/a", "/a/1", "/a/2")
+	mockFetcher.AddPage("https://example.com/b", "/b/1")
+	mockFetcher.AddPage("https://example.com/a/1")
+	mockFetcher.AddPage("https://example.com/a/2")
+	mockFetcher.AddPage("https://example.com/b/1")
+
+	// The slow page would let depth 2 start early without the BFS guarantee
+	fetcher := &slowFetcher{
+		Fetcher: mockFetcher,
+		delays:  map[string]time.Duration{"https://example.com/b": 100 * time.Millisecond},
+	}
+	crawler := New(Options{
+		Workers:        3,
+		Fetcher:        fetcher,
+		FollowBehavior: FollowSameDomain,
+		Strategy:       StrategyBFS,
+	})
+
+	var depths []int
+	mu := sync.Mutex{}
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			depths = append(depths, result.Depth)
+		})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 1, 2, 2, 2}, depths)
+}
+
+// slowPopFrontier wraps a ChannelFrontier and delays each popped entry, which
+// widens the gap between an entry leaving the frontier and being processed.
+type slowPopFrontier struct {
+	*ChannelFrontier
+	delay time.Duration
+}
+
+func (f *slowPopFrontier) Pop(ctx context.Context) (FrontierEntry, error) {
+	entry, err := f.ChannelFrontier.Pop(ctx)
+	if err == nil {
+		time.Sleep(f.delay)
+	}
+	return entry, err
+}
+
+func TestCrawler_IdleAfterPop(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/1")
+	mockFetcher.AddPage("https://example.com/1", "/2")
+	mockFetcher.AddPage("https://example.com/2")
+
+	// Each popped entry is out of the frontier for longer than it takes the
+	// idle monitor to give up on an empty frontier
+	crawler := New(Options{
+		Workers:        2,
+		Fetcher:        mockFetcher,
+		Frontier:       &slowPopFrontier{ChannelFrontier: NewChannelFrontier(10), delay: 3 * idleCheckInterval},
+		FollowBehavior: FollowSameDomain,
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			assert.NoError(t, result.Error)
+		})
+	require.NoError(t, err)
+
+	assert.Len(t, mockFetcher.RequestedURLs(), 3)
+	assert.Equal(t, ReasonIdle, crawler.GetStats().GetStopReason())
+}
@@ -0,0 +1,141 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Edge is a link from one page to another.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// LinkGraph records the links between pages, with each link recorded once.
+// All methods are thread-safe.
+type LinkGraph struct {
+	mutex sync.RWMutex
+	edges map[string]map[string]struct{}
+	count int
+}
+
+// NewLinkGraph creates an empty LinkGraph.
+func NewLinkGraph() *LinkGraph {
+	return &LinkGraph{edges: make(map[string]map[string]struct{})}
+}
+
+// AddEdge records a link, reporting false if it was already recorded.
+func (g *LinkGraph) AddEdge(from, to string) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	targets, exists := g.edges[from]
+	if !exists {
+		targets = make(map[string]struct{})
+		g.edges[from] = targets
+	}
+	if _, exists := targets[to]; exists {
+		return false
+	}
+	targets[to] = struct{}{}
+	g.count++
+	return true
+}
+
+// Len returns the number of links recorded.
+func (g *LinkGraph) Len() int {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.count
+}
+
+// Links returns the pages linked to from the given page, sorted by URL.
+func (g *LinkGraph) Links(from string) []string {
+	g.mutex.RLock()
+	targets := make([]string, 0, len(g.edges[from]))
+	for to := range g.edges[from] {
+		targets = append(targets, to)
+	}
+	g.mutex.RUnlock()
+	sort.Strings(targets)
+	return targets
+}
+
+// Edges returns a copy of the links, sorted by source and then target.
+func (g *LinkGraph) Edges() []Edge {
+	g.mutex.RLock()
+	edges := make([]Edge, 0, g.count)
+	for from, targets := range g.edges {
+		for to := range targets {
+			edges = append(edges, Edge{From: from, To: to})
+		}
+	}
+	g.mutex.RUnlock()
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// Nodes returns every page that is the source or target of a link, sorted
+// by URL.
+func (g *LinkGraph) Nodes() []string {
+	g.mutex.RLock()
+	seen := make(map[string]bool, len(g.edges))
+	for from, targets := range g.edges {
+		seen[from] = true
+		for to := range targets {
+			seen[to] = true
+		}
+	}
+	g.mutex.RUnlock()
+	nodes := make([]string, 0, len(seen))
+	for node := range seen {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// clear removes all links.
+func (g *LinkGraph) clear() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.edges = make(map[string]map[string]struct{})
+	g.count = 0
+}
+
+// MarshalJSON encodes the graph as its sorted nodes and edges.
+func (g *LinkGraph) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Nodes []string `json:"nodes"`
+		Edges []Edge   `json:"edges"`
+	}{
+		Nodes: g.Nodes(),
+		Edges: g.Edges(),
+	})
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language, for example to be
+// rendered with "dot -Tsvg".
+func (g *LinkGraph) WriteDOT(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprintln(writer, "digraph links {")
+	for _, edge := range g.Edges() {
+		fmt.Fprintf(writer, "\t%s -> %s;\n", dotQuote(edge.From), dotQuote(edge.To))
+	}
+	fmt.Fprintln(writer, "}")
+	return writer.Flush()
+}
+
+// dotQuote returns s as a quoted DOT identifier.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,104 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkGraph(t *testing.T) {
+	graph := NewLinkGraph()
+	assert.True(t, graph.AddEdge("https://example.com", "https://example.com/b"))
+	assert.True(t, graph.AddEdge("https://example.com", "https://example.com/a"))
+	assert.False(t, graph.AddEdge("https://example.com", "https://example.com/a"))
+	assert.True(t, graph.AddEdge("https://example.com/a", `https://example.com/"quoted"`))
+
+	assert.Equal(t, 3, graph.Len())
+	assert.Equal(t, []string{"https://example.com/a", "https://example.com/b"},
+		graph.Links("https://example.com"))
+	assert.Equal(t, []Edge{
+		{From: "https://example.com", To: "https://example.com/a"},
+		{From: "https://example.com", To: "https://example.com/b"},
+		{From: "https://example.com/a", To: `https://example.com/"quoted"`},
+	}, graph.Edges())
+
+	data, err := json.Marshal(graph)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"nodes": [
+			"https://example.com",
+			"https://example.com/\"quoted\"",
+			"https://example.com/a",
+			"https://example.com/b"
+		],
+		"edges": [
+			{"from": "https://example.com", "to": "https://example.com/a"},
+			{"from": "https://example.com", "to": "https://example.com/b"},
+			{"from": "https://example.com/a", "to": "https://example.com/\"quoted\""}
+		]
+	}`, string(data))
+
+	var dot strings.Builder
+	require.NoError(t, graph.WriteDOT(&dot))
+	assert.Equal(t, `digraph links {
+	"https://example.com" -> "https://example.com/a";
+	"https://example.com" -> "https://example.com/b";
+	"https://example.com/a" -> "https://example.com/\"quoted\"";
+}
+`, dot.String())
+}
+
+func TestLinkGraph_Concurrent(t *testing.T) {
+	graph := NewLinkGraph()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				graph.AddEdge("https://example.com", fmt.Sprintf("https://example.com/%d", j))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 100, graph.Len())
+}
+
+func TestCrawler_RecordGraph(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/a", "/b/", "https://other.com/")
+	mockFetcher.AddPage("https://example.com/a", "/", "/b")
+	mockFetcher.AddPage("https://example.com/b")
+
+	crawler := New(Options{
+		Workers:        2,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		RecordGraph:    true,
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {})
+	require.NoError(t, err)
+
+	// Links are normalized, and recorded even if they are not followed
+	assert.Equal(t, []Edge{
+		{From: "https://example.com", To: "https://example.com/a"},
+		{From: "https://example.com", To: "https://example.com/b"},
+		{From: "https://example.com", To: "https://other.com"},
+		{From: "https://example.com/a", To: "https://example.com"},
+		{From: "https://example.com/a", To: "https://example.com/b"},
+	}, crawler.Graph().Edges())
+
+	require.NoError(t, crawler.Reset())
+	assert.Equal(t, 0, crawler.Graph().Len())
+
+	// No graph is kept by default
+	assert.Nil(t, New(Options{}).Graph())
+}
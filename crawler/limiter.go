@@ -0,0 +1,130 @@
+package crawler
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces requests so that at most one starts per interval.
+// Callers reserve the next free slot and then sleep until it arrives. When an
+// interval is set, up to jitter is randomly added to each gap.
+type rateLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	jitter   time.Duration
+	next     time.Time
+}
+
+// jittered returns the delay plus a random duration in [0, jitter].
+func jittered(delay, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return delay
+	}
+	return delay + rand.N(jitter+1)
+}
+
+// Wait blocks until the caller may proceed or the context is cancelled.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	if l.interval > 0 {
+		l.next = l.next.Add(jittered(l.interval, l.jitter))
+	}
+	l.mutex.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Delay prevents any request from starting until the delay has elapsed.
+func (l *rateLimiter) Delay(delay time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if until := time.Now().Add(delay); l.next.Before(until) {
+		l.next = until
+	}
+}
+
+// SetInterval changes the minimum spacing between requests.
+func (l *rateLimiter) SetInterval(interval time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.interval = interval
+}
+
+// Interval returns the minimum spacing between requests.
+func (l *rateLimiter) Interval() time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.interval
+}
+
+// newGlobalLimiter returns a rateLimiter allowing the given number of
+// requests per second, or nil if rps is not positive.
+func newGlobalLimiter(rps float64) *rateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rps)}
+}
+
+// hostLimiter maintains an independent rateLimiter for each host.
+type hostLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	jitter   time.Duration
+	limiters map[string]*rateLimiter
+}
+
+func newHostLimiter(interval, jitter time.Duration) *hostLimiter {
+	return &hostLimiter{
+		interval: interval,
+		jitter:   jitter,
+		limiters: make(map[string]*rateLimiter),
+	}
+}
+
+func (h *hostLimiter) get(host string) *rateLimiter {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	limiter, exists := h.limiters[host]
+	if !exists {
+		limiter = &rateLimiter{interval: h.interval, jitter: h.jitter}
+		h.limiters[host] = limiter
+	}
+	return limiter
+}
+
+// Wait blocks until a request to the host may proceed.
+func (h *hostLimiter) Wait(ctx context.Context, host string) error {
+	return h.get(host).Wait(ctx)
+}
+
+// SetMinInterval raises the spacing for a host to at least the given interval.
+// This is used to honor a Crawl-delay advertised by the host.
+func (h *hostLimiter) SetMinInterval(host string, interval time.Duration) {
+	limiter := h.get(host)
+	if limiter.Interval() < interval {
+		limiter.SetInterval(interval)
+	}
+}
+
+// Delay holds back all requests to the host until the delay has elapsed.
+func (h *hostLimiter) Delay(host string, delay time.Duration) {
+	h.get(host).Delay(delay)
+}
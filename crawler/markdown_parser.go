@@ -0,0 +1,70 @@
+package crawler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/myzie/web"
+	"github.com/myzie/web/fetch"
+)
+
+// MarkdownParser is a Parser that converts a page's HTML to Markdown,
+// returning a string. Headings, links, lists, and code blocks are preserved.
+type MarkdownParser struct {
+	// OnlyMainContent removes boilerplate before conversion, like
+	// fetch.Request.OnlyMainContent. Elements matching web.StandardExcludeTags,
+	// such as nav, footer, forms, and cookie popups, are removed, and if the
+	// page has a <main> or <article> element only its content is converted.
+	OnlyMainContent bool
+
+	// ExcludeTags are CSS selectors of further elements to remove.
+	ExcludeTags []string
+}
+
+// Parse converts the page to Markdown.
+func (p MarkdownParser) Parse(ctx context.Context, page *fetch.Response) (any, error) {
+	html := page.HTML
+	if strings.TrimSpace(html) == "" {
+		return "", nil
+	}
+	options := web.RenderOptions{
+		OnlyMainContent: p.OnlyMainContent,
+		ExcludeTags:     p.ExcludeTags,
+	}
+	if options.HasFiltering() {
+		doc, err := web.NewDocument(html)
+		if err != nil {
+			return nil, err
+		}
+		if html, err = doc.Render(options); err != nil {
+			return nil, err
+		}
+	}
+	if p.OnlyMainContent {
+		var err error
+		if html, err = mainContent(html); err != nil {
+			return nil, err
+		}
+	}
+	markdown, err := web.Markdown(html)
+	if err != nil {
+		return nil, err
+	}
+	return strings.TrimSpace(markdown), nil
+}
+
+// mainContent returns the HTML of the page's first <main> element, or else of
+// its first <article> element. The whole page is returned if it has neither.
+func mainContent(html string) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return "", err
+	}
+	for _, selector := range []string{"main", "article"} {
+		if s := doc.Find(selector).First(); len(s.Nodes) > 0 {
+			return goquery.OuterHtml(s)
+		}
+	}
+	return html, nil
+}
@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdownParser(t *testing.T) {
+	html := `<html><body>
+<nav><a href="/">Home</a></nav>
+<div class="sidebar">Related posts</div>
+<main>
+<h1>Getting started</h1>
+<p>Read the <a href="https://example.com/docs">documentation</a> first.</p>
+<ul><li>Install</li><li>Configure</li></ul>
+<pre><code class="language-go">fmt.Println("hi")
+</code></pre>
+<button>Subscribe</button>
+</main>
+<footer>Copyright Example</footer>
+</body></html>`
+
+	article := "# Getting started\n\n" +
+		"Read the [documentation](https://example.com/docs) first.\n\n" +
+		"- Install\n- Configure\n\n" +
+		"```go\nfmt.Println(\"hi\")\n```"
+
+	tests := []struct {
+		name     string
+		parser   MarkdownParser
+		expected string
+	}{
+		{
+			name:     "whole page",
+			parser:   MarkdownParser{},
+			expected: "[Home](/)\n\nRelated posts\n\n" + article + "\n\nSubscribe\n\nCopyright Example",
+		},
+		{
+			name:     "only main content",
+			parser:   MarkdownParser{OnlyMainContent: true},
+			expected: article,
+		},
+		{
+			name:     "exclude tags",
+			parser:   MarkdownParser{ExcludeTags: []string{"nav", ".sidebar", "footer"}},
+			expected: article + "\n\nSubscribe",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := tt.parser.Parse(context.Background(), &fetch.Response{HTML: html})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, parsed)
+		})
+	}
+}
+
+func TestMarkdownParser_NoMainElement(t *testing.T) {
+	parsed, err := MarkdownParser{OnlyMainContent: true}.Parse(context.Background(), &fetch.Response{
+		HTML: `<body><nav>Menu</nav><h2>Title</h2><p>Text</p><footer>Footer</footer></body>`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "## Title\n\nText", parsed)
+
+	parsed, err = MarkdownParser{}.Parse(context.Background(), &fetch.Response{})
+	require.NoError(t, err)
+	assert.Equal(t, "", parsed)
+}
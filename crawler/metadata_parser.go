@@ -0,0 +1,105 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/myzie/web"
+	"github.com/myzie/web/fetch"
+)
+
+// PageMetadata is the metadata extracted from a page by MetadataParser.
+// Fields are empty when the page does not declare them.
+type PageMetadata struct {
+	// Title is the page title, falling back to og:title.
+	Title string `json:"title,omitempty"`
+
+	// Description is the meta description, falling back to og:description.
+	Description string `json:"description,omitempty"`
+
+	// Canonical is the canonical link, resolved against the page URL.
+	Canonical string `json:"canonical,omitempty"`
+
+	// Language is the lang attribute of the html element, lowercased.
+	Language string `json:"language,omitempty"`
+
+	// OpenGraph holds the og:* properties keyed by name without the prefix,
+	// for example "title" or "image:width". The first value of a repeated
+	// property is kept.
+	OpenGraph map[string]string `json:"open_graph,omitempty"`
+
+	// Twitter holds the twitter:* card tags keyed by name without the
+	// prefix, for example "card" or "site".
+	Twitter map[string]string `json:"twitter,omitempty"`
+}
+
+// MetadataParser is a Parser that extracts basic metadata from HTML pages,
+// returning a *PageMetadata. It is suitable as Options.DefaultParser.
+type MetadataParser struct{}
+
+// Parse extracts the metadata of the page.
+func (p MetadataParser) Parse(ctx context.Context, page *fetch.Response) (any, error) {
+	metadata := &PageMetadata{}
+	if strings.TrimSpace(page.HTML) == "" {
+		return metadata, nil
+	}
+	doc, err := web.NewDocument(page.HTML)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Title = doc.Title()
+	metadata.Description = doc.Description()
+	metadata.Canonical = resolveCanonical(page, doc.CanonicalURL())
+	metadata.Language = doc.Language()
+	for _, meta := range doc.Meta() {
+		// Sites mix up the name and property attributes, so accept either
+		key := strings.ToLower(strings.TrimSpace(meta.Property))
+		if key == "" {
+			key = strings.ToLower(strings.TrimSpace(meta.Name))
+		}
+		content := strings.TrimSpace(meta.Content)
+		if content == "" {
+			continue
+		}
+		if name, ok := strings.CutPrefix(key, "og:"); ok && name != "" {
+			metadata.OpenGraph = addMeta(metadata.OpenGraph, name, content)
+		} else if name, ok := strings.CutPrefix(key, "twitter:"); ok && name != "" {
+			metadata.Twitter = addMeta(metadata.Twitter, name, content)
+		}
+	}
+	return metadata, nil
+}
+
+// addMeta stores the value under the name unless it is already set,
+// allocating the map if needed.
+func addMeta(values map[string]string, name, value string) map[string]string {
+	if values == nil {
+		values = map[string]string{}
+	}
+	if _, exists := values[name]; !exists {
+		values[name] = value
+	}
+	return values
+}
+
+// resolveCanonical resolves a canonical link against the URL of the page. The
+// link is returned as is if either cannot be parsed.
+func resolveCanonical(page *fetch.Response, href string) string {
+	if href == "" {
+		return ""
+	}
+	base := page.FinalURL
+	if base == "" {
+		base = page.URL
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return baseURL.ResolveReference(ref).String()
+}
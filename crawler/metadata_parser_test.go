@@ -0,0 +1,123 @@
+package crawler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *fetch.Response
+		expected *PageMetadata
+	}{
+		{
+			name: "complete",
+			response: &fetch.Response{
+				URL: "https://example.com/post?ref=home",
+				HTML: `<!DOCTYPE html>
+<html lang="en-US">
+<head>
+	<title>  Hello &amp; welcome
+	</title>
+	<meta name="description" content="A post about things.">
+	<link rel="canonical" href="/post">
+	<meta property="og:title" content="Hello">
+	<meta property="og:image" content="https://example.com/a.png">
+	<meta property="og:image" content="https://example.com/b.png">
+	<meta property="og:image:width" content="1200">
+	<meta name="twitter:card" content="summary_large_image">
+	<meta name="twitter:site" content="@example">
+</head>
+<body><h1>Hello</h1></body>
+</html>`,
+			},
+			expected: &PageMetadata{
+				Title:       "Hello & welcome",
+				Description: "A post about things.",
+				Canonical:   "https://example.com/post",
+				Language:    "en-us",
+				OpenGraph: map[string]string{
+					"title":       "Hello",
+					"image":       "https://example.com/a.png",
+					"image:width": "1200",
+				},
+				Twitter: map[string]string{
+					"card": "summary_large_image",
+					"site": "@example",
+				},
+			},
+		},
+		{
+			name: "messy",
+			response: &fetch.Response{
+				URL:      "https://example.com/old",
+				FinalURL: "https://www.example.com/new/",
+				HTML: `<HTML LANG=FR><HEAD>
+<meta property="og:description" content=" Fallback description ">
+<meta name="og:title" content="Named OG title">
+<meta property="twitter:creator" content="@author">
+<meta property="og:type">
+<link rel="canonical" href="page.html">
+<p>unclosed paragraph <b>bold
+<title>Late title</title>`,
+			},
+			expected: &PageMetadata{
+				Title:       "Late title",
+				Description: "Fallback description",
+				Canonical:   "https://www.example.com/new/page.html",
+				Language:    "fr",
+				OpenGraph: map[string]string{
+					"description": "Fallback description",
+					"title":       "Named OG title",
+				},
+				Twitter: map[string]string{"creator": "@author"},
+			},
+		},
+		{
+			name:     "empty",
+			response: &fetch.Response{URL: "https://example.com"},
+			expected: &PageMetadata{},
+		},
+		{
+			name: "no metadata",
+			response: &fetch.Response{
+				URL:  "https://example.com",
+				HTML: "<p>Just text",
+			},
+			expected: &PageMetadata{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := MetadataParser{}.Parse(context.Background(), tt.response)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, parsed)
+		})
+	}
+}
+
+func TestCrawler_MetadataParser(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL:  "https://example.com",
+		HTML: `<html lang="en"><head><title>Home</title></head></html>`,
+	})
+
+	crawler := New(Options{
+		Workers:       1,
+		Fetcher:       mockFetcher,
+		DefaultParser: MetadataParser{},
+	})
+	var parsed any
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			parsed = result.Parsed
+		})
+	require.NoError(t, err)
+	assert.Equal(t, &PageMetadata{Title: "Home", Language: "en"}, parsed)
+}
@@ -0,0 +1,45 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/myzie/web/fetch"
+)
+
+// NamedParser is a parser along with the name its result is stored under by
+// a MultiParser.
+type NamedParser struct {
+	Name   string
+	Parser Parser
+}
+
+// MultiParser is a Parser that runs several parsers on each page and combines
+// their results into a map[string]any keyed by parser name. A parser that
+// fails does not stop the others: its result is left out of the map, and its
+// error is included in the returned error, which joins the errors of every
+// failed parser. The map is returned even if some parsers fail.
+type MultiParser struct {
+	parsers []NamedParser
+}
+
+// NewMultiParser creates a parser that runs the given parsers in order.
+func NewMultiParser(parsers ...NamedParser) *MultiParser {
+	return &MultiParser{parsers: parsers}
+}
+
+// Parse runs each parser on the page.
+func (p *MultiParser) Parse(ctx context.Context, page *fetch.Response) (any, error) {
+	results := make(map[string]any, len(p.parsers))
+	var errs []error
+	for _, parser := range p.parsers {
+		result, err := parser.Parser.Parse(ctx, page)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parser %s: %w", parser.Name, err))
+			continue
+		}
+		results[parser.Name] = result
+	}
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,41 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiParser(t *testing.T) {
+	errFailed := errors.New("failed")
+	failing := NewMockParser()
+	failing.SetParseFunc(func(ctx context.Context, page *fetch.Response) (any, error) {
+		return nil, errFailed
+	})
+
+	parser := NewMultiParser(
+		NamedParser{Name: "title", Parser: namedParser("Example")},
+		NamedParser{Name: "broken", Parser: failing},
+		NamedParser{Name: "content", Parser: namedParser("Hello")},
+	)
+	result, err := parser.Parse(context.Background(), &fetch.Response{URL: "https://example.com"})
+
+	// A failing parser does not prevent the others from running
+	require.ErrorIs(t, err, errFailed)
+	assert.EqualError(t, err, "parser broken: failed")
+	assert.Equal(t, map[string]any{
+		"title":   "Example",
+		"content": "Hello",
+	}, result)
+}
+
+func TestMultiParser_NoErrors(t *testing.T) {
+	parser := NewMultiParser(NamedParser{Name: "title", Parser: namedParser("Example")})
+	result, err := parser.Parse(context.Background(), &fetch.Response{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"title": "Example"}, result)
+}
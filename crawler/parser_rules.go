@@ -0,0 +1,45 @@
+package crawler
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+)
+
+// ParserRule selects the parser used for matching URLs. A rule matches a URL
+// when each of its Domain, Path, Pattern, and Match that is set matches. A
+// rule with none set matches every URL.
+type ParserRule struct {
+	// Domain matches URLs on the host and its subdomains.
+	Domain string
+
+	// Path is a glob matched against the URL path using path.Match, for
+	// example "/products/*". A "*" does not match across "/".
+	Path string
+
+	// Pattern matches against the full URL.
+	Pattern *regexp.Regexp
+
+	// Match is an arbitrary predicate on the URL.
+	Match func(u *url.URL) bool
+
+	// Parser parses the pages of matching URLs. If nil, matching pages are
+	// not parsed.
+	Parser Parser
+}
+
+// matches reports whether the rule applies to the URL.
+func (r ParserRule) matches(u *url.URL) bool {
+	if r.Domain != "" && !matchesDomain(u, r.Domain) {
+		return false
+	}
+	if r.Path != "" {
+		if ok, _ := path.Match(r.Path, u.EscapedPath()); !ok {
+			return false
+		}
+	}
+	if r.Pattern != nil && !r.Pattern.MatchString(u.String()) {
+		return false
+	}
+	return r.Match == nil || r.Match(u)
+}
@@ -0,0 +1,98 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// namedParser returns its name as the parsed result.
+type namedParser string
+
+func (p namedParser) Parse(ctx context.Context, page *fetch.Response) (any, error) {
+	return string(p), nil
+}
+
+func TestParserRule_Matches(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     ParserRule
+		url      string
+		expected bool
+	}{
+		{name: "empty", rule: ParserRule{}, url: "https://example.com/a", expected: true},
+		{name: "domain", rule: ParserRule{Domain: "example.com"}, url: "https://www.example.com/a", expected: true},
+		{name: "other domain", rule: ParserRule{Domain: "example.com"}, url: "https://example.org/a", expected: false},
+		{name: "path", rule: ParserRule{Path: "/products/*"}, url: "https://example.com/products/1", expected: true},
+		{name: "nested path", rule: ParserRule{Path: "/products/*"}, url: "https://example.com/products/1/reviews", expected: false},
+		{name: "pattern", rule: ParserRule{Pattern: regexp.MustCompile(`\?id=\d+$`)}, url: "https://example.com/p?id=12", expected: true},
+		{
+			name: "predicate",
+			rule: ParserRule{Match: func(u *url.URL) bool {
+				return strings.HasPrefix(u.Path, "/blog/")
+			}},
+			url:      "https://example.com/blog/post",
+			expected: true,
+		},
+		{
+			name:     "all conditions",
+			rule:     ParserRule{Domain: "example.com", Path: "/blog/*"},
+			url:      "https://example.org/blog/post",
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, tt.rule.matches(u))
+		})
+	}
+}
+
+func TestCrawler_ParserRules(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/products/1", "/blog/post", "/about", "/assets/logo")
+	mockFetcher.AddPage("https://example.com/products/1")
+	mockFetcher.AddPage("https://example.com/blog/post")
+	mockFetcher.AddPage("https://example.com/about")
+	mockFetcher.AddPage("https://example.com/assets/logo")
+
+	crawler := New(Options{
+		Workers:        2,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		ParserRules: []ParserRule{
+			{Path: "/products/*", Parser: namedParser("product")},
+			{Pattern: regexp.MustCompile(`/blog/`), Parser: namedParser("blog")},
+			{Path: "/assets/*"},
+		},
+		Parsers:       map[string]Parser{"example.com": namedParser("domain")},
+		DefaultParser: namedParser("default"),
+	})
+
+	var mutex sync.Mutex
+	parsed := map[string]any{}
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			parsed[result.URL.String()] = result.Parsed
+		})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		"https://example.com":             "domain",
+		"https://example.com/products/1":  "product",
+		"https://example.com/blog/post":   "blog",
+		"https://example.com/about":       "domain",
+		"https://example.com/assets/logo": nil,
+	}, parsed)
+}
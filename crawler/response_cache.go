@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/myzie/web/fetch"
+)
+
+// cachedResponseVersion identifies the current cache value format.
+const cachedResponseVersion = 1
+
+// DefaultCacheErrorTTL is how long known dead URLs remain in the cache when
+// Options.CacheErrorTTL is not set.
+const DefaultCacheErrorTTL = 24 * time.Hour
+
+// cachedResponse is the format used to store fetched responses in the cache,
+// so that a cache hit behaves the same as a live fetch.
+type cachedResponse struct {
+	Version  int             `json:"version"`
+	Expires  time.Time       `json:"expires,omitzero"`
+	Response *fetch.Response `json:"response"`
+}
+
+// encodeCachedResponse serializes a response for storage in the cache.
+func encodeCachedResponse(response *fetch.Response) ([]byte, error) {
+	return json.Marshal(cachedResponse{
+		Version:  cachedResponseVersion,
+		Response: response,
+	})
+}
+
+// encodeDeadURL serializes the marker stored in the cache for a URL that
+// failed with a terminal status. Only the URL and status are kept. Pages with
+// an error status are otherwise never cached, so the status identifies the
+// marker. The marker records when it expires, as not every cache can expire
+// entries itself.
+func encodeDeadURL(rawURL string, statusCode int, expires time.Time) ([]byte, error) {
+	return json.Marshal(cachedResponse{
+		Version: cachedResponseVersion,
+		Expires: expires,
+		Response: &fetch.Response{
+			URL:        rawURL,
+			StatusCode: statusCode,
+		},
+	})
+}
+
+// isDeadStatus reports whether a response status means the URL is gone and
+// not worth fetching again. Client errors are terminal, except for request
+// timeouts and rate limiting.
+func isDeadStatus(statusCode int) bool {
+	return statusCode >= 400 && statusCode < 500 &&
+		statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests
+}
+
+// decodeCachedResponse deserializes a cached response, returning nil if it
+// has expired. Values that are not in the current format are assumed to be
+// raw HTML, which is how responses were cached previously.
+func decodeCachedResponse(rawURL string, value []byte) *fetch.Response {
+	var cached cachedResponse
+	if err := json.Unmarshal(value, &cached); err == nil &&
+		cached.Version == cachedResponseVersion && cached.Response != nil {
+		if !cached.Expires.IsZero() && !time.Now().Before(cached.Expires) {
+			return nil
+		}
+		return cached.Response
+	}
+	return &fetch.Response{
+		URL:  rawURL,
+		HTML: string(value),
+	}
+}
@@ -0,0 +1,225 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	weberrors "github.com/myzie/web/errors"
+	"github.com/myzie/web/fetch"
+)
+
+const (
+	// DefaultRetryBackoff is the initial delay between retries when
+	// Options.RetryBackoff is not set.
+	DefaultRetryBackoff = 500 * time.Millisecond
+
+	// DefaultMaxRetryAfter is the longest Retry-After delay honored when
+	// Options.MaxRetryAfter is not set.
+	DefaultMaxRetryAfter = 5 * time.Minute
+
+	// DefaultMaxRateLimitRetries is the number of times a rate limited URL is
+	// requeued when Options.MaxRateLimitRetries is not set.
+	DefaultMaxRateLimitRetries = 3
+)
+
+// isRetriableStatus reports whether an HTTP status code indicates a transient
+// server condition.
+func isRetriableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// isRetriable reports whether a failed fetch may succeed if attempted again.
+// Network errors and retriable status codes are retried, while cancellation,
+// redirect loops and other errors are not.
+func isRetriable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, fetch.ErrTooManyRedirects) {
+		return false
+	}
+	var reqErr *weberrors.RequestError
+	if errors.As(err, &reqErr) && reqErr.StatusCode() != 0 {
+		return isRetriableStatus(reqErr.StatusCode())
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// statusError returns an error for responses with an error status. Whether
+// the error is retried depends on the status code.
+func statusError(response *fetch.Response) error {
+	if response == nil || response.StatusCode < 400 {
+		return nil
+	}
+	return weberrors.NewRequestError(
+		fmt.Errorf("request failed with status %d", response.StatusCode),
+	).WithStatusCode(response.StatusCode).WithRawURL(response.URL)
+}
+
+// retryDelay returns the exponential backoff delay before the given retry
+// attempt, starting at 1, with up to 50% random jitter added.
+func retryDelay(backoff time.Duration, attempt int) time.Duration {
+	delay := backoff << (attempt - 1)
+	return delay + rand.N(delay/2+1)
+}
+
+// fetchWithRetry fetches the request, retrying transient failures with
+// exponential backoff. Requests are subject to the host's rate limiter.
+func (c *Crawler) fetchWithRetry(ctx context.Context, fetcher fetch.Fetcher, req *fetch.Request, host string) (*fetch.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			c.stats.IncrementRetried()
+			timer := time.NewTimer(retryDelay(c.retryBackoff, attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+		response, err := c.fetch(ctx, fetcher, req, host)
+		if err == nil {
+			return response, nil
+		}
+		// Rate limited responses are requeued by the caller rather than
+		// retried immediately
+		if _, limited := c.retryAfter(response); limited {
+			return response, err
+		}
+		if attempt >= c.maxRetries || !isRetriable(err) {
+			return response, err
+		}
+		c.logger.Debug("retrying fetch",
+			slog.String("url", req.URL),
+			slog.Int("attempt", attempt+1),
+			slog.String("error", err.Error()))
+	}
+}
+
+// fetch makes a single request once the host's rate limiter, the global rate
+// limiter and the adaptive limiter, if enabled, allow it.
+func (c *Crawler) fetch(ctx context.Context, fetcher fetch.Fetcher, req *fetch.Request, host string) (*fetch.Response, error) {
+	if c.adaptive != nil {
+		if err := c.adaptive.Acquire(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.waitLimiters(ctx, host); err != nil {
+		// The request never started, so leave the limit unchanged
+		if c.adaptive != nil {
+			c.adaptive.Release(host, time.Now(), 0, context.Canceled)
+		}
+		return nil, err
+	}
+	started := time.Now()
+	response, err := c.fetchWithTimeout(ctx, fetcher, req)
+	latency := time.Since(started)
+	c.stats.RecordLatency(latency)
+	if err == nil {
+		err = statusError(response)
+	}
+	if c.adaptive != nil {
+		limit := c.adaptive.Release(host, started, latency, err)
+		c.stats.SetDomainConcurrency(hostname(host), limit)
+	}
+	return response, err
+}
+
+// waitLimiters blocks until both the host's rate limiter and the global rate
+// limiter allow a request to the host.
+func (c *Crawler) waitLimiters(ctx context.Context, host string) error {
+	if err := c.hostLimiter.Wait(ctx, host); err != nil {
+		return err
+	}
+	if c.globalLimiter != nil {
+		return c.globalLimiter.Wait(ctx)
+	}
+	return nil
+}
+
+// fetchWithTimeout calls the fetcher, limited to the request timeout if one is
+// set. Only this request is cancelled when the timeout elapses.
+func (c *Crawler) fetchWithTimeout(ctx context.Context, fetcher fetch.Fetcher, req *fetch.Request) (*fetch.Response, error) {
+	if c.requestTimeout <= 0 {
+		return fetcher.Fetch(ctx, req)
+	}
+	requestCtx, cancel := context.WithTimeout(ctx, c.requestTimeout)
+	defer cancel()
+	response, err := fetcher.Fetch(requestCtx, req)
+	if err != nil && ctx.Err() == nil && requestCtx.Err() != nil {
+		err = fmt.Errorf("request timed out after %s: %w", c.requestTimeout, requestCtx.Err())
+	}
+	return response, err
+}
+
+// hostname returns the host without any port.
+func hostname(host string) string {
+	return (&url.URL{Host: host}).Hostname()
+}
+
+// parseRetryAfter parses a Retry-After header value, which may be either a
+// number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// retryAfter returns the delay requested by a 429 or 503 response carrying a
+// Retry-After header, capped at the configured maximum.
+func (c *Crawler) retryAfter(response *fetch.Response) (time.Duration, bool) {
+	if response == nil {
+		return 0, false
+	}
+	if response.StatusCode != http.StatusTooManyRequests &&
+		response.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	delay, ok := parseRetryAfter(response.GetHeader("Retry-After"), time.Now())
+	if !ok {
+		return 0, false
+	}
+	return min(delay, c.maxRetryAfter), true
+}
+
+// requeue adds the entry back to the frontier once the delay has elapsed. The
+// idle monitor treats pending requeues as outstanding work, and checkpoints
+// include them as in flight.
+func (c *Crawler) requeue(ctx context.Context, entry FrontierEntry, delay time.Duration) {
+	c.stats.IncrementRetried()
+	entry.Requeues++
+	c.addOutstanding(1)
+	c.inFlight.Store(entry.URL, entry)
+	time.AfterFunc(delay, func() {
+		defer c.addOutstanding(-1)
+		if ctx.Err() == nil {
+			c.inFlight.CompareAndDelete(entry.URL, entry)
+			c.push(entry)
+		}
+	})
+}
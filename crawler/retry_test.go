@@ -0,0 +1,295 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	weberrors "github.com/myzie/web/errors"
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyFetcher fails a configured number of times per URL before succeeding.
+type flakyFetcher struct {
+	mutex    sync.Mutex
+	failures map[string]int
+	errs     map[string]error
+	attempts map[string]int
+}
+
+func (f *flakyFetcher) Fetch(ctx context.Context, req *fetch.Request) (*fetch.Response, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.attempts[req.URL]++
+	if f.attempts[req.URL] <= f.failures[req.URL] {
+		return nil, f.errs[req.URL]
+	}
+	return &fetch.Response{URL: req.URL, StatusCode: 200}, nil
+}
+
+func TestCrawler_Retries(t *testing.T) {
+	unavailable := weberrors.NewRequestError(fmt.Errorf("unavailable")).WithStatusCode(503)
+	notFound := weberrors.NewRequestError(fmt.Errorf("not found")).WithStatusCode(404)
+	fetcher := &flakyFetcher{
+		failures: map[string]int{
+			"https://example.com/flaky":   2,
+			"https://example.com/down":    10,
+			"https://example.com/missing": 10,
+		},
+		errs: map[string]error{
+			"https://example.com/flaky":   unavailable,
+			"https://example.com/down":    unavailable,
+			"https://example.com/missing": notFound,
+		},
+		attempts: map[string]int{},
+	}
+
+	crawler := New(Options{
+		Workers:        3,
+		Fetcher:        fetcher,
+		FollowBehavior: FollowNone,
+		MaxRetries:     3,
+		RetryBackoff:   time.Millisecond,
+	})
+
+	results := map[string][]error{}
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.URL.String()] = append(results[result.URL.String()], result.Error)
+	}
+
+	urls := []string{
+		"https://example.com/flaky",
+		"https://example.com/down",
+		"https://example.com/missing",
+	}
+	err := crawler.Crawl(context.Background(), urls, callback)
+	require.NoError(t, err)
+
+	// Each URL is reported exactly once
+	assert.Equal(t, []error{nil}, results["https://example.com/flaky"])
+	assert.Equal(t, []error{unavailable}, results["https://example.com/down"])
+	assert.Equal(t, []error{notFound}, results["https://example.com/missing"])
+
+	assert.Equal(t, 3, fetcher.attempts["https://example.com/flaky"])
+	assert.Equal(t, 4, fetcher.attempts["https://example.com/down"])
+	assert.Equal(t, 1, fetcher.attempts["https://example.com/missing"])
+
+	stats := crawler.GetStats()
+	assert.Equal(t, int64(5), stats.GetRetried())
+	assert.Equal(t, int64(1), stats.GetSucceeded())
+	assert.Equal(t, int64(2), stats.GetFailed())
+}
+
+func TestIsRetriable(t *testing.T) {
+	assert.False(t, isRetriable(nil))
+	assert.False(t, isRetriable(context.Canceled))
+	assert.False(t, isRetriable(fmt.Errorf("parse failure")))
+	assert.False(t, isRetriable(&url.Error{Op: "Get", URL: "https://example.com",
+		Err: fmt.Errorf("%w: stopped after 10 redirects", fetch.ErrTooManyRedirects)}))
+	assert.True(t, isRetriable(weberrors.NewRequestErrorf("busy").WithStatusCode(429)))
+	assert.True(t, isRetriable(weberrors.NewRequestErrorf("error").WithStatusCode(502)))
+	assert.False(t, isRetriable(weberrors.NewRequestErrorf("gone").WithStatusCode(410)))
+	assert.True(t, isRetriable(statusError(&fetch.Response{StatusCode: 500})))
+	assert.Nil(t, statusError(&fetch.Response{StatusCode: 200}))
+}
+
+// rateLimitedFetcher responds with 429 and a Retry-After header until the
+// configured number of requests have been made.
+type rateLimitedFetcher struct {
+	mutex    sync.Mutex
+	limited  int
+	attempts []time.Time
+}
+
+func (f *rateLimitedFetcher) Fetch(ctx context.Context, req *fetch.Request) (*fetch.Response, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.attempts = append(f.attempts, time.Now())
+	if len(f.attempts) <= f.limited {
+		return &fetch.Response{
+			URL:        req.URL,
+			StatusCode: 429,
+			Headers:    map[string]string{"Retry-After": "1"},
+		}, nil
+	}
+	return &fetch.Response{URL: req.URL, StatusCode: 200}, nil
+}
+
+func TestCrawler_RetryAfter(t *testing.T) {
+	fetcher := &rateLimitedFetcher{limited: 1}
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        fetcher,
+		FollowBehavior: FollowNone,
+		MaxRetryAfter:  200 * time.Millisecond,
+	})
+
+	var results []*Result
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, result)
+	}
+
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, callback)
+	require.NoError(t, err)
+
+	// The URL is requeued after the capped Retry-After delay and reported once
+	require.Len(t, results, 1)
+	assert.NoError(t, results[0].Error)
+	require.Len(t, fetcher.attempts, 2)
+	assert.GreaterOrEqual(t, fetcher.attempts[1].Sub(fetcher.attempts[0]), 200*time.Millisecond)
+	assert.Less(t, fetcher.attempts[1].Sub(fetcher.attempts[0]), time.Second)
+
+	// The requeued URL is counted as processed once
+	stats := crawler.GetStats()
+	assert.Equal(t, int64(1), stats.GetProcessed())
+	assert.Equal(t, int64(1), stats.GetSucceeded())
+	assert.Equal(t, int64(1), stats.GetRetried())
+	assert.Equal(t, int64(1), stats.ByDomain()["example.com"].Processed)
+}
+
+func TestCrawler_RetryAfterExhausted(t *testing.T) {
+	fetcher := &rateLimitedFetcher{limited: 10}
+	crawler := New(Options{
+		Workers:             1,
+		Fetcher:             fetcher,
+		FollowBehavior:      FollowNone,
+		MaxRetryAfter:       time.Millisecond,
+		MaxRateLimitRetries: 2,
+	})
+
+	var results []*Result
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, result)
+	}
+
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, callback)
+	require.NoError(t, err)
+
+	require.Len(t, results, 1)
+	var reqErr *weberrors.RequestError
+	require.ErrorAs(t, results[0].Error, &reqErr)
+	assert.Equal(t, 429, reqErr.StatusCode())
+	assert.Len(t, fetcher.attempts, 3)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	delay, ok := parseRetryAfter("120", now)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, delay)
+
+	delay, ok = parseRetryAfter("Mon, 01 Jan 2024 12:00:30 GMT", now)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, delay)
+
+	delay, ok = parseRetryAfter("Mon, 01 Jan 2024 11:00:00 GMT", now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), delay)
+
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(t, ok)
+	_, ok = parseRetryAfter("-5", now)
+	assert.False(t, ok)
+}
+
+// hangingFetcher never responds to requests for the hung URL until the
+// request is cancelled.
+type hangingFetcher struct {
+	fetch.Fetcher
+	hung     string
+	attempts atomic.Int64
+}
+
+func (f *hangingFetcher) Fetch(ctx context.Context, req *fetch.Request) (*fetch.Response, error) {
+	if req.URL != f.hung {
+		return f.Fetcher.Fetch(ctx, req)
+	}
+	f.attempts.Add(1)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCrawler_RequestTimeout(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/hung", "/ok")
+	mockFetcher.AddPage("https://example.com/ok")
+	fetcher := &hangingFetcher{Fetcher: mockFetcher, hung: "https://example.com/hung"}
+
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        fetcher,
+		FollowBehavior: FollowSameDomain,
+		RequestTimeout: 50 * time.Millisecond,
+		MaxRetries:     1,
+		RetryBackoff:   time.Millisecond,
+	})
+	errs := map[string]error{}
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			errs[result.URL.String()] = result.Error
+		})
+	require.NoError(t, err)
+
+	// The hung request times out and is retried, without ending the crawl
+	require.ErrorIs(t, errs["https://example.com/hung"], context.DeadlineExceeded)
+	assert.Equal(t, int64(2), fetcher.attempts.Load())
+	assert.NoError(t, errs["https://example.com/ok"])
+	assert.Equal(t, ReasonIdle, crawler.GetStats().GetStopReason())
+}
+
+func TestCrawler_CancelledFetch(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/hung")
+	fetcher := &hangingFetcher{Fetcher: mockFetcher, hung: "https://example.com/hung"}
+
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        fetcher,
+		FollowBehavior: FollowSameDomain,
+		MaxRetries:     2,
+		RetryBackoff:   time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	errs := map[string]error{}
+	go func() {
+		for fetcher.attempts.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	err := crawler.Crawl(ctx, []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs[result.URL.String()] = result.Error
+		})
+	require.NoError(t, err)
+
+	// The interrupted fetch is neither retried nor reported as a failure
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]error{"https://example.com": nil}, errs)
+	assert.Equal(t, int64(1), fetcher.attempts.Load())
+	stats := crawler.GetStats()
+	assert.Equal(t, int64(1), stats.GetCancelled())
+	assert.Equal(t, int64(0), stats.GetFailed())
+	assert.Equal(t, int64(1), stats.GetSucceeded())
+	assert.Equal(t, ReasonContextCancelled, stats.GetStopReason())
+}
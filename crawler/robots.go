@@ -0,0 +1,288 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myzie/web/fetch"
+)
+
+// ErrRobotsDisallowed is reported on a Result when robots.txt disallows the
+// URL for the configured user agent.
+var ErrRobotsDisallowed = errors.New("url disallowed by robots.txt")
+
+// ErrRobotsUnavailable is reported on a Result when the host's robots.txt
+// could not be loaded because of a network error or a server error status.
+// The host is treated as disallowed until robots.txt loads, which is retried
+// for each of its URLs.
+var ErrRobotsUnavailable = errors.New("robots.txt unavailable")
+
+// maxRobotsSize limits how much of a robots.txt file is read.
+const maxRobotsSize = 512 * 1024
+
+// robotsRule is a single Allow or Disallow line.
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// robotsGroup holds the rules that apply to a set of user agents.
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// robotsRules is a parsed robots.txt file.
+type robotsRules struct {
+	groups []*robotsGroup
+}
+
+// parseRobots parses the contents of a robots.txt file. Unknown directives
+// and malformed lines are ignored.
+func parseRobots(r io.Reader) *robotsRules {
+	rules := &robotsRules{}
+	var current *robotsGroup
+	inAgents := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			// Consecutive user-agent lines share a group
+			if current == nil || !inAgents {
+				current = &robotsGroup{}
+				rules.groups = append(rules.groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			// An empty Disallow means everything is allowed
+			if value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{
+				pattern: value,
+				allow:   key == "allow",
+			})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		default:
+			inAgents = false
+		}
+	}
+	return rules
+}
+
+// group returns the group that applies to the given user agent. Of the groups
+// naming the user agent, the one with the longest, most specific name is
+// chosen, and the wildcard group applies when none do.
+func (r *robotsRules) group(userAgent string) *robotsGroup {
+	if r == nil {
+		return nil
+	}
+	userAgent = strings.ToLower(userAgent)
+	var wildcard, named *robotsGroup
+	matchLen := 0
+	for _, g := range r.groups {
+		for _, agent := range g.agents {
+			if agent == "*" {
+				if wildcard == nil {
+					wildcard = g
+				}
+				continue
+			}
+			if userAgent != "" && len(agent) > matchLen && strings.Contains(userAgent, agent) {
+				named = g
+				matchLen = len(agent)
+			}
+		}
+	}
+	if named != nil {
+		return named
+	}
+	return wildcard
+}
+
+// Allowed reports whether the user agent may fetch the given URL. The longest
+// matching rule wins, with Allow winning ties.
+func (r *robotsRules) Allowed(userAgent string, u *url.URL) bool {
+	g := r.group(userAgent)
+	if g == nil {
+		return true
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	allowed := true
+	matchLen := -1
+	for _, rule := range g.rules {
+		if !robotsPatternMatch(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > matchLen || (n == matchLen && rule.allow) {
+			matchLen = n
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay returns the crawl delay that applies to the user agent.
+func (r *robotsRules) CrawlDelay(userAgent string) time.Duration {
+	if g := r.group(userAgent); g != nil {
+		return g.crawlDelay
+	}
+	return 0
+}
+
+// robotsPatternMatch matches a path against a robots.txt pattern, which may
+// contain "*" wildcards and a trailing "$" anchor.
+func robotsPatternMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+	return globMatch(pattern, path, anchored)
+}
+
+func globMatch(pattern, s string, anchored bool) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == '*' {
+			pattern = pattern[1:]
+			for i := 0; i <= len(s); i++ {
+				if globMatch(pattern, s[i:], anchored) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(s) == 0 || s[0] != pattern[0] {
+			return false
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return !anchored || s == ""
+}
+
+// robotsEntry holds the rules for one host once they have loaded.
+type robotsEntry struct {
+	mutex  sync.Mutex
+	loaded bool
+	rules  *robotsRules
+}
+
+// robotsCache fetches and caches robots.txt rules per host.
+type robotsCache struct {
+	client    *http.Client
+	userAgent string
+	mutex     sync.Mutex
+	entries   map[string]*robotsEntry
+}
+
+func newRobotsCache(client *http.Client, userAgent string) *robotsCache {
+	return &robotsCache{
+		client:    client,
+		userAgent: userAgent,
+		entries:   make(map[string]*robotsEntry),
+	}
+}
+
+// Get returns the rules for the URL's host, fetching robots.txt on first
+// contact. A nil result means no restrictions apply. Failures are not cached,
+// so robots.txt is fetched again on the next call.
+func (c *robotsCache) Get(ctx context.Context, u *url.URL) (*robotsRules, error) {
+	c.mutex.Lock()
+	entry, exists := c.entries[u.Host]
+	if !exists {
+		entry = &robotsEntry{}
+		c.entries[u.Host] = entry
+	}
+	c.mutex.Unlock()
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	if entry.loaded {
+		return entry.rules, nil
+	}
+	rules, err := c.fetch(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	entry.rules, entry.loaded = rules, true
+	return rules, nil
+}
+
+// fetch retrieves and parses robots.txt for the URL's host. Missing robots.txt
+// files place no restrictions on crawling. Network errors and server error
+// statuses return ErrRobotsUnavailable.
+func (c *robotsCache) fetch(ctx context.Context, u *url.URL) (*robotsRules, error) {
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRobotsUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w: status %d", ErrRobotsUnavailable, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	return parseRobots(io.LimitReader(resp.Body, maxRobotsSize)), nil
+}
+
+// metaRobots reports the noindex and nofollow directives in a page's robots
+// meta tag. The "none" directive implies both.
+func metaRobots(response *fetch.Response) (noIndex, noFollow bool) {
+	for _, directive := range strings.Split(response.Metadata.Robots, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "noindex":
+			noIndex = true
+		case "nofollow":
+			noFollow = true
+		case "none":
+			noIndex, noFollow = true, true
+		}
+	}
+	return noIndex, noFollow
+}
@@ -0,0 +1,268 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRobotsRules_Allowed(t *testing.T) {
+	rules := parseRobots(strings.NewReader(`
+# Example robots.txt
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+
+User-agent: webbot
+User-agent: otherbot
+Disallow: /
+Crawl-delay: 2.5
+
+User-agent: bot
+Disallow: /bots
+
+User-agent: webbot-news
+Allow: /
+`))
+
+	tests := []struct {
+		name      string
+		userAgent string
+		path      string
+		expected  bool
+	}{
+		{name: "root allowed", userAgent: "", path: "/", expected: true},
+		{name: "disallowed prefix", userAgent: "", path: "/private/page", expected: false},
+		{name: "longer allow wins", userAgent: "", path: "/private/public/page", expected: true},
+		{name: "wildcard with anchor", userAgent: "", path: "/docs/file.pdf", expected: false},
+		{name: "anchor not at end", userAgent: "", path: "/docs/file.pdf.html", expected: true},
+		{name: "named agent", userAgent: "WebBot/1.0", path: "/anything", expected: false},
+		{name: "second agent in group", userAgent: "otherbot", path: "/", expected: false},
+		{name: "shorter name", userAgent: "robot", path: "/bots", expected: false},
+		{name: "most specific name", userAgent: "WebBot-News/2.0", path: "/anything", expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse("https://example.com" + tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, rules.Allowed(tt.userAgent, u))
+		})
+	}
+
+	assert.Equal(t, 2500*time.Millisecond, rules.CrawlDelay("webbot"))
+	assert.Equal(t, time.Duration(0), rules.CrawlDelay("somebot"))
+}
+
+func TestCrawler_RespectRobots(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	baseURL := server.URL
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse(baseURL, &fetch.Response{
+		URL:   baseURL,
+		HTML:  "<html><body>Home</body></html>",
+		Links: []*fetch.Link{{URL: "/private"}, {URL: "/public"}},
+	})
+	mockFetcher.AddResponse(baseURL+"/public", &fetch.Response{
+		URL:  baseURL + "/public",
+		HTML: "<html><body>Public</body></html>",
+	})
+
+	crawler := New(Options{
+		MaxURLs:        10,
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		RespectRobots:  true,
+		HTTPClient:     server.Client(),
+	})
+
+	results := map[string]error{}
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.URL.String()] = result.Error
+	}
+
+	err := crawler.Crawl(context.Background(), []string{baseURL}, callback)
+	require.NoError(t, err)
+
+	assert.Len(t, results, 3)
+	assert.NoError(t, results[baseURL])
+	assert.NoError(t, results[baseURL+"/public"])
+	assert.ErrorIs(t, results[baseURL+"/private"], ErrRobotsDisallowed)
+
+	stats := crawler.GetStats()
+	assert.Equal(t, int64(1), stats.GetRobotsDenied())
+	assert.Equal(t, int64(0), stats.GetSkipped())
+	assert.Equal(t, int64(2), stats.GetProcessed())
+	assert.Equal(t, int64(2), stats.GetSucceeded())
+}
+
+func TestRobotsCache_Unavailable(t *testing.T) {
+	var status atomic.Int64
+	status.Store(http.StatusServiceUnavailable)
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(int(status.Load()))
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	}))
+	defer server.Close()
+
+	robots := newRobotsCache(server.Client(), "")
+	u, err := url.Parse(server.URL + "/page")
+	require.NoError(t, err)
+
+	// Server errors are reported and not cached
+	rules, err := robots.Get(context.Background(), u)
+	assert.ErrorIs(t, err, ErrRobotsUnavailable)
+	assert.Nil(t, rules)
+	_, err = robots.Get(context.Background(), u)
+	assert.ErrorIs(t, err, ErrRobotsUnavailable)
+	assert.Equal(t, int64(2), requests.Load())
+
+	// Once robots.txt loads, its rules are cached
+	status.Store(http.StatusOK)
+	rules, err = robots.Get(context.Background(), u)
+	require.NoError(t, err)
+	require.NotNil(t, rules)
+	rules, err = robots.Get(context.Background(), u)
+	require.NoError(t, err)
+	assert.True(t, rules.Allowed("", u))
+	assert.Equal(t, int64(3), requests.Load())
+
+	// A cancelled request is not cached either
+	other, err := url.Parse("https://other.invalid/page")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = robots.Get(ctx, other)
+	assert.ErrorIs(t, err, ErrRobotsUnavailable)
+	robots.mutex.Lock()
+	assert.False(t, robots.entries[other.Host].loaded)
+	robots.mutex.Unlock()
+}
+
+func TestCrawler_RobotsUnavailable(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage(server.URL)
+	crawler := New(Options{
+		MaxURLs:       1,
+		Workers:       1,
+		Fetcher:       mockFetcher,
+		RespectRobots: true,
+		HTTPClient:    server.Client(),
+	})
+
+	var results []*Result
+	err := crawler.Crawl(context.Background(), []string{server.URL}, func(ctx context.Context, result *Result) {
+		results = append(results, result)
+	})
+	require.NoError(t, err)
+
+	// The URL is not fetched and does not count towards MaxURLs
+	require.Len(t, results, 1)
+	assert.ErrorIs(t, results[0].Error, ErrRobotsUnavailable)
+	assert.Empty(t, mockFetcher.RequestedURLs())
+	stats := crawler.GetStats()
+	assert.Equal(t, int64(1), stats.GetRobotsDenied())
+	assert.Equal(t, int64(0), stats.GetProcessed())
+}
+
+func TestMetaRobots(t *testing.T) {
+	tests := []struct {
+		content  string
+		noIndex  bool
+		noFollow bool
+	}{
+		{content: "", noIndex: false, noFollow: false},
+		{content: "index, follow", noIndex: false, noFollow: false},
+		{content: "noindex", noIndex: true, noFollow: false},
+		{content: "NOFOLLOW", noIndex: false, noFollow: true},
+		{content: "noindex,nofollow", noIndex: true, noFollow: true},
+		{content: "none", noIndex: true, noFollow: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			response := &fetch.Response{Metadata: fetch.Metadata{Robots: tt.content}}
+			noIndex, noFollow := metaRobots(response)
+			assert.Equal(t, tt.noIndex, noIndex)
+			assert.Equal(t, tt.noFollow, noFollow)
+		})
+	}
+}
+
+func TestCrawler_RespectMetaRobots(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddResponse("https://example.com", &fetch.Response{
+		URL:   "https://example.com",
+		Links: []*fetch.Link{{URL: "/noindex"}, {URL: "/nofollow"}},
+	})
+	mockFetcher.AddResponse("https://example.com/noindex", &fetch.Response{
+		URL:      "https://example.com/noindex",
+		Metadata: fetch.Metadata{Robots: "noindex"},
+		Links:    []*fetch.Link{{URL: "/a"}},
+	})
+	mockFetcher.AddResponse("https://example.com/nofollow", &fetch.Response{
+		URL:      "https://example.com/nofollow",
+		Metadata: fetch.Metadata{Robots: "nofollow"},
+		Links:    []*fetch.Link{{URL: "/b"}},
+	})
+	mockFetcher.AddResponse("https://example.com/a", &fetch.Response{URL: "https://example.com/a"})
+	mockFetcher.AddResponse("https://example.com/b", &fetch.Response{URL: "https://example.com/b"})
+
+	crawler := New(Options{
+		MaxURLs:           10,
+		Workers:           1,
+		Fetcher:           mockFetcher,
+		FollowBehavior:    FollowSameDomain,
+		DefaultParser:     NewMockParser(),
+		RespectMetaRobots: true,
+	})
+
+	results := map[string]*Result{}
+	var mu sync.Mutex
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"}, func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.URL.String()] = result
+	})
+	require.NoError(t, err)
+
+	require.Len(t, results, 4)
+	assert.NotContains(t, results, "https://example.com/b")
+
+	noIndex := results["https://example.com/noindex"]
+	assert.True(t, noIndex.NoIndex)
+	assert.Nil(t, noIndex.Parsed)
+
+	noFollow := results["https://example.com/nofollow"]
+	assert.False(t, noFollow.NoIndex)
+	assert.NotNil(t, noFollow.Parsed)
+	assert.Empty(t, noFollow.Links)
+}
@@ -0,0 +1,112 @@
+package crawler
+
+import (
+	"context"
+	"strings"
+)
+
+// SeedConfig is a seed URL with its own crawl scope, which lets a single
+// crawl cover several sites with different policies. The scope is carried in
+// the FrontierEntry of every URL reached from the seed, and its fields
+// override the corresponding Options for those URLs. Zero values fall back to
+// the Options. A URL reached from more than one seed is crawled once, with
+// the scope of the seed it was first reached from.
+type SeedConfig struct {
+	// URL is the seed URL.
+	URL string `json:"url"`
+
+	// FollowBehavior overrides Options.FollowBehavior. As there, links are
+	// judged relative to the page they are found on, so FollowSameDomain
+	// keeps the crawl on the seed's host.
+	FollowBehavior FollowBehavior `json:"follow_behavior,omitempty"`
+
+	// MaxDepth overrides Options.MaxDepth.
+	MaxDepth int `json:"max_depth,omitempty"`
+
+	// PathPrefixes restricts the links followed to those whose path begins
+	// with one of the prefixes, matched as with Options.PathPrefixes, which
+	// it replaces. The seed itself is crawled regardless.
+	PathPrefixes []string `json:"path_prefixes,omitempty"`
+}
+
+// scoped reports whether the seed overrides any of the Options.
+func (s SeedConfig) scoped() bool {
+	return s.FollowBehavior != "" || s.MaxDepth > 0 || len(s.PathPrefixes) > 0
+}
+
+// CrawlSeeds crawls like Crawl, but each seed may have its own follow
+// behavior, maximum depth and path prefixes. Seeds listed in
+// Options.SeedSitemaps are crawled with the Options as usual.
+func (c *Crawler) CrawlSeeds(ctx context.Context, seeds []SeedConfig, callback Callback) error {
+	stop, err := c.start()
+	if err != nil {
+		return err
+	}
+	defer c.finish()
+	return c.crawl(ctx, stop, seeds, callback)
+}
+
+// seedConfigs returns seeds for the URLs without their own scope.
+func seedConfigs(urls []string) []SeedConfig {
+	seeds := make([]SeedConfig, len(urls))
+	for i, rawURL := range urls {
+		seeds[i] = SeedConfig{URL: rawURL}
+	}
+	return seeds
+}
+
+// enqueueSeeds queues the seeds in order, returning the number queued.
+// Consecutive seeds without their own scope are queued together.
+func (c *Crawler) enqueueSeeds(ctx context.Context, seeds []SeedConfig) (int, error) {
+	var queued int
+	var batch []string
+	flush := func(seed *SeedConfig) error {
+		count, err := c.enqueue(ctx, batch, seed)
+		queued += count
+		batch = batch[:0]
+		return err
+	}
+	for _, seed := range seeds {
+		if !seed.scoped() {
+			batch = append(batch, seed.URL)
+			continue
+		}
+		if err := flush(nil); err != nil {
+			return queued, err
+		}
+		batch = append(batch, seed.URL)
+		if err := flush(&seed); err != nil {
+			return queued, err
+		}
+	}
+	return queued, flush(nil)
+}
+
+// followBehaviorFor returns the follow behavior for URLs reached from the
+// seed, which is nil for URLs without their own scope.
+func (c *Crawler) followBehaviorFor(seed *SeedConfig) FollowBehavior {
+	if seed != nil && seed.FollowBehavior != "" {
+		return seed.FollowBehavior
+	}
+	return c.followBehavior
+}
+
+// maxDepthFor returns the maximum depth for URLs reached from the seed.
+func (c *Crawler) maxDepthFor(seed *SeedConfig) int {
+	if seed != nil && seed.MaxDepth > 0 {
+		return seed.MaxDepth
+	}
+	return c.maxDepth
+}
+
+// hasPathPrefix reports whether the path begins with one of the prefixes.
+// Trailing slashes are trimmed during normalization, so "/docs" matches the
+// prefix "/docs/".
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) || path == strings.TrimSuffix(prefix, "/") {
+			return true
+		}
+	}
+	return false
+}
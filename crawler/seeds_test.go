@@ -0,0 +1,82 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrawler_CrawlSeeds(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://a.com", "/1", "https://b.com/docs/a")
+	mockFetcher.AddPage("https://a.com/1", "/1/2")
+	mockFetcher.AddPage("https://a.com/1/2")
+	mockFetcher.AddPage("https://b.com", "/docs/a", "/blog/post")
+	mockFetcher.AddPage("https://b.com/docs/a", "/docs/b", "https://c.com/docs")
+	mockFetcher.AddPage("https://b.com/docs/b")
+	mockFetcher.AddPage("https://b.com/blog/post")
+	mockFetcher.AddPage("https://c.com/docs")
+	mockFetcher.AddPage("https://d.com", "/page", "https://e.com")
+	mockFetcher.AddPage("https://d.com/page")
+	mockFetcher.AddPage("https://e.com")
+
+	var mu sync.Mutex
+	depths := map[string]int{}
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowAny,
+	})
+	seeds := []SeedConfig{
+		{URL: "https://a.com", FollowBehavior: FollowSameDomain, MaxDepth: 1},
+		{URL: "https://b.com", FollowBehavior: FollowSameDomain, PathPrefixes: []string{"/docs/"}},
+		{URL: "https://d.com"},
+	}
+	err := crawler.CrawlSeeds(context.Background(), seeds, func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		depths[result.URL.String()] = result.Depth
+	})
+	require.NoError(t, err)
+
+	// Each seed's links are followed according to its own scope, while the
+	// seed without one follows the Options
+	assert.Equal(t, map[string]int{
+		"https://a.com":        0,
+		"https://a.com/1":      1,
+		"https://b.com":        0,
+		"https://b.com/docs/a": 1,
+		"https://b.com/docs/b": 2,
+		"https://d.com":        0,
+		"https://d.com/page":   1,
+		"https://e.com":        1,
+	}, depths)
+}
+
+func TestFrontierEntry_SeedJSON(t *testing.T) {
+	entry := FrontierEntry{
+		URL:   "https://example.com/docs/a",
+		Depth: 1,
+		Seed: &SeedConfig{
+			URL:            "https://example.com",
+			FollowBehavior: FollowSameDomain,
+			MaxDepth:       2,
+			PathPrefixes:   []string{"/docs/"},
+		},
+	}
+	data, err := json.Marshal(entry)
+	require.NoError(t, err)
+	var decoded FrontierEntry
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, entry, decoded)
+
+	// Entries without a seed scope are encoded as before
+	data, err = json.Marshal(FrontierEntry{URL: "https://example.com"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"url":"https://example.com"}`, string(data))
+}
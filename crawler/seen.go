@@ -0,0 +1,272 @@
+package crawler
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+)
+
+const (
+	// DefaultApproxDedupItems is the number of URLs the first bloom filter is
+	// sized for when Options.ApproxDedupItems is not set.
+	DefaultApproxDedupItems = 1_000_000
+
+	// DefaultApproxDedupErrorRate is the false positive rate used when
+	// Options.ApproxDedupErrorRate is not set.
+	DefaultApproxDedupErrorRate = 0.001
+)
+
+// urlSet records the URLs that have been queued so that each is crawled once.
+// Implementations must be safe for concurrent use.
+type urlSet interface {
+	// Add adds the URL, reporting false if it was already present.
+	Add(url string) bool
+
+	// AddAll adds the URLs, reporting for each whether it was added. A URL
+	// repeated within the batch is only added once.
+	AddAll(urls []string) []bool
+
+	// Contains reports whether the URL is present, without adding it.
+	Contains(url string) bool
+
+	// Remove removes the URL so that it may be added again. Sets that do not
+	// support removal ignore it.
+	Remove(url string)
+
+	// URLs returns the URLs in the set, or nil if they are not retained.
+	URLs() []string
+
+	// Clear removes all URLs from the set.
+	Clear()
+}
+
+// urlSetShards is the number of shards in an exactURLSet. Workers adding the
+// links of different pages rarely contend for the same shard.
+const urlSetShards = 64
+
+// exactURLSet is a urlSet that stores every URL. The URLs are spread across
+// shards, each with its own lock.
+type exactURLSet struct {
+	seed   maphash.Seed
+	shards [urlSetShards]urlShard
+}
+
+type urlShard struct {
+	mutex sync.RWMutex
+	urls  map[string]struct{}
+}
+
+func newExactURLSet() *exactURLSet {
+	return &exactURLSet{seed: maphash.MakeSeed()}
+}
+
+func (s *exactURLSet) shard(url string) *urlShard {
+	return &s.shards[maphash.String(s.seed, url)%urlSetShards]
+}
+
+func (s *exactURLSet) Add(url string) bool {
+	shard := s.shard(url)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	return shard.add(url)
+}
+
+// AddAll groups the URLs by shard so that each shard is locked once.
+func (s *exactURLSet) AddAll(urls []string) []bool {
+	added := make([]bool, len(urls))
+	var byShard [urlSetShards][]int
+	for i, url := range urls {
+		index := maphash.String(s.seed, url) % urlSetShards
+		byShard[index] = append(byShard[index], i)
+	}
+	for index, indexes := range byShard {
+		if len(indexes) == 0 {
+			continue
+		}
+		shard := &s.shards[index]
+		shard.mutex.Lock()
+		for _, i := range indexes {
+			added[i] = shard.add(urls[i])
+		}
+		shard.mutex.Unlock()
+	}
+	return added
+}
+
+func (s *exactURLSet) Contains(url string) bool {
+	shard := s.shard(url)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+	_, exists := shard.urls[url]
+	return exists
+}
+
+func (s *exactURLSet) Remove(url string) {
+	shard := s.shard(url)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	delete(shard.urls, url)
+}
+
+func (s *exactURLSet) Clear() {
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mutex.Lock()
+		shard.urls = nil
+		shard.mutex.Unlock()
+	}
+}
+
+func (s *exactURLSet) URLs() []string {
+	var urls []string
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mutex.RLock()
+		for url := range shard.urls {
+			urls = append(urls, url)
+		}
+		shard.mutex.RUnlock()
+	}
+	return urls
+}
+
+// add adds the URL to the shard, which must be locked.
+func (s *urlShard) add(url string) bool {
+	if _, exists := s.urls[url]; exists {
+		return false
+	}
+	if s.urls == nil {
+		s.urls = map[string]struct{}{}
+	}
+	s.urls[url] = struct{}{}
+	return true
+}
+
+// bloomURLSet is a urlSet backed by a scalable bloom filter, which uses a
+// small, fixed number of bits per URL rather than storing the URLs. When a
+// filter is full, a larger one with a lower error rate is added, so that the
+// overall false positive rate stays near the configured rate however many
+// URLs are added. A false positive causes a URL to be treated as already
+// seen. URLs cannot be removed or listed.
+type bloomURLSet struct {
+	mutex     sync.Mutex
+	filters   []*bloomFilter
+	capacity  int
+	errorRate float64
+	seeds     [2]maphash.Seed
+}
+
+func newBloomURLSet(capacity int, errorRate float64) *bloomURLSet {
+	s := &bloomURLSet{
+		capacity:  capacity,
+		errorRate: errorRate,
+		seeds:     [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+	}
+	s.Clear()
+	return s
+}
+
+func (s *bloomURLSet) Add(url string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.add(url)
+}
+
+// AddAll adds the URLs holding the lock once.
+func (s *bloomURLSet) AddAll(urls []string) []bool {
+	added := make([]bool, len(urls))
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, url := range urls {
+		added[i] = s.add(url)
+	}
+	return added
+}
+
+// add adds the URL to the set, which must be locked.
+func (s *bloomURLSet) add(url string) bool {
+	h1 := maphash.String(s.seeds[0], url)
+	h2 := maphash.String(s.seeds[1], url) | 1
+	for _, filter := range s.filters {
+		if filter.contains(h1, h2) {
+			return false
+		}
+	}
+	last := s.filters[len(s.filters)-1]
+	if last.count >= last.capacity {
+		last = newBloomFilter(last.capacity*2, last.errorRate/2)
+		s.filters = append(s.filters, last)
+	}
+	last.add(h1, h2)
+	return true
+}
+
+func (s *bloomURLSet) Contains(url string) bool {
+	h1 := maphash.String(s.seeds[0], url)
+	h2 := maphash.String(s.seeds[1], url) | 1
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, filter := range s.filters {
+		if filter.contains(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *bloomURLSet) Remove(url string) {}
+
+func (s *bloomURLSet) URLs() []string {
+	return nil
+}
+
+func (s *bloomURLSet) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// Half the error budget goes to the first filter, and each following
+	// filter gets half of the previous one's, so the total stays in budget
+	s.filters = []*bloomFilter{newBloomFilter(s.capacity, s.errorRate/2)}
+}
+
+// bloomFilter is a fixed size bloom filter using double hashing.
+type bloomFilter struct {
+	bits      []uint64
+	size      uint64
+	hashes    int
+	count     int
+	capacity  int
+	errorRate float64
+}
+
+// newBloomFilter creates a filter sized for the given number of items at the
+// given false positive rate.
+func newBloomFilter(capacity int, errorRate float64) *bloomFilter {
+	size := math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+	hashes := max(1, int(math.Round(size/float64(capacity)*math.Ln2)))
+	words := (uint64(size) + 63) / 64
+	return &bloomFilter{
+		bits:      make([]uint64, words),
+		size:      words * 64,
+		hashes:    hashes,
+		capacity:  capacity,
+		errorRate: errorRate,
+	}
+}
+
+func (f *bloomFilter) add(h1, h2 uint64) {
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % f.size
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+	f.count++
+}
+
+func (f *bloomFilter) contains(h1, h2 uint64) bool {
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % f.size
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBloomURLSet(t *testing.T) {
+	// Sized for fewer URLs than are added, so that the set has to grow
+	set := newBloomURLSet(1000, 0.01)
+	var added int
+	for i := 0; i < 5000; i++ {
+		if set.Add(fmt.Sprintf("https://example.com/%d", i)) {
+			added++
+		}
+	}
+	assert.Greater(t, added, 4900)
+	assert.Greater(t, len(set.filters), 1)
+
+	for i := 0; i < 5000; i++ {
+		assert.False(t, set.Add(fmt.Sprintf("https://example.com/%d", i)))
+	}
+	assert.Nil(t, set.URLs())
+	assert.True(t, set.Contains("https://example.com/1"))
+
+	// URLs not added are rarely reported as seen
+	var falsePositives int
+	for i := 0; i < 10000; i++ {
+		if !set.Add(fmt.Sprintf("https://example.org/%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 300)
+}
+
+func TestURLSet_Contains(t *testing.T) {
+	sets := map[string]urlSet{
+		"exact": newExactURLSet(),
+		"bloom": newBloomURLSet(100, 0.001),
+	}
+	for name, set := range sets {
+		t.Run(name, func(t *testing.T) {
+			assert.False(t, set.Contains("https://example.com"))
+			// Checking does not add the URL
+			assert.True(t, set.Add("https://example.com"))
+			assert.True(t, set.Contains("https://example.com"))
+			assert.False(t, set.Contains("https://example.com/about"))
+		})
+	}
+}
+
+func TestCrawler_ApproxDedup(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/page1", "/page2")
+	mockFetcher.AddPage("https://example.com/page1", "/", "/page2")
+	mockFetcher.AddPage("https://example.com/page2", "/", "/page1")
+
+	crawler := New(Options{
+		Workers:        2,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		ApproxDedup:    true,
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			assert.NoError(t, result.Error)
+		})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		"https://example.com",
+		"https://example.com/page1",
+		"https://example.com/page2",
+	}, mockFetcher.RequestedURLs())
+}
+
+// linkDenseSite is a read-only synthetic site whose pages each link to many
+// other pages, most of them already seen.
+type linkDenseSite map[string]*fetch.Response
+
+func newLinkDenseSite(pages, linksPerPage int) linkDenseSite {
+	site := linkDenseSite{}
+	for i := 0; i < pages; i++ {
+		pageURL := fmt.Sprintf("https://example.com/%d", i)
+		links := make([]string, linksPerPage)
+		for j := range links {
+			links[j] = fmt.Sprintf("/%d", (i*31+j*7)%pages)
+		}
+		site[pageURL] = fetch.NewMockResponse(pageURL, links...)
+	}
+	return site
+}
+
+func (s linkDenseSite) Fetch(ctx context.Context, req *fetch.Request) (*fetch.Response, error) {
+	response, ok := s[req.URL]
+	if !ok {
+		return nil, fmt.Errorf("not found: %s", req.URL)
+	}
+	return response, nil
+}
+
+// BenchmarkCrawler_LinkDense crawls a site of 2,000 pages with 300 links each
+// using many workers, which stresses deduplication of discovered links.
+func BenchmarkCrawler_LinkDense(b *testing.B) {
+	site := newLinkDenseSite(2000, 300)
+	seeds := []string{"https://example.com/0"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		crawler := New(Options{
+			Workers:        32,
+			Fetcher:        site,
+			FollowBehavior: FollowSameDomain,
+			QueueSize:      len(site),
+			Logger:         slog.New(slog.DiscardHandler),
+		})
+		if err := crawler.Crawl(context.Background(), seeds, func(ctx context.Context, result *Result) {}); err != nil {
+			b.Fatal(err)
+		}
+		if processed := crawler.GetStats().GetProcessed(); processed != int64(len(site)) {
+			b.Fatalf("processed %d pages, expected %d", processed, len(site))
+		}
+	}
+}
+
+func TestURLSet_AddAll(t *testing.T) {
+	sets := map[string]urlSet{
+		"exact": newExactURLSet(),
+		"bloom": newBloomURLSet(100, 0.001),
+	}
+	for name, set := range sets {
+		t.Run(name, func(t *testing.T) {
+			assert.True(t, set.Add("https://example.com/a"))
+			added := set.AddAll([]string{
+				"https://example.com/a",
+				"https://example.com/b",
+				"https://example.com/c",
+				"https://example.com/b",
+			})
+			assert.Equal(t, []bool{false, true, true, false}, added)
+			assert.True(t, set.Contains("https://example.com/c"))
+		})
+	}
+}
+
+// BenchmarkURLSet_AddAll adds the links of pages from many goroutines at
+// once, most of which are already in the set.
+func BenchmarkURLSet_AddAll(b *testing.B) {
+	set := newExactURLSet()
+	var page atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		links := make([]string, 300)
+		for pb.Next() {
+			i := page.Add(1)
+			for j := range links {
+				links[j] = fmt.Sprintf("https://example.com/%d", (i*31+int64(j)*7)%20000)
+			}
+			set.AddAll(links)
+		}
+	})
+}
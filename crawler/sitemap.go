@@ -0,0 +1,120 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// MaxSitemapDepth limits how deeply sitemap index files may be nested.
+	MaxSitemapDepth = 3
+
+	// maxSitemapSize is the largest uncompressed sitemap that will be read,
+	// matching the limit in the sitemap protocol.
+	maxSitemapSize = 50 * 1024 * 1024
+)
+
+// sitemapDocument decodes both urlset and sitemapindex documents.
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// LoadSitemap fetches the sitemap at the given URL and returns the page URLs
+// it lists. Sitemap index files are followed recursively, up to
+// MaxSitemapDepth levels deep, and gzip compressed sitemaps are supported.
+func LoadSitemap(ctx context.Context, client *http.Client, sitemapURL string) ([]string, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	loader := &sitemapLoader{client: client, visited: map[string]bool{}}
+	if err := loader.load(ctx, sitemapURL, 0); err != nil {
+		return nil, err
+	}
+	return loader.urls, nil
+}
+
+type sitemapLoader struct {
+	client  *http.Client
+	visited map[string]bool
+	urls    []string
+}
+
+func (l *sitemapLoader) load(ctx context.Context, sitemapURL string, depth int) error {
+	if depth > MaxSitemapDepth {
+		return fmt.Errorf("sitemap nesting exceeds max depth of %d: %s", MaxSitemapDepth, sitemapURL)
+	}
+	if l.visited[sitemapURL] {
+		return nil
+	}
+	l.visited[sitemapURL] = true
+
+	doc, err := l.fetch(ctx, sitemapURL)
+	if err != nil {
+		return err
+	}
+	switch doc.XMLName.Local {
+	case "urlset":
+		for _, u := range doc.URLs {
+			if loc := strings.TrimSpace(u.Loc); loc != "" {
+				l.urls = append(l.urls, loc)
+			}
+		}
+	case "sitemapindex":
+		for _, s := range doc.Sitemaps {
+			if loc := strings.TrimSpace(s.Loc); loc != "" {
+				if err := l.load(ctx, loc, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("unexpected sitemap root element %q: %s", doc.XMLName.Local, sitemapURL)
+	}
+	return nil
+}
+
+func (l *sitemapLoader) fetch(ctx context.Context, sitemapURL string) (*sitemapDocument, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sitemap: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch sitemap %s: status %d", sitemapURL, resp.StatusCode)
+	}
+
+	// Detect gzip content by its magic number rather than trusting the URL
+	// extension or content type, which are frequently wrong
+	var body io.Reader = bufio.NewReader(resp.Body)
+	if magic, err := body.(*bufio.Reader).Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress sitemap %s: %w", sitemapURL, err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(io.LimitReader(body, maxSitemapSize)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap %s: %w", sitemapURL, err)
+	}
+	return &doc, nil
+}
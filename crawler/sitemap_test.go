@@ -0,0 +1,116 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSitemapServer(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>%[1]s/pages.xml</loc></sitemap>
+	<sitemap><loc>%[1]s/posts.xml.gz</loc></sitemap>
+</sitemapindex>`, server.URL)
+		case "/pages.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>%[1]s/about</loc></url>
+	<url><loc> %[1]s/contact </loc></url>
+</urlset>`, server.URL)
+		case "/posts.xml.gz":
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			fmt.Fprintf(gz, `<urlset><url><loc>%s/posts/1</loc></url></urlset>`, server.URL)
+			require.NoError(t, gz.Close())
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Write(buf.Bytes())
+		case "/loop.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/loop2.xml</loc></sitemap></sitemapindex>`, server.URL)
+		case "/loop2.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/loop.xml</loc></sitemap></sitemapindex>`, server.URL)
+		case "/deep.xml":
+			depth := r.URL.Query().Get("d")
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/deep.xml?d=%s1</loc></sitemap></sitemapindex>`, server.URL, depth)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return server
+}
+
+func TestLoadSitemap(t *testing.T) {
+	server := newSitemapServer(t)
+	defer server.Close()
+
+	urls, err := LoadSitemap(context.Background(), server.Client(), server.URL+"/sitemap.xml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		server.URL + "/about",
+		server.URL + "/contact",
+		server.URL + "/posts/1",
+	}, urls)
+
+	// Cycles between index files are only visited once
+	urls, err = LoadSitemap(context.Background(), server.Client(), server.URL+"/loop.xml")
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+
+	// Excessive nesting is rejected
+	_, err = LoadSitemap(context.Background(), server.Client(), server.URL+"/deep.xml")
+	assert.ErrorContains(t, err, "max depth")
+
+	_, err = LoadSitemap(context.Background(), server.Client(), server.URL+"/missing.xml")
+	assert.ErrorContains(t, err, "status 404")
+}
+
+func TestCrawler_SeedSitemaps(t *testing.T) {
+	server := newSitemapServer(t)
+	defer server.Close()
+
+	mockFetcher := fetch.NewMockFetcher()
+	for _, path := range []string{"/about", "/contact", "/posts/1"} {
+		mockFetcher.AddResponse(server.URL+path, &fetch.Response{URL: server.URL + path})
+	}
+
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowNone,
+		HTTPClient:     server.Client(),
+		SeedSitemaps:   []string{server.URL + "/sitemap.xml"},
+	})
+
+	var processedURLs []string
+	mu := sync.Mutex{}
+	callback := func(ctx context.Context, result *Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.NoError(t, result.Error)
+		processedURLs = append(processedURLs, result.URL.String())
+	}
+
+	err := crawler.Crawl(context.Background(), nil, callback)
+	require.NoError(t, err)
+
+	sort.Strings(processedURLs)
+	assert.Equal(t, []string{
+		server.URL + "/about",
+		server.URL + "/contact",
+		server.URL + "/posts/1",
+	}, processedURLs)
+}
@@ -0,0 +1,243 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myzie/web/fetch"
+)
+
+// maxSitemapURLs is the most URLs a sitemap may list under the sitemap
+// protocol.
+const maxSitemapURLs = 50000
+
+const (
+	sitemapHeader = xml.Header + `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	sitemapFooter = "</urlset>\n"
+	indexHeader   = xml.Header + `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	indexFooter   = "</sitemapindex>\n"
+)
+
+// ErrSitemapTooLarge is returned when writing a single sitemap for more URLs
+// or bytes than the sitemap protocol allows. Use SitemapCollector.WriteFiles
+// to split large sitemaps.
+var ErrSitemapTooLarge = errors.New("sitemap exceeds the protocol limits")
+
+// sitemapURL is a url element of a sitemap.
+type sitemapURL struct {
+	XMLName xml.Name `xml:"url"`
+	Loc     string   `xml:"loc"`
+	LastMod string   `xml:"lastmod,omitempty"`
+}
+
+// sitemapRef is a sitemap element of a sitemap index.
+type sitemapRef struct {
+	XMLName xml.Name `xml:"sitemap"`
+	Loc     string   `xml:"loc"`
+}
+
+// SitemapCollector collects the pages of a crawl for a sitemap. Its Add
+// method is a Callback, so it may be passed to Crawl directly or called from
+// another callback. Only the URL and modification time of each page are kept.
+// All methods are thread-safe.
+type SitemapCollector struct {
+	mutex    sync.Mutex
+	urls     map[string]time.Time
+	maxURLs  int
+	maxBytes int
+}
+
+// NewSitemapCollector creates an empty SitemapCollector.
+func NewSitemapCollector() *SitemapCollector {
+	return &SitemapCollector{
+		urls:     map[string]time.Time{},
+		maxURLs:  maxSitemapURLs,
+		maxBytes: maxSitemapSize,
+	}
+}
+
+// Add records the page of a result. Results with an error, duplicates, pages
+// marked noindex and content other than HTML are ignored. Redirected pages
+// are listed at their final URL. The modification time is taken from the
+// Last-Modified header, falling back to the Date header.
+func (s *SitemapCollector) Add(ctx context.Context, result *Result) {
+	if !sitemapIncludes(result) {
+		return
+	}
+	loc := result.URL
+	if result.FinalURL != nil {
+		loc = result.FinalURL
+	}
+	lastMod := responseTime(result.Response)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if existing, exists := s.urls[loc.String()]; !exists || lastMod.After(existing) {
+		s.urls[loc.String()] = lastMod
+	}
+}
+
+// Len returns the number of URLs collected.
+func (s *SitemapCollector) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.urls)
+}
+
+// WriteTo writes a single sitemap listing the collected URLs, sorted by URL.
+// It returns ErrSitemapTooLarge if the sitemap would exceed the protocol's
+// limit of 50,000 URLs or 50MB.
+func (s *SitemapCollector) WriteTo(w io.Writer) (int64, error) {
+	chunks, err := s.chunks()
+	if err != nil {
+		return 0, err
+	}
+	if len(chunks) > 1 {
+		return 0, ErrSitemapTooLarge
+	}
+	n, err := w.Write(chunks[0])
+	return int64(n), err
+}
+
+// WriteFiles writes the sitemap to the directory. If the collected URLs fit
+// in one sitemap, it is written to sitemap.xml. Otherwise the URLs are split
+// across sitemap-1.xml, sitemap-2.xml and so on, and sitemap.xml is written as
+// a sitemap index listing them. The index refers to the sitemaps by their
+// names appended to baseURL, which should be the URL the directory will be
+// published at. The paths of the files written are returned.
+func (s *SitemapCollector) WriteFiles(dir, baseURL string) ([]string, error) {
+	chunks, err := s.chunks()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	indexPath := filepath.Join(dir, "sitemap.xml")
+	if len(chunks) == 1 {
+		if err := os.WriteFile(indexPath, chunks[0], 0o644); err != nil {
+			return nil, err
+		}
+		return []string{indexPath}, nil
+	}
+	var index bytes.Buffer
+	index.WriteString(indexHeader)
+	paths := make([]string, 0, len(chunks)+1)
+	for i, chunk := range chunks {
+		name := fmt.Sprintf("sitemap-%d.xml", i+1)
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, chunk, 0o644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+		ref, err := xml.Marshal(sitemapRef{Loc: strings.TrimSuffix(baseURL, "/") + "/" + name})
+		if err != nil {
+			return nil, err
+		}
+		index.Write(ref)
+		index.WriteString("\n")
+	}
+	index.WriteString(indexFooter)
+	if err := os.WriteFile(indexPath, index.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	return append(paths, indexPath), nil
+}
+
+// chunks encodes the collected URLs as one or more sitemaps, each within the
+// limits on URLs and bytes.
+func (s *SitemapCollector) chunks() ([][]byte, error) {
+	s.mutex.Lock()
+	lastMods := maps.Clone(s.urls)
+	s.mutex.Unlock()
+	locs := slices.Sorted(maps.Keys(lastMods))
+
+	var chunks [][]byte
+	var chunk bytes.Buffer
+	count := 0
+	overhead := len(sitemapHeader) + len(sitemapFooter)
+	for _, loc := range locs {
+		entry := sitemapURL{Loc: loc}
+		if lastMod := lastMods[loc]; !lastMod.IsZero() {
+			entry.LastMod = lastMod.UTC().Format(time.RFC3339)
+		}
+		data, err := xml.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, '\n')
+		if count > 0 && (count >= s.maxURLs || chunk.Len()+len(data)+len(sitemapFooter) > s.maxBytes) {
+			chunk.WriteString(sitemapFooter)
+			chunks = append(chunks, bytes.Clone(chunk.Bytes()))
+			chunk.Reset()
+			count = 0
+		}
+		if count == 0 {
+			if overhead+len(data) > s.maxBytes {
+				return nil, fmt.Errorf("%w: url is too long: %s", ErrSitemapTooLarge, loc)
+			}
+			chunk.WriteString(sitemapHeader)
+		}
+		chunk.Write(data)
+		count++
+	}
+	if count == 0 {
+		chunk.WriteString(sitemapHeader)
+	}
+	chunk.WriteString(sitemapFooter)
+	return append(chunks, chunk.Bytes()), nil
+}
+
+// WriteSitemap writes a sitemap listing the pages of the results, as
+// collected by SitemapCollector. It returns ErrSitemapTooLarge if the sitemap
+// would exceed the protocol's limits.
+func WriteSitemap(w io.Writer, results []*Result) error {
+	collector := NewSitemapCollector()
+	for _, result := range results {
+		collector.Add(context.Background(), result)
+	}
+	_, err := collector.WriteTo(w)
+	return err
+}
+
+// sitemapIncludes reports whether a result is a page that belongs in a
+// sitemap.
+func sitemapIncludes(result *Result) bool {
+	if result == nil || result.Error != nil || result.Duplicate || result.NoIndex ||
+		result.Response == nil || result.URL == nil {
+		return false
+	}
+	if result.StatusCode >= 300 {
+		return false
+	}
+	contentType := result.Response.ContentType
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// responseTime returns the modification time of a response from its
+// Last-Modified header, or else its Date header. The zero time is returned if
+// neither is valid.
+func responseTime(response *fetch.Response) time.Time {
+	for _, name := range []string{"Last-Modified", "Date"} {
+		if t, err := http.ParseTime(response.GetHeader(name)); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
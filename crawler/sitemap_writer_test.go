@@ -0,0 +1,174 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pageResult returns a successful result for an HTML page.
+func pageResult(rawURL string, headers map[string]string) *Result {
+	u, _ := url.Parse(rawURL)
+	return &Result{
+		URL:        u,
+		StatusCode: 200,
+		Response: &fetch.Response{
+			URL:         rawURL,
+			StatusCode:  200,
+			ContentType: "text/html; charset=utf-8",
+			Headers:     headers,
+		},
+	}
+}
+
+func TestWriteSitemap(t *testing.T) {
+	redirected := pageResult("https://example.com/old", nil)
+	redirected.FinalURL, _ = url.Parse("https://example.com/new")
+	failed := pageResult("https://example.com/error", nil)
+	failed.Error = errors.New("request failed")
+	duplicate := pageResult("https://example.com/copy", nil)
+	duplicate.Duplicate = true
+	noIndex := pageResult("https://example.com/private", nil)
+	noIndex.NoIndex = true
+	pdf := pageResult("https://example.com/report.pdf", nil)
+	pdf.Response.ContentType = "application/pdf"
+
+	results := []*Result{
+		pageResult("https://example.com/b?x=1&y=2", map[string]string{
+			"Last-Modified": "Wed, 21 Oct 2015 07:28:00 GMT",
+			"Date":          "Thu, 01 Jan 2026 00:00:00 GMT",
+		}),
+		pageResult("https://example.com/a", map[string]string{
+			"Date": "Thu, 01 Jan 2026 00:00:00 GMT",
+		}),
+		redirected,
+		failed,
+		duplicate,
+		noIndex,
+		pdf,
+		nil,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSitemap(&buf, results))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>https://example.com/a</loc><lastmod>2026-01-01T00:00:00Z</lastmod></url>
+<url><loc>https://example.com/b?x=1&amp;y=2</loc><lastmod>2015-10-21T07:28:00Z</lastmod></url>
+<url><loc>https://example.com/new</loc></url>
+</urlset>
+`, buf.String())
+
+	// The output is readable by the sitemap loader
+	var doc sitemapDocument
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "urlset", doc.XMLName.Local)
+	assert.Len(t, doc.URLs, 3)
+}
+
+func TestWriteSitemap_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteSitemap(&buf, nil))
+	var doc sitemapDocument
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "urlset", doc.XMLName.Local)
+	assert.Empty(t, doc.URLs)
+}
+
+func TestSitemapCollector_WriteFiles(t *testing.T) {
+	collector := NewSitemapCollector()
+	collector.maxURLs = 2
+	for i := 0; i < 5; i++ {
+		collector.Add(context.Background(), pageResult(fmt.Sprintf("https://example.com/%d", i), nil))
+	}
+	require.Equal(t, 5, collector.Len())
+
+	// Too many URLs for a single sitemap
+	var buf bytes.Buffer
+	_, err := collector.WriteTo(&buf)
+	require.ErrorIs(t, err, ErrSitemapTooLarge)
+
+	dir := t.TempDir()
+	paths, err := collector.WriteFiles(dir, "https://example.com/sitemaps/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "sitemap-1.xml"),
+		filepath.Join(dir, "sitemap-2.xml"),
+		filepath.Join(dir, "sitemap-3.xml"),
+		filepath.Join(dir, "sitemap.xml"),
+	}, paths)
+
+	data, err := os.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	require.NoError(t, err)
+	var index sitemapDocument
+	require.NoError(t, xml.Unmarshal(data, &index))
+	assert.Equal(t, "sitemapindex", index.XMLName.Local)
+	assert.Equal(t, []sitemapLoc{
+		{Loc: "https://example.com/sitemaps/sitemap-1.xml"},
+		{Loc: "https://example.com/sitemaps/sitemap-2.xml"},
+		{Loc: "https://example.com/sitemaps/sitemap-3.xml"},
+	}, index.Sitemaps)
+
+	var locs []string
+	for _, path := range paths[:3] {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var doc sitemapDocument
+		require.NoError(t, xml.Unmarshal(data, &doc))
+		for _, u := range doc.URLs {
+			locs = append(locs, u.Loc)
+		}
+	}
+	assert.Equal(t, []string{
+		"https://example.com/0",
+		"https://example.com/1",
+		"https://example.com/2",
+		"https://example.com/3",
+		"https://example.com/4",
+	}, locs)
+}
+
+func TestSitemapCollector_MaxBytes(t *testing.T) {
+	collector := NewSitemapCollector()
+	collector.maxBytes = len(sitemapHeader) + len(sitemapFooter) + 100
+	for i := 0; i < 3; i++ {
+		collector.Add(context.Background(), pageResult(fmt.Sprintf("https://example.com/%d", i), nil))
+	}
+	chunks, err := collector.chunks()
+	require.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk), collector.maxBytes)
+	}
+
+	// A URL that cannot fit in a sitemap on its own is an error
+	collector.maxBytes = len(sitemapHeader) + len(sitemapFooter) + 10
+	_, err = collector.chunks()
+	assert.ErrorIs(t, err, ErrSitemapTooLarge)
+}
+
+func TestSitemapCollector_Crawl(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+	mockFetcher.AddPage("https://example.com", "/about", "/missing")
+	mockFetcher.AddPage("https://example.com/about")
+	mockFetcher.AddStatus("https://example.com/missing", 404)
+
+	collector := NewSitemapCollector()
+	crawler := New(Options{
+		Workers:        2,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+	})
+	require.NoError(t, crawler.Crawl(context.Background(), []string{"https://example.com"}, collector.Add))
+	assert.Equal(t, 2, collector.Len())
+}
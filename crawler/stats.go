@@ -1,12 +1,186 @@
 package crawler
 
-import "sync/atomic"
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencySampleSize is the number of fetch latencies kept for estimating
+// percentiles. Beyond this, samples are replaced at random so that they remain
+// representative of the whole crawl.
+const latencySampleSize = 1024
+
+// parseErrorLimit is the number of parse errors retained. Later errors are
+// only counted.
+const parseErrorLimit = 100
+
+// StopReason describes why a crawl stopped.
+type StopReason string
+
+const (
+	// ReasonIdle means there was no more work to do.
+	ReasonIdle StopReason = "idle"
+
+	// ReasonMaxURLs means URLs were left uncrawled because of MaxURLs.
+	ReasonMaxURLs StopReason = "max_urls"
+
+	// ReasonTimeout means the crawl ran for MaxDuration.
+	ReasonTimeout StopReason = "timeout"
+
+	// ReasonContextCancelled means the context passed to Crawl was cancelled
+	// or its deadline passed.
+	ReasonContextCancelled StopReason = "context_cancelled"
+
+	// ReasonStopped means Stop was called.
+	ReasonStopped StopReason = "stopped"
+)
 
 // CrawlerStats tracks crawling statistics. All methods are thread-safe.
 type CrawlerStats struct {
-	processed int64
-	succeeded int64
-	failed    int64
+	processed       int64
+	succeeded       int64
+	failed          int64
+	skipped         int64
+	dropped         int64
+	duplicates      int64
+	retried         int64
+	domainLimited   int64
+	oversized       int64
+	notModified     int64
+	traps           int64
+	blocked         int64
+	robotsDenied    int64
+	cancelled       int64
+	cacheHits       int64
+	cacheMisses     int64
+	cacheStores     int64
+	bytesDownloaded int64
+	latency         latencyStats
+	domains         sync.Map
+	statusCodes     sync.Map
+	parseMutex      sync.Mutex
+	parseErrors     []ParseError
+	parseDropped    int64
+	stopMutex       sync.Mutex
+	stopReason      StopReason
+}
+
+// ParseError records a page that failed to parse.
+type ParseError struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// LatencySummary summarizes the time taken by fetches. The percentiles are
+// estimated from a random sample of fetches.
+type LatencySummary struct {
+	Count int64         `json:"count"`
+	Min   time.Duration `json:"min"`
+	Max   time.Duration `json:"max"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+}
+
+// latencyStats accumulates fetch latencies.
+type latencyStats struct {
+	mutex   sync.Mutex
+	count   int64
+	total   time.Duration
+	min     time.Duration
+	max     time.Duration
+	samples []time.Duration
+}
+
+func (l *latencyStats) record(d time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.count++
+	l.total += d
+	if l.count == 1 || d < l.min {
+		l.min = d
+	}
+	if d > l.max {
+		l.max = d
+	}
+	if len(l.samples) < latencySampleSize {
+		l.samples = append(l.samples, d)
+	} else if i := rand.N(l.count); i < latencySampleSize {
+		l.samples[i] = d
+	}
+}
+
+// restore sets the totals to those in the summary. The samples are not part
+// of the summary, so percentiles are estimated from the fetches recorded
+// after the restore.
+func (l *latencyStats) restore(summary LatencySummary) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.count = summary.Count
+	l.total = summary.Mean * time.Duration(summary.Count)
+	l.min = summary.Min
+	l.max = summary.Max
+	l.samples = nil
+}
+
+func (l *latencyStats) summary() LatencySummary {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.count == 0 {
+		return LatencySummary{}
+	}
+	sorted := slices.Clone(l.samples)
+	slices.Sort(sorted)
+	percentile := func(p float64) time.Duration {
+		if len(sorted) == 0 {
+			return 0
+		}
+		return sorted[int(float64(len(sorted)-1)*p)]
+	}
+	return LatencySummary{
+		Count: l.count,
+		Min:   l.min,
+		Max:   l.max,
+		Mean:  l.total / time.Duration(l.count),
+		P50:   percentile(0.50),
+		P95:   percentile(0.95),
+	}
+}
+
+// DomainStats holds the statistics for a single domain.
+type DomainStats struct {
+	Processed int64 `json:"processed"`
+	Succeeded int64 `json:"succeeded"`
+	Failed    int64 `json:"failed"`
+	Bytes     int64 `json:"bytes"`
+
+	// Concurrency is the current limit on concurrent requests to the domain
+	// with Options.Adaptive, or zero otherwise.
+	Concurrency int64 `json:"concurrency,omitempty"`
+}
+
+// String returns a human readable summary of the statistics.
+func (d DomainStats) String() string {
+	s := fmt.Sprintf("processed=%d succeeded=%d failed=%d bytes=%d",
+		d.Processed, d.Succeeded, d.Failed, d.Bytes)
+	if d.Concurrency > 0 {
+		s += fmt.Sprintf(" concurrency=%d", d.Concurrency)
+	}
+	return s
+}
+
+// domainCounters holds the atomic counters for a single domain.
+type domainCounters struct {
+	processed   int64
+	succeeded   int64
+	failed      int64
+	bytes       int64
+	concurrency int64
 }
 
 // GetProcessed returns the number of URLs processed
@@ -24,6 +198,213 @@ func (s *CrawlerStats) GetFailed() int64 {
 	return atomic.LoadInt64(&s.failed)
 }
 
+// GetSkipped returns the number of URLs skipped because of robots.txt or
+// their content type
+func (s *CrawlerStats) GetSkipped() int64 {
+	return atomic.LoadInt64(&s.skipped)
+}
+
+// GetDropped returns the number of discovered URLs dropped because the queue
+// was full
+func (s *CrawlerStats) GetDropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// GetDuplicates returns the number of URLs whose content was a duplicate
+func (s *CrawlerStats) GetDuplicates() int64 {
+	return atomic.LoadInt64(&s.duplicates)
+}
+
+// GetRetried returns the number of fetch retries that were attempted
+func (s *CrawlerStats) GetRetried() int64 {
+	return atomic.LoadInt64(&s.retried)
+}
+
+// GetStopReason returns why the most recent crawl stopped, or an empty
+// reason if it is still running
+func (s *CrawlerStats) GetStopReason() StopReason {
+	s.stopMutex.Lock()
+	defer s.stopMutex.Unlock()
+	return s.stopReason
+}
+
+// SetStopReason records why the crawl stopped. Only the first reason is kept,
+// since later ones are a consequence of it. It reports whether the reason was
+// recorded.
+func (s *CrawlerStats) SetStopReason(reason StopReason) bool {
+	s.stopMutex.Lock()
+	defer s.stopMutex.Unlock()
+	if s.stopReason != "" {
+		return false
+	}
+	s.stopReason = reason
+	return true
+}
+
+// reset clears all statistics.
+func (s *CrawlerStats) reset() {
+	s.restore(StatsSnapshot{})
+	s.resetStopReason()
+}
+
+// resetStopReason clears the stop reason when a crawl starts.
+func (s *CrawlerStats) resetStopReason() {
+	s.stopMutex.Lock()
+	defer s.stopMutex.Unlock()
+	s.stopReason = ""
+}
+
+// GetDomainLimited returns the number of URLs dropped because their host
+// reached the per-domain limit
+func (s *CrawlerStats) GetDomainLimited() int64 {
+	return atomic.LoadInt64(&s.domainLimited)
+}
+
+// GetOversized returns the number of responses that exceeded the maximum size
+func (s *CrawlerStats) GetOversized() int64 {
+	return atomic.LoadInt64(&s.oversized)
+}
+
+// GetNotModified returns the number of cached pages the server reported as
+// not modified
+func (s *CrawlerStats) GetNotModified() int64 {
+	return atomic.LoadInt64(&s.notModified)
+}
+
+// GetTraps returns the number of links dropped as suspected crawl traps
+func (s *CrawlerStats) GetTraps() int64 {
+	return atomic.LoadInt64(&s.traps)
+}
+
+// GetBlocked returns the number of URLs dropped because their domain is blocked
+func (s *CrawlerStats) GetBlocked() int64 {
+	return atomic.LoadInt64(&s.blocked)
+}
+
+// GetRobotsDenied returns the number of URLs skipped because robots.txt
+// disallowed them or could not be loaded
+func (s *CrawlerStats) GetRobotsDenied() int64 {
+	return atomic.LoadInt64(&s.robotsDenied)
+}
+
+// GetCancelled returns the number of fetches interrupted by the crawl being
+// cancelled
+func (s *CrawlerStats) GetCancelled() int64 {
+	return atomic.LoadInt64(&s.cancelled)
+}
+
+// GetCacheHits returns the number of pages found in the cache
+func (s *CrawlerStats) GetCacheHits() int64 {
+	return atomic.LoadInt64(&s.cacheHits)
+}
+
+// GetCacheMisses returns the number of pages not found in the cache
+func (s *CrawlerStats) GetCacheMisses() int64 {
+	return atomic.LoadInt64(&s.cacheMisses)
+}
+
+// GetCacheStores returns the number of pages stored in the cache
+func (s *CrawlerStats) GetCacheStores() int64 {
+	return atomic.LoadInt64(&s.cacheStores)
+}
+
+// ParseErrors returns a copy of the parse errors recorded so far, in the order
+// they occurred. Only the first 100 are retained; the number beyond that is
+// reported by GetParseErrorsDropped.
+func (s *CrawlerStats) ParseErrors() []ParseError {
+	s.parseMutex.Lock()
+	defer s.parseMutex.Unlock()
+	return slices.Clone(s.parseErrors)
+}
+
+// GetParseErrorsDropped returns the number of parse errors that were counted
+// but not retained
+func (s *CrawlerStats) GetParseErrorsDropped() int64 {
+	s.parseMutex.Lock()
+	defer s.parseMutex.Unlock()
+	return s.parseDropped
+}
+
+// RecordParseError records that the page at the URL failed to parse
+func (s *CrawlerStats) RecordParseError(url string, err error) {
+	s.parseMutex.Lock()
+	defer s.parseMutex.Unlock()
+	if len(s.parseErrors) >= parseErrorLimit {
+		s.parseDropped++
+		return
+	}
+	s.parseErrors = append(s.parseErrors, ParseError{URL: url, Error: err.Error()})
+}
+
+// GetBytesDownloaded returns the number of bytes of page content fetched
+func (s *CrawlerStats) GetBytesDownloaded() int64 {
+	return atomic.LoadInt64(&s.bytesDownloaded)
+}
+
+// GetLatency returns a summary of the time taken by fetches
+func (s *CrawlerStats) GetLatency() LatencySummary {
+	return s.latency.summary()
+}
+
+// ByDomain returns a copy of the statistics for each domain crawled so far
+func (s *CrawlerStats) ByDomain() map[string]DomainStats {
+	result := map[string]DomainStats{}
+	s.domains.Range(func(key, value any) bool {
+		counters := value.(*domainCounters)
+		result[key.(string)] = DomainStats{
+			Processed:   atomic.LoadInt64(&counters.processed),
+			Succeeded:   atomic.LoadInt64(&counters.succeeded),
+			Failed:      atomic.LoadInt64(&counters.failed),
+			Bytes:       atomic.LoadInt64(&counters.bytes),
+			Concurrency: atomic.LoadInt64(&counters.concurrency),
+		}
+		return true
+	})
+	return result
+}
+
+// domainSnapshot returns the statistics for each domain, or nil if no domain
+// has been crawled.
+func (s *CrawlerStats) domainSnapshot() map[string]DomainStats {
+	if domains := s.ByDomain(); len(domains) > 0 {
+		return domains
+	}
+	return nil
+}
+
+// StatusCodes returns the number of responses received with each HTTP status
+// code so far. Only the final response for each fetch is counted, after
+// retries and redirects. The map is nil if no responses have been received.
+func (s *CrawlerStats) StatusCodes() map[int]int64 {
+	var result map[int]int64
+	s.statusCodes.Range(func(key, value any) bool {
+		if result == nil {
+			result = map[int]int64{}
+		}
+		result[key.(int)] = atomic.LoadInt64(value.(*int64))
+		return true
+	})
+	return result
+}
+
+// RecordStatusCode atomically increments the count for the status code
+func (s *CrawlerStats) RecordStatusCode(code int) {
+	value, ok := s.statusCodes.Load(code)
+	if !ok {
+		value, _ = s.statusCodes.LoadOrStore(code, new(int64))
+	}
+	atomic.AddInt64(value.(*int64), 1)
+}
+
+// domain returns the counters for the domain, creating them if needed
+func (s *CrawlerStats) domain(name string) *domainCounters {
+	if value, ok := s.domains.Load(name); ok {
+		return value.(*domainCounters)
+	}
+	value, _ := s.domains.LoadOrStore(name, &domainCounters{})
+	return value.(*domainCounters)
+}
+
 // IncrementProcessed atomically increments the processed counter
 func (s *CrawlerStats) IncrementProcessed() {
 	atomic.AddInt64(&s.processed, 1)
@@ -38,3 +419,218 @@ func (s *CrawlerStats) IncrementSucceeded() {
 func (s *CrawlerStats) IncrementFailed() {
 	atomic.AddInt64(&s.failed, 1)
 }
+
+// IncrementSkipped atomically increments the skipped counter
+func (s *CrawlerStats) IncrementSkipped() {
+	atomic.AddInt64(&s.skipped, 1)
+}
+
+// IncrementDropped atomically increments the dropped counter
+func (s *CrawlerStats) IncrementDropped() {
+	atomic.AddInt64(&s.dropped, 1)
+}
+
+// IncrementDuplicates atomically increments the duplicates counter
+func (s *CrawlerStats) IncrementDuplicates() {
+	atomic.AddInt64(&s.duplicates, 1)
+}
+
+// IncrementRetried atomically increments the retried counter
+func (s *CrawlerStats) IncrementRetried() {
+	atomic.AddInt64(&s.retried, 1)
+}
+
+// IncrementDomainLimited atomically increments the domain limited counter
+func (s *CrawlerStats) IncrementDomainLimited() {
+	atomic.AddInt64(&s.domainLimited, 1)
+}
+
+// IncrementOversized atomically increments the oversized counter
+func (s *CrawlerStats) IncrementOversized() {
+	atomic.AddInt64(&s.oversized, 1)
+}
+
+// IncrementNotModified atomically increments the not modified counter
+func (s *CrawlerStats) IncrementNotModified() {
+	atomic.AddInt64(&s.notModified, 1)
+}
+
+// IncrementTraps atomically increments the traps counter
+func (s *CrawlerStats) IncrementTraps() {
+	atomic.AddInt64(&s.traps, 1)
+}
+
+// IncrementBlocked atomically increments the blocked counter
+func (s *CrawlerStats) IncrementBlocked() {
+	atomic.AddInt64(&s.blocked, 1)
+}
+
+// IncrementRobotsDenied atomically increments the robots denied counter
+func (s *CrawlerStats) IncrementRobotsDenied() {
+	atomic.AddInt64(&s.robotsDenied, 1)
+}
+
+// IncrementCancelled atomically increments the cancelled counter
+func (s *CrawlerStats) IncrementCancelled() {
+	atomic.AddInt64(&s.cancelled, 1)
+}
+
+// IncrementCacheHits atomically increments the cache hits counter
+func (s *CrawlerStats) IncrementCacheHits() {
+	atomic.AddInt64(&s.cacheHits, 1)
+}
+
+// IncrementCacheMisses atomically increments the cache misses counter
+func (s *CrawlerStats) IncrementCacheMisses() {
+	atomic.AddInt64(&s.cacheMisses, 1)
+}
+
+// IncrementCacheStores atomically increments the cache stores counter
+func (s *CrawlerStats) IncrementCacheStores() {
+	atomic.AddInt64(&s.cacheStores, 1)
+}
+
+// AddBytesDownloaded atomically adds to the bytes downloaded counter
+func (s *CrawlerStats) AddBytesDownloaded(n int64) {
+	atomic.AddInt64(&s.bytesDownloaded, n)
+}
+
+// RecordLatency records the time taken by a fetch
+func (s *CrawlerStats) RecordLatency(d time.Duration) {
+	s.latency.record(d)
+}
+
+// IncrementDomainProcessed atomically increments the processed counter for
+// the domain
+func (s *CrawlerStats) IncrementDomainProcessed(domain string) {
+	atomic.AddInt64(&s.domain(domain).processed, 1)
+}
+
+// IncrementDomainSucceeded atomically increments the succeeded counter for
+// the domain
+func (s *CrawlerStats) IncrementDomainSucceeded(domain string) {
+	atomic.AddInt64(&s.domain(domain).succeeded, 1)
+}
+
+// IncrementDomainFailed atomically increments the failed counter for the
+// domain
+func (s *CrawlerStats) IncrementDomainFailed(domain string) {
+	atomic.AddInt64(&s.domain(domain).failed, 1)
+}
+
+// AddDomainBytes atomically adds to the bytes downloaded from the domain
+func (s *CrawlerStats) AddDomainBytes(domain string, n int64) {
+	atomic.AddInt64(&s.domain(domain).bytes, n)
+}
+
+// SetDomainConcurrency atomically sets the concurrency limit for the domain
+func (s *CrawlerStats) SetDomainConcurrency(domain string, limit int) {
+	atomic.StoreInt64(&s.domain(domain).concurrency, int64(limit))
+}
+
+// StatsSnapshot is a point-in-time copy of the crawler statistics.
+type StatsSnapshot struct {
+	Processed       int64                  `json:"processed"`
+	Succeeded       int64                  `json:"succeeded"`
+	Failed          int64                  `json:"failed"`
+	Skipped         int64                  `json:"skipped"`
+	Dropped         int64                  `json:"dropped"`
+	Duplicates      int64                  `json:"duplicates"`
+	Retried         int64                  `json:"retried"`
+	DomainLimited   int64                  `json:"domain_limited"`
+	Oversized       int64                  `json:"oversized"`
+	NotModified     int64                  `json:"not_modified"`
+	Traps           int64                  `json:"traps"`
+	Blocked         int64                  `json:"blocked"`
+	RobotsDenied    int64                  `json:"robots_denied"`
+	Cancelled       int64                  `json:"cancelled"`
+	CacheHits       int64                  `json:"cache_hits"`
+	CacheMisses     int64                  `json:"cache_misses"`
+	CacheStores     int64                  `json:"cache_stores"`
+	BytesDownloaded int64                  `json:"bytes_downloaded"`
+	Latency         LatencySummary         `json:"latency"`
+	Domains         map[string]DomainStats `json:"domains,omitempty"`
+	StatusCodes     map[int]int64          `json:"status_codes,omitempty"`
+	StopReason      StopReason             `json:"stop_reason,omitempty"`
+	ParseErrors     []ParseError           `json:"parse_errors,omitempty"`
+	ParseDropped    int64                  `json:"parse_errors_dropped,omitempty"`
+}
+
+// Snapshot returns a copy of the current statistics
+func (s *CrawlerStats) Snapshot() StatsSnapshot {
+	return StatsSnapshot{
+		Processed:       s.GetProcessed(),
+		Succeeded:       s.GetSucceeded(),
+		Failed:          s.GetFailed(),
+		Skipped:         s.GetSkipped(),
+		Dropped:         s.GetDropped(),
+		Duplicates:      s.GetDuplicates(),
+		Retried:         s.GetRetried(),
+		DomainLimited:   s.GetDomainLimited(),
+		Oversized:       s.GetOversized(),
+		NotModified:     s.GetNotModified(),
+		Traps:           s.GetTraps(),
+		Blocked:         s.GetBlocked(),
+		RobotsDenied:    s.GetRobotsDenied(),
+		Cancelled:       s.GetCancelled(),
+		CacheHits:       s.GetCacheHits(),
+		CacheMisses:     s.GetCacheMisses(),
+		CacheStores:     s.GetCacheStores(),
+		BytesDownloaded: s.GetBytesDownloaded(),
+		Latency:         s.GetLatency(),
+		Domains:         s.domainSnapshot(),
+		StatusCodes:     s.StatusCodes(),
+		StopReason:      s.GetStopReason(),
+		ParseErrors:     s.ParseErrors(),
+		ParseDropped:    s.GetParseErrorsDropped(),
+	}
+}
+
+// MarshalJSON encodes a snapshot of the statistics, so that stats may be
+// marshalled directly while the crawl is running
+func (s *CrawlerStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Snapshot())
+}
+
+// restore sets the counters to the values in the snapshot
+func (s *CrawlerStats) restore(snapshot StatsSnapshot) {
+	atomic.StoreInt64(&s.processed, snapshot.Processed)
+	atomic.StoreInt64(&s.succeeded, snapshot.Succeeded)
+	atomic.StoreInt64(&s.failed, snapshot.Failed)
+	atomic.StoreInt64(&s.skipped, snapshot.Skipped)
+	atomic.StoreInt64(&s.dropped, snapshot.Dropped)
+	atomic.StoreInt64(&s.duplicates, snapshot.Duplicates)
+	atomic.StoreInt64(&s.retried, snapshot.Retried)
+	atomic.StoreInt64(&s.domainLimited, snapshot.DomainLimited)
+	atomic.StoreInt64(&s.oversized, snapshot.Oversized)
+	atomic.StoreInt64(&s.notModified, snapshot.NotModified)
+	atomic.StoreInt64(&s.traps, snapshot.Traps)
+	atomic.StoreInt64(&s.blocked, snapshot.Blocked)
+	atomic.StoreInt64(&s.robotsDenied, snapshot.RobotsDenied)
+	atomic.StoreInt64(&s.cancelled, snapshot.Cancelled)
+	atomic.StoreInt64(&s.cacheHits, snapshot.CacheHits)
+	atomic.StoreInt64(&s.cacheMisses, snapshot.CacheMisses)
+	atomic.StoreInt64(&s.cacheStores, snapshot.CacheStores)
+	atomic.StoreInt64(&s.bytesDownloaded, snapshot.BytesDownloaded)
+	s.latency.restore(snapshot.Latency)
+	// The concurrency limits belong to the adaptive limiter, which starts
+	// afresh, so they are not restored
+	s.domains.Clear()
+	for name, domain := range snapshot.Domains {
+		s.domains.Store(name, &domainCounters{
+			processed: domain.Processed,
+			succeeded: domain.Succeeded,
+			failed:    domain.Failed,
+			bytes:     domain.Bytes,
+		})
+	}
+	s.statusCodes.Clear()
+	for code, count := range snapshot.StatusCodes {
+		value := count
+		s.statusCodes.Store(code, &value)
+	}
+	s.parseMutex.Lock()
+	s.parseErrors = slices.Clone(snapshot.ParseErrors)
+	s.parseDropped = snapshot.ParseDropped
+	s.parseMutex.Unlock()
+}
@@ -0,0 +1,153 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrawlerStats_Latency(t *testing.T) {
+	stats := &CrawlerStats{}
+	assert.Equal(t, LatencySummary{}, stats.GetLatency())
+
+	for i := 100; i >= 1; i-- {
+		stats.RecordLatency(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, LatencySummary{
+		Count: 100,
+		Min:   time.Millisecond,
+		Max:   100 * time.Millisecond,
+		Mean:  50500 * time.Microsecond,
+		P50:   50 * time.Millisecond,
+		P95:   95 * time.Millisecond,
+	}, stats.GetLatency())
+}
+
+func TestCrawlerStats_MarshalJSON(t *testing.T) {
+	stats := &CrawlerStats{}
+	stats.IncrementProcessed()
+	stats.IncrementProcessed()
+	stats.IncrementSucceeded()
+	stats.IncrementFailed()
+	stats.AddBytesDownloaded(42)
+	stats.IncrementCacheHits()
+	stats.SetStopReason(ReasonIdle)
+
+	data, err := json.Marshal(stats)
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, float64(2), decoded["processed"])
+	assert.Equal(t, float64(1), decoded["succeeded"])
+	assert.Equal(t, float64(1), decoded["failed"])
+	assert.Equal(t, float64(42), decoded["bytes_downloaded"])
+	assert.Equal(t, float64(1), decoded["cache_hits"])
+	assert.Equal(t, float64(0), decoded["cache_misses"])
+	assert.Equal(t, "idle", decoded["stop_reason"])
+
+	var snapshot StatsSnapshot
+	require.NoError(t, json.Unmarshal(data, &snapshot))
+	assert.Equal(t, stats.Snapshot(), snapshot)
+}
+
+func TestCrawlerStats_StatusCodes(t *testing.T) {
+	stats := &CrawlerStats{}
+	assert.Nil(t, stats.StatusCodes())
+
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			switch {
+			case i < 90:
+				stats.RecordStatusCode(200)
+			case i < 98:
+				stats.RecordStatusCode(404)
+			default:
+				stats.RecordStatusCode(503)
+			}
+		}()
+	}
+	wg.Wait()
+	expected := map[int]int64{200: 90, 404: 8, 503: 2}
+	assert.Equal(t, expected, stats.StatusCodes())
+
+	// The counts survive a round trip through JSON and a restore
+	data, err := json.Marshal(stats)
+	require.NoError(t, err)
+	var snapshot StatsSnapshot
+	require.NoError(t, json.Unmarshal(data, &snapshot))
+	assert.Equal(t, expected, snapshot.StatusCodes)
+	restored := &CrawlerStats{}
+	restored.restore(snapshot)
+	assert.Equal(t, expected, restored.StatusCodes())
+	restored.reset()
+	assert.Nil(t, restored.StatusCodes())
+}
+
+func TestCrawlerStats_Restore(t *testing.T) {
+	stats := &CrawlerStats{}
+	stats.IncrementDomainProcessed("example.com")
+	stats.IncrementDomainSucceeded("example.com")
+	stats.AddDomainBytes("example.com", 100)
+	stats.IncrementDomainProcessed("other.com")
+	stats.IncrementDomainFailed("other.com")
+	for i := 1; i <= 4; i++ {
+		stats.RecordLatency(time.Duration(i) * time.Millisecond)
+	}
+
+	// Domains and latency survive a round trip through JSON and a restore
+	data, err := json.Marshal(stats)
+	require.NoError(t, err)
+	var snapshot StatsSnapshot
+	require.NoError(t, json.Unmarshal(data, &snapshot))
+	assert.Equal(t, map[string]DomainStats{
+		"example.com": {Processed: 1, Succeeded: 1, Bytes: 100},
+		"other.com":   {Processed: 1, Failed: 1},
+	}, snapshot.Domains)
+
+	restored := &CrawlerStats{}
+	restored.restore(snapshot)
+	assert.Equal(t, snapshot.Domains, restored.ByDomain())
+	latency := restored.GetLatency()
+	assert.Equal(t, int64(4), latency.Count)
+	assert.Equal(t, time.Millisecond, latency.Min)
+	assert.Equal(t, 4*time.Millisecond, latency.Max)
+	assert.Equal(t, 2500*time.Microsecond, latency.Mean)
+
+	// Later fetches add to the restored totals
+	restored.RecordLatency(5 * time.Millisecond)
+	restored.IncrementDomainProcessed("example.com")
+	assert.Equal(t, int64(5), restored.GetLatency().Count)
+	assert.Equal(t, 3*time.Millisecond, restored.GetLatency().Mean)
+	assert.Equal(t, int64(2), restored.ByDomain()["example.com"].Processed)
+
+	restored.reset()
+	assert.Empty(t, restored.ByDomain())
+	assert.Equal(t, LatencySummary{}, restored.GetLatency())
+}
+
+func TestCrawlerStats_ParseErrors(t *testing.T) {
+	stats := &CrawlerStats{}
+	assert.Empty(t, stats.ParseErrors())
+
+	for i := 0; i < parseErrorLimit+5; i++ {
+		stats.RecordParseError(fmt.Sprintf("https://example.com/%d", i), errors.New("bad page"))
+	}
+	parseErrors := stats.ParseErrors()
+	require.Len(t, parseErrors, parseErrorLimit)
+	assert.Equal(t, ParseError{URL: "https://example.com/0", Error: "bad page"}, parseErrors[0])
+	assert.Equal(t, int64(5), stats.GetParseErrorsDropped())
+
+	snapshot := stats.Snapshot()
+	assert.Len(t, snapshot.ParseErrors, parseErrorLimit)
+	assert.Equal(t, int64(5), snapshot.ParseDropped)
+}
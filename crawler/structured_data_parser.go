@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/myzie/web"
+	"github.com/myzie/web/fetch"
+)
+
+// StructuredDataParser is a Parser that extracts the JSON-LD structured data
+// embedded in a page's <script type="application/ld+json"> blocks, returning
+// a []map[string]any with one entry per item. Blocks holding an array are
+// flattened, as are objects holding an @graph, whose items inherit the
+// object's @context. Malformed blocks are skipped with a warning.
+type StructuredDataParser struct {
+	// Logger receives warnings about malformed blocks. Defaults to
+	// slog.Default().
+	Logger *slog.Logger
+}
+
+// Parse extracts the structured data items of the page.
+func (p StructuredDataParser) Parse(ctx context.Context, page *fetch.Response) (any, error) {
+	items := []map[string]any{}
+	if strings.TrimSpace(page.HTML) == "" {
+		return items, nil
+	}
+	doc, err := web.NewDocument(page.HTML)
+	if err != nil {
+		return nil, err
+	}
+	doc.GoqueryDocument().Find("script[type]").Each(func(i int, s *goquery.Selection) {
+		mediaType, _, _ := strings.Cut(s.AttrOr("type", ""), ";")
+		if !strings.EqualFold(strings.TrimSpace(mediaType), "application/ld+json") {
+			return
+		}
+		var value any
+		if err := json.Unmarshal([]byte(trimScript(s.Text())), &value); err != nil {
+			p.logger().Warn("skipping malformed structured data",
+				slog.String("url", page.URL),
+				slog.String("error", err.Error()))
+			return
+		}
+		items = appendItems(items, value, nil)
+	})
+	return items, nil
+}
+
+func (p StructuredDataParser) logger() *slog.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return slog.Default()
+}
+
+// trimScript removes whitespace and the HTML comment or CDATA markers that
+// some sites wrap script contents in.
+func trimScript(text string) string {
+	text = strings.TrimSpace(text)
+	for _, marker := range [][2]string{{"<!--", "-->"}, {"//<![CDATA[", "//]]>"}, {"<![CDATA[", "]]>"}} {
+		if strings.HasPrefix(text, marker[0]) && strings.HasSuffix(text, marker[1]) {
+			text = strings.TrimSpace(text[len(marker[0]) : len(text)-len(marker[1])])
+		}
+	}
+	return text
+}
+
+// appendItems appends the structured data items in value, flattening arrays
+// and @graph objects. Items without an @context are given the context of the
+// enclosing object, if any.
+func appendItems(items []map[string]any, value any, ldContext any) []map[string]any {
+	switch value := value.(type) {
+	case []any:
+		for _, element := range value {
+			items = appendItems(items, element, ldContext)
+		}
+	case map[string]any:
+		if c, ok := value["@context"]; ok {
+			ldContext = c
+		}
+		if graph, ok := value["@graph"]; ok {
+			return appendItems(items, graph, ldContext)
+		}
+		if _, ok := value["@context"]; !ok && ldContext != nil {
+			value["@context"] = ldContext
+		}
+		items = append(items, value)
+	}
+	return items
+}
@@ -0,0 +1,78 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructuredDataParser(t *testing.T) {
+	html := `<html><head>
+<script type="application/ld+json">
+{"@context": "https://schema.org", "@type": "Product", "name": "Widget"}
+</script>
+<script type="application/ld+json">
+[{"@type": "BreadcrumbList"}, {"@type": "WebSite", "url": "https://example.com"}]
+</script>
+<script type="Application/LD+JSON; charset=utf-8">
+<!--
+{"@context": "https://schema.org", "@graph": [
+	{"@type": "Organization", "name": "Example"},
+	{"@context": "https://example.com/ctx", "@type": "Person"}
+]}
+-->
+</script>
+<script type="application/ld+json">{"@type": "Broken",}</script>
+<script type="text/javascript">var data = {"@type": "Ignored"};</script>
+</head><body></body></html>`
+
+	var logs bytes.Buffer
+	parser := StructuredDataParser{Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	parsed, err := parser.Parse(context.Background(), &fetch.Response{
+		URL:  "https://example.com",
+		HTML: html,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []map[string]any{
+		{"@context": "https://schema.org", "@type": "Product", "name": "Widget"},
+		{"@type": "BreadcrumbList"},
+		{"@type": "WebSite", "url": "https://example.com"},
+		{"@context": "https://schema.org", "@type": "Organization", "name": "Example"},
+		{"@context": "https://example.com/ctx", "@type": "Person"},
+	}, parsed)
+
+	// The malformed block is skipped with a warning
+	assert.Contains(t, logs.String(), "skipping malformed structured data")
+	assert.Contains(t, logs.String(), "url=https://example.com")
+}
+
+func TestStructuredDataParser_None(t *testing.T) {
+	parsed, err := StructuredDataParser{}.Parse(context.Background(), &fetch.Response{
+		HTML: "<p>No structured data</p>",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]any{}, parsed)
+}
+
+func TestStructuredDataParser_WithMetadata(t *testing.T) {
+	parser := NewMultiParser(
+		NamedParser{Name: "metadata", Parser: MetadataParser{}},
+		NamedParser{Name: "structured_data", Parser: StructuredDataParser{}},
+	)
+	parsed, err := parser.Parse(context.Background(), &fetch.Response{
+		URL: "https://example.com",
+		HTML: `<title>Widget</title>
+<script type="application/ld+json">{"@type": "Product"}</script>`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"metadata":        &PageMetadata{Title: "Widget"},
+		"structured_data": []map[string]any{{"@type": "Product"}},
+	}, parsed)
+}
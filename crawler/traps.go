@@ -0,0 +1,64 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// pathVariants records the distinct URLs allowed for one host and path.
+// Rejected URLs are not recorded, so that a trap generating endless variants
+// uses no more memory than the limit allows.
+type pathVariants struct {
+	mutex sync.Mutex
+	urls  map[string]struct{}
+}
+
+// isTrap reports whether the URL looks like part of a crawl trap, such as an
+// endless calendar or a path that links to itself relative to its own
+// location. Links that are dropped are counted in the trap stat.
+func (c *Crawler) isTrap(u *url.URL) bool {
+	if c.maxSegmentRepeats > 0 && segmentRepeats(u.Path) > c.maxSegmentRepeats {
+		c.stats.IncrementTraps()
+		return true
+	}
+	if c.maxURLsPerPath > 0 && !c.allowPathVariant(u) {
+		c.stats.IncrementTraps()
+		return true
+	}
+	return false
+}
+
+// allowPathVariant reports whether the URL is within the limit of distinct
+// URLs, which differ only by their query, for its host and path, and records
+// it if so. A URL that was allowed once is always allowed.
+func (c *Crawler) allowPathVariant(u *url.URL) bool {
+	value, _ := c.pathVariants.LoadOrStore(u.Host+u.Path, &pathVariants{urls: map[string]struct{}{}})
+	variants := value.(*pathVariants)
+	variants.mutex.Lock()
+	defer variants.mutex.Unlock()
+	key := u.String()
+	if _, exists := variants.urls[key]; exists {
+		return true
+	}
+	if len(variants.urls) >= c.maxURLsPerPath {
+		return false
+	}
+	variants.urls[key] = struct{}{}
+	return true
+}
+
+// segmentRepeats returns the largest number of times any one segment
+// occurs in the path. For example, "/a/b/a/b/a" has a maximum of 3.
+func segmentRepeats(path string) int {
+	counts := map[string]int{}
+	maxCount := 0
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		counts[segment]++
+		maxCount = max(maxCount, counts[segment])
+	}
+	return maxCount
+}
@@ -0,0 +1,132 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/myzie/web"
+	"github.com/myzie/web/fetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentRepeats(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{path: "", expected: 0},
+		{path: "/", expected: 0},
+		{path: "/docs/guide", expected: 1},
+		{path: "/a/b/a/b/a", expected: 3},
+		{path: "/docs//docs/", expected: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.expected, segmentRepeats(tt.path))
+		})
+	}
+}
+
+func TestCrawler_Traps(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+
+	// An endless calendar, and a relative link that nests itself
+	mockFetcher.AddPage("https://example.com", "/calendar?date=0", "/a/b")
+	for i := 0; i < 10; i++ {
+		mockFetcher.AddPage(fmt.Sprintf("https://example.com/calendar?date=%d", i),
+			fmt.Sprintf("/calendar?date=%d", i+1))
+	}
+	mockFetcher.AddPage("https://example.com/a/b", "/a/b/a/b")
+	mockFetcher.AddPage("https://example.com/a/b/a/b", "/a/b/a/b/a/b")
+
+	crawler := New(Options{
+		Workers:               1,
+		Fetcher:               mockFetcher,
+		FollowBehavior:        FollowSameDomain,
+		Normalize:             web.NormalizeOptions{KeepQuery: true},
+		MaxURLsPerPath:        3,
+		MaxPathSegmentRepeats: 2,
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			assert.NoError(t, result.Error)
+		})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		"https://example.com",
+		"https://example.com/calendar?date=0",
+		"https://example.com/calendar?date=1",
+		"https://example.com/calendar?date=2",
+		"https://example.com/a/b",
+		"https://example.com/a/b/a/b",
+	}, mockFetcher.RequestedURLs())
+	assert.Equal(t, int64(2), crawler.GetStats().GetTraps())
+}
+
+func TestCrawler_AllowPathVariant(t *testing.T) {
+	crawler := New(Options{MaxURLsPerPath: 2})
+	variant := func(i int) *url.URL {
+		u, err := url.Parse(fmt.Sprintf("https://example.com/calendar?date=%d", i))
+		require.NoError(t, err)
+		return u
+	}
+	assert.True(t, crawler.allowPathVariant(variant(0)))
+	assert.True(t, crawler.allowPathVariant(variant(1)))
+	for i := 2; i < 100; i++ {
+		assert.False(t, crawler.allowPathVariant(variant(i)))
+	}
+	assert.True(t, crawler.allowPathVariant(variant(0)))
+
+	// Only the allowed variants are remembered
+	value, ok := crawler.pathVariants.Load("example.com/calendar")
+	require.True(t, ok)
+	assert.Len(t, value.(*pathVariants).urls, 2)
+}
+
+func TestCrawler_TrapsAfterFilters(t *testing.T) {
+	mockFetcher := fetch.NewMockFetcher()
+
+	// Every page links to the same variants in a shared footer, and the
+	// first variants are rejected by ShouldFollow
+	footer := []string{
+		"/search?q=skip1", "/search?q=skip2",
+		"/search?q=a", "/search?q=b", "/search?q=c",
+	}
+	mockFetcher.AddPage("https://example.com", append([]string{"/1", "/2"}, footer...)...)
+	mockFetcher.AddPage("https://example.com/1", footer...)
+	mockFetcher.AddPage("https://example.com/2", footer...)
+	mockFetcher.AddPage("https://example.com/search?q=a")
+	mockFetcher.AddPage("https://example.com/search?q=b")
+
+	crawler := New(Options{
+		Workers:        1,
+		Fetcher:        mockFetcher,
+		FollowBehavior: FollowSameDomain,
+		Normalize:      web.NormalizeOptions{KeepQuery: true},
+		MaxURLsPerPath: 2,
+		ShouldFollow: func(ctx context.Context, pageURL *url.URL, link string) bool {
+			return !strings.Contains(link, "skip")
+		},
+	})
+	err := crawler.Crawl(context.Background(), []string{"https://example.com"},
+		func(ctx context.Context, result *Result) {
+			assert.NoError(t, result.Error)
+		})
+	require.NoError(t, err)
+
+	// Rejected links do not use up the variants for the path, and the
+	// dropped variant is counted once despite appearing on every page
+	assert.ElementsMatch(t, []string{
+		"https://example.com",
+		"https://example.com/1",
+		"https://example.com/2",
+		"https://example.com/search?q=a",
+		"https://example.com/search?q=b",
+	}, mockFetcher.RequestedURLs())
+	assert.Equal(t, int64(1), crawler.GetStats().GetTraps())
+}
@@ -13,6 +13,7 @@ import (
 type Link struct {
 	URL  string `json:"url"`
 	Text string `json:"text,omitempty"`
+	Rel  string `json:"rel,omitempty"`
 }
 
 // Host returns the host of the link.
@@ -24,6 +25,16 @@ func (l *Link) Host() string {
 	return u.Host
 }
 
+// HasRel returns true if the link's rel attribute contains the given value.
+func (l *Link) HasRel(value string) bool {
+	for _, rel := range strings.Fields(l.Rel) {
+		if strings.EqualFold(rel, value) {
+			return true
+		}
+	}
+	return false
+}
+
 // Meta represents a meta tag on a page.
 type Meta struct {
 	Tag      string `json:"tag"`
@@ -222,7 +233,11 @@ func (d *Document) Links() []*Link {
 		if href == "" {
 			return
 		}
-		links = append(links, &Link{URL: href, Text: s.Text()})
+		links = append(links, &Link{
+			URL:  href,
+			Text: s.Text(),
+			Rel:  strings.TrimSpace(s.AttrOr("rel", "")),
+		})
 	})
 	return links
 }
@@ -20,3 +20,24 @@ func TestDocument_Markdown(t *testing.T) {
 	header := doc.H1()
 	require.Equal(t, "Hello, world!", header)
 }
+
+func TestDocument_Links(t *testing.T) {
+	doc, err := NewDocument(`
+		<html>
+			<body>
+				<a href="/about">About</a>
+				<a href="/ugc" rel="ugc NoFollow">Comment</a>
+				<a>No href</a>
+			</body>
+		</html>
+	`)
+	require.NoError(t, err)
+
+	links := doc.Links()
+	require.Len(t, links, 2)
+	require.Equal(t, "/about", links[0].URL)
+	require.False(t, links[0].HasRel("nofollow"))
+	require.Equal(t, "ugc NoFollow", links[1].Rel)
+	require.True(t, links[1].HasRel("nofollow"))
+	require.True(t, links[1].HasRel("ugc"))
+}
@@ -1,8 +1,11 @@
 package web
 
 import (
+	"net"
 	"net/url"
 	"strings"
+
+	"golang.org/x/net/publicsuffix"
 )
 
 // AreSameHost checks if two URLs have the same host value.
@@ -10,20 +13,57 @@ func AreSameHost(url1, url2 *url.URL) bool {
 	return url1 != nil && url2 != nil && url1.Host == url2.Host
 }
 
+// AreSameHostIgnoringWWW checks if two URLs have the same host, treating a
+// host with a leading "www." as equivalent to its bare form, so that
+// www.example.com matches example.com and www.blog.example.com matches
+// blog.example.com. Hosts are compared case-insensitively.
+func AreSameHostIgnoringWWW(url1, url2 *url.URL) bool {
+	if url1 == nil || url2 == nil {
+		return false
+	}
+	return strings.EqualFold(trimWWW(url1.Host), trimWWW(url2.Host))
+}
+
+// trimWWW removes a leading "www." from the host, in any case, unless what
+// remains is a single label such as "com".
+func trimWWW(host string) string {
+	if len(host) < 4 || !strings.EqualFold(host[:4], "www.") || !strings.Contains(host[4:], ".") {
+		return host
+	}
+	return host[4:]
+}
+
 // AreRelatedHosts checks if two URLs are the same or are related by a common
-// parent domain.
+// registrable domain, such as www.example.co.uk and api.example.co.uk. The
+// public suffix list is used to find the registrable domain, so hosts that
+// only share a public suffix like co.uk or s3.amazonaws.com are not related.
+// IP addresses and hosts without a registrable domain are never related.
 func AreRelatedHosts(url1, url2 *url.URL) bool {
 	if url1 == nil || url2 == nil {
 		return false
 	}
-	parts1 := strings.Split(url1.Host, ".")
-	parts2 := strings.Split(url2.Host, ".")
-
-	// Get the base domain (last two parts)
-	if len(parts1) < 2 || len(parts2) < 2 {
+	domain1, ok := registrableDomain(url1.Hostname())
+	if !ok {
+		return false
+	}
+	domain2, ok := registrableDomain(url2.Hostname())
+	if !ok {
 		return false
 	}
-	base1 := strings.Join(parts1[len(parts1)-2:], ".")
-	base2 := strings.Join(parts2[len(parts2)-2:], ".")
-	return base1 == base2
+	return domain1 == domain2
+}
+
+// registrableDomain returns the host's registrable domain, also known as its
+// eTLD+1. It returns false for IP addresses and for hosts that are a public
+// suffix themselves or that have a single label.
+func registrableDomain(host string) (string, bool) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+		return "", false
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return "", false
+	}
+	return domain, true
 }
@@ -0,0 +1,61 @@
+package fetch
+
+import (
+	"bytes"
+	"mime"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// utf8BOM is the byte order mark some UTF-8 documents begin with.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// decodeBody converts a response body to UTF-8 and returns it along with the
+// name of its original charset. For HTML, the charset is taken from a byte
+// order mark, the Content-Type header, or a <meta> declaration near the start
+// of the document, in that order. A body without a byte order mark or header
+// charset that is already valid UTF-8 is kept as UTF-8, whatever its <meta>
+// declaration says, allowing for an incomplete character at the end of a
+// truncated body. Other content types are only decoded when the header
+// names a charset. Bodies in an unknown charset are returned unchanged with
+// an empty name.
+func decodeBody(body []byte, contentType string, truncated bool) ([]byte, string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	html := contentType == "" || mediaType == "text/html" || mediaType == "application/xhtml+xml"
+	if !html && (err != nil || params["charset"] == "") {
+		return body, ""
+	}
+	encoding, name, certain := charset.DetermineEncoding(body, contentType)
+	if !html {
+		encoding, name = charset.Lookup(params["charset"])
+	}
+	if encoding == nil {
+		return body, ""
+	}
+	if !certain && validUTF8(body, truncated) {
+		name = "utf-8"
+	}
+	if name == "utf-8" {
+		return bytes.TrimPrefix(body, utf8BOM), name
+	}
+	decoded, err := encoding.NewDecoder().Bytes(body)
+	if err != nil {
+		return body, ""
+	}
+	return decoded, name
+}
+
+// validUTF8 reports whether the body is valid UTF-8. A truncated body may end
+// with an incomplete character.
+func validUTF8(body []byte, truncated bool) bool {
+	for i := len(body) - 1; truncated && i >= 0 && i >= len(body)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(body[i]) {
+			if !utf8.FullRune(body[i:]) {
+				body = body[:i]
+			}
+			break
+		}
+	}
+	return utf8.Valid(body)
+}
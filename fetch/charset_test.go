@@ -0,0 +1,82 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test bodies in legacy encodings. "café" in ISO-8859-1 and "日本語" in
+// Shift-JIS.
+var (
+	latin1Cafe    = "caf\xe9"
+	shiftJISNihon = "\x93\xfa\x96\x7b\x8c\xea"
+)
+
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		truncated   bool
+		expected    string
+		charset     string
+	}{
+		{"utf-8 header", "<p>café</p>", "text/html; charset=utf-8", false, "<p>café</p>", "utf-8"},
+		{"no charset", "<p>café</p>", "text/html", false, "<p>café</p>", "utf-8"},
+		{"no content type", "<p>hello</p>", "", false, "<p>hello</p>", "utf-8"},
+		{"latin-1 header", "<p>" + latin1Cafe + "</p>", "text/html; charset=ISO-8859-1", false, "<p>café</p>", "windows-1252"},
+		{"shift-jis header", "<p>" + shiftJISNihon + "</p>", "text/html; charset=Shift_JIS", false, "<p>日本語</p>", "shift_jis"},
+		{"meta charset", `<html><head><meta charset="shift_jis"></head><p>` + shiftJISNihon + "</p>", "text/html", false,
+			`<html><head><meta charset="shift_jis"></head><p>日本語</p>`, "shift_jis"},
+		{"meta http-equiv", `<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1"><p>` + latin1Cafe, "text/html", false,
+			`<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1"><p>café`, "windows-1252"},
+		{"undeclared legacy bytes", "<p>" + latin1Cafe + "</p>", "text/html", false, "<p>café</p>", "windows-1252"},
+		{"utf-8 bom", "\xef\xbb\xbf<p>café</p>", "text/html", false, "<p>café</p>", "utf-8"},
+		{"truncated utf-8", "<p>caf\xc3", "text/html", true, "<p>caf\xc3", "utf-8"},
+		{"header overrides meta", `<meta charset="shift_jis"><p>café</p>`, "text/html; charset=utf-8", false,
+			`<meta charset="shift_jis"><p>café</p>`, "utf-8"},
+		{"other type with charset", latin1Cafe, "text/plain; charset=iso-8859-1", false, "café", "windows-1252"},
+		{"other type without charset", latin1Cafe, "application/octet-stream", false, latin1Cafe, ""},
+		{"unknown charset", latin1Cafe, "text/plain; charset=klingon", false, latin1Cafe, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, charset := decodeBody([]byte(tt.body), tt.contentType, tt.truncated)
+			require.Equal(t, tt.expected, string(body))
+			require.Equal(t, tt.charset, charset)
+		})
+	}
+}
+
+func TestHTTPFetcher_Charset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latin1":
+			w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+			w.Write([]byte(`<html><head><title>Caf` + "\xe9" + `</title></head><body><a href="/caf` + "\xe9" + `">Menu</a></body></html>`))
+		case "/sjis":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><meta charset="Shift_JIS"><title>` + shiftJISNihon + `</title></head><body><p>` + shiftJISNihon + `</p></body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	fetcher := NewHTTPFetcher(HTTPFetcherOptions{})
+	response, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL + "/latin1"})
+	require.NoError(t, err)
+	require.Equal(t, "windows-1252", response.Charset)
+	require.Contains(t, response.HTML, "<title>Café</title>")
+	require.Equal(t, "Café", response.Metadata.Title)
+	require.Len(t, response.Links, 1)
+	require.Equal(t, "/café", response.Links[0].URL)
+
+	response, err = fetcher.Fetch(context.Background(), &Request{URL: server.URL + "/sjis"})
+	require.NoError(t, err)
+	require.Equal(t, "shift_jis", response.Charset)
+	require.Contains(t, response.HTML, "<p>日本語</p>")
+	require.Equal(t, "日本語", response.Metadata.Title)
+}
@@ -0,0 +1,310 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// ChromeFetcherName is the name the headless browser fetcher is registered
+// under in a Fetchers set.
+const ChromeFetcherName = "chromedp"
+
+// DefaultNetworkIdleTime is how long the network must be quiet before a page
+// is considered loaded with WaitNetworkIdle.
+const DefaultNetworkIdleTime = 500 * time.Millisecond
+
+// WaitStrategy determines when a page rendered by the ChromeFetcher is
+// considered ready to be captured.
+type WaitStrategy string
+
+const (
+	// WaitLoad waits for the page load event.
+	WaitLoad WaitStrategy = "load"
+
+	// WaitNetworkIdle waits for the load event and then for there to be no
+	// network requests in flight for the NetworkIdleTime.
+	WaitNetworkIdle WaitStrategy = "network-idle"
+
+	// WaitSelector waits for the load event and then for an element matching
+	// the WaitSelector CSS selector to be present.
+	WaitSelector WaitStrategy = "selector"
+)
+
+// ChromeFetcherOptions defines the options for the headless browser fetcher.
+type ChromeFetcherOptions struct {
+	// RemoteURL is the DevTools URL of an already running browser, for
+	// example "ws://localhost:9222". When empty, a local headless Chrome is
+	// started on first use.
+	RemoteURL string
+
+	// ExecPath is the path of the Chrome binary to start. Defaults to
+	// searching the usual install locations. Ignored with RemoteURL.
+	ExecPath string
+
+	Timeout     time.Duration
+	Headers     map[string]string
+	MaxBodySize int64
+
+	// Wait defaults to WaitLoad.
+	Wait WaitStrategy
+
+	// WaitSelector is the CSS selector waited for with the WaitSelector
+	// strategy.
+	WaitSelector string
+
+	// NetworkIdleTime defaults to DefaultNetworkIdleTime.
+	NetworkIdleTime time.Duration
+}
+
+// ChromeFetcher implements the Fetcher interface by rendering pages in a
+// headless Chrome browser, so that content and links created by JavaScript
+// are returned. Each request is loaded in a new tab of a shared browser.
+// Only GET requests are supported. Call Close to shut the browser down.
+type ChromeFetcher struct {
+	timeout         time.Duration
+	headers         map[string]string
+	maxBodySize     int64
+	wait            WaitStrategy
+	waitSelector    string
+	networkIdleTime time.Duration
+	startMutex      sync.Mutex
+	browserCtx      context.Context
+	cancel          context.CancelFunc
+}
+
+// NewChromeFetcher creates a new headless browser fetcher.
+func NewChromeFetcher(options ChromeFetcherOptions) (*ChromeFetcher, error) {
+	if options.Timeout == 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.Headers == nil {
+		options.Headers = DefaultHeaders
+	}
+	if options.MaxBodySize == 0 {
+		options.MaxBodySize = DefaultMaxBodySize
+	}
+	if options.Wait == "" {
+		options.Wait = WaitLoad
+	}
+	if options.NetworkIdleTime == 0 {
+		options.NetworkIdleTime = DefaultNetworkIdleTime
+	}
+	switch options.Wait {
+	case WaitLoad, WaitNetworkIdle:
+	case WaitSelector:
+		if options.WaitSelector == "" {
+			return nil, errors.New("wait selector is required")
+		}
+	default:
+		return nil, fmt.Errorf("invalid wait strategy: %s", options.Wait)
+	}
+
+	var allocCtx context.Context
+	var cancelAlloc context.CancelFunc
+	if options.RemoteURL != "" {
+		allocCtx, cancelAlloc = chromedp.NewRemoteAllocator(context.Background(), options.RemoteURL)
+	} else {
+		allocOptions := chromedp.DefaultExecAllocatorOptions[:]
+		if options.ExecPath != "" {
+			allocOptions = append(allocOptions, chromedp.ExecPath(options.ExecPath))
+		}
+		allocCtx, cancelAlloc = chromedp.NewExecAllocator(context.Background(), allocOptions...)
+	}
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+
+	return &ChromeFetcher{
+		timeout:         options.Timeout,
+		headers:         options.Headers,
+		maxBodySize:     options.MaxBodySize,
+		wait:            options.Wait,
+		waitSelector:    options.WaitSelector,
+		networkIdleTime: options.NetworkIdleTime,
+		browserCtx:      browserCtx,
+		cancel: func() {
+			cancelBrowser()
+			cancelAlloc()
+		},
+	}, nil
+}
+
+// Close shuts down the browser, or disconnects from a remote browser.
+func (f *ChromeFetcher) Close() error {
+	f.cancel()
+	return nil
+}
+
+// Fetch implements the Fetcher interface by loading the page in a new tab.
+func (f *ChromeFetcher) Fetch(ctx context.Context, req *Request) (*Response, error) {
+	if req.Method != "" && req.Method != http.MethodGet {
+		return nil, fmt.Errorf("unsupported method for %s fetcher: %s", ChromeFetcherName, req.Method)
+	}
+
+	// Start the browser if it is not running yet
+	f.startMutex.Lock()
+	err := chromedp.Run(f.browserCtx)
+	f.startMutex.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start browser: %w", err)
+	}
+
+	// Tabs belong to the browser context, so cancel them with the request
+	tabCtx, cancel := chromedp.NewContext(f.browserCtx)
+	defer cancel()
+	tabCtx, cancelTimeout := context.WithTimeout(tabCtx, f.timeout)
+	defer cancelTimeout()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	headers := network.Headers{}
+	for key, value := range f.headers {
+		headers[key] = value
+	}
+	for key, value := range req.Headers {
+		headers[key] = value
+	}
+
+	tracker := newPageTracker()
+	chromedp.ListenTarget(tabCtx, tracker.handle)
+
+	var html, finalURL string
+	actions := []chromedp.Action{
+		network.SetExtraHTTPHeaders(headers),
+		chromedp.Navigate(req.URL),
+	}
+	switch f.wait {
+	case WaitNetworkIdle:
+		actions = append(actions, tracker.waitIdle(f.networkIdleTime))
+	case WaitSelector:
+		actions = append(actions, chromedp.WaitReady(f.waitSelector, chromedp.ByQuery))
+	}
+	if req.WaitFor > 0 {
+		actions = append(actions, chromedp.Sleep(time.Duration(req.WaitFor)*time.Millisecond))
+	}
+	actions = append(actions,
+		chromedp.Location(&finalURL),
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+	)
+	if err := chromedp.Run(tabCtx, actions...); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	// Check if the page is too large
+	maxBodySize := f.maxBodySize
+	if req.MaxBodySize > 0 {
+		maxBodySize = req.MaxBodySize
+	}
+	truncated := int64(len(html)) > maxBodySize
+	if truncated {
+		if !req.TruncateBody {
+			return nil, fmt.Errorf("%w: exceeds limit of %d bytes", ErrResponseTooLarge, maxBodySize)
+		}
+		html = html[:maxBodySize]
+	}
+
+	// Apply processing options
+	response, err := ProcessRequest(req, html)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set other response fields from the document response
+	statusCode, responseHeaders, contentType := tracker.document()
+	response.URL = req.URL
+	response.FinalURL = finalURL
+	if statusCode != 0 {
+		response.StatusCode = statusCode
+	}
+	response.Headers = responseHeaders
+	response.ContentType = contentType
+	response.Truncated = truncated
+	return response, nil
+}
+
+// pageTracker records the document response and the network requests in
+// flight for a tab.
+type pageTracker struct {
+	mutex        sync.Mutex
+	statusCode   int
+	headers      map[string]string
+	contentType  string
+	inFlight     map[network.RequestID]struct{}
+	lastActivity time.Time
+}
+
+func newPageTracker() *pageTracker {
+	return &pageTracker{
+		headers:      map[string]string{},
+		inFlight:     map[network.RequestID]struct{}{},
+		lastActivity: time.Now(),
+	}
+}
+
+// handle processes DevTools events for the tab.
+func (t *pageTracker) handle(ev any) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	switch ev := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		t.inFlight[ev.RequestID] = struct{}{}
+		t.lastActivity = time.Now()
+	case *network.EventLoadingFinished:
+		delete(t.inFlight, ev.RequestID)
+		t.lastActivity = time.Now()
+	case *network.EventLoadingFailed:
+		delete(t.inFlight, ev.RequestID)
+		t.lastActivity = time.Now()
+	case *network.EventResponseReceived:
+		// The navigation request of the page has the loader's ID. The first
+		// such document response is the page itself rather than a frame.
+		if t.statusCode != 0 || ev.Type != network.ResourceTypeDocument ||
+			string(ev.RequestID) != string(ev.LoaderID) {
+			return
+		}
+		t.statusCode = int(ev.Response.Status)
+		t.contentType = ev.Response.MimeType
+		for name, value := range ev.Response.Headers {
+			t.headers[http.CanonicalHeaderKey(name)] = fmt.Sprint(value)
+		}
+	}
+}
+
+// document returns the status code, headers, and content type of the page.
+func (t *pageTracker) document() (int, map[string]string, string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.statusCode, t.headers, t.contentType
+}
+
+// waitIdle returns an action that waits until no requests have been in
+// flight for the given duration.
+func (t *pageTracker) waitIdle(idle time.Duration) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			t.mutex.Lock()
+			done := len(t.inFlight) == 0 && time.Since(t.lastActivity) >= idle
+			t.mutex.Unlock()
+			if done {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestChromeFetcher returns a headless browser fetcher for integration
+// tests. The tests are skipped unless CHROME_PATH or CHROME_URL is set.
+func newTestChromeFetcher(t *testing.T, options ChromeFetcherOptions) *ChromeFetcher {
+	options.ExecPath = os.Getenv("CHROME_PATH")
+	options.RemoteURL = os.Getenv("CHROME_URL")
+	if options.ExecPath == "" && options.RemoteURL == "" {
+		t.Skip("CHROME_PATH and CHROME_URL not set")
+	}
+	fetcher, err := NewChromeFetcher(options)
+	require.NoError(t, err)
+	t.Cleanup(func() { fetcher.Close() })
+	return fetcher
+}
+
+func TestNewChromeFetcher(t *testing.T) {
+	_, err := NewChromeFetcher(ChromeFetcherOptions{Wait: WaitSelector})
+	require.Error(t, err)
+	_, err = NewChromeFetcher(ChromeFetcherOptions{Wait: "never"})
+	require.Error(t, err)
+
+	fetcher, err := NewChromeFetcher(ChromeFetcherOptions{Wait: WaitSelector, WaitSelector: "#content"})
+	require.NoError(t, err)
+	require.NoError(t, fetcher.Close())
+}
+
+func TestChromeFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><script>
+			setTimeout(function() {
+				var a = document.createElement("a");
+				a.id = "rendered";
+				a.href = "/rendered";
+				a.textContent = "rendered";
+				document.body.appendChild(a);
+			}, 100);
+		</script></body></html>`))
+	}))
+	defer server.Close()
+
+	fetcher := newTestChromeFetcher(t, ChromeFetcherOptions{
+		Wait:         WaitSelector,
+		WaitSelector: "#rendered",
+	})
+	response, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, "text/html", response.ContentType)
+	require.Contains(t, response.HTML, "rendered")
+	require.Len(t, response.Links, 1)
+}
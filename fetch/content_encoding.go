@@ -0,0 +1,106 @@
+package fetch
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding is sent with requests that do not set their own
+// Accept-Encoding header. The HTTP transport only decompresses gzip, and only
+// when it adds the header itself, so responses are decoded by decodeContent.
+const acceptEncoding = "gzip, deflate, br"
+
+// ErrUnsupportedEncoding is returned when a response uses a Content-Encoding
+// the fetcher cannot decode.
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// decodeContent returns a reader that decodes the body according to the
+// Content-Encoding header. When several encodings are listed, they are
+// removed in the reverse of the order they were applied. An empty body is
+// returned as is. Closing the reader releases the decoders, but not the body.
+func decodeContent(body io.Reader, contentEncoding string) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(body)
+	if _, err := buffered.Peek(1); err == io.EOF {
+		return io.NopCloser(buffered), nil
+	}
+	decoded := &decodedBody{Reader: buffered}
+	encodings := strings.Split(contentEncoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var decoder io.Reader
+		var err error
+		switch encoding := strings.ToLower(strings.TrimSpace(encodings[i])); encoding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			decoder, err = gzip.NewReader(decoded.Reader)
+		case "deflate":
+			decoder, err = newDeflateReader(decoded.Reader)
+		case "br":
+			decoder = brotli.NewReader(decoded.Reader)
+		default:
+			err = fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+		}
+		if err != nil {
+			decoded.Close()
+			return nil, err
+		}
+		if closer, ok := decoder.(io.Closer); ok {
+			decoded.closers = append(decoded.closers, closer)
+		}
+		decoded.Reader = decoder
+	}
+	return decoded, nil
+}
+
+// decodedBody reads a decoded body and closes its decoders.
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes the decoders.
+func (d *decodedBody) Close() error {
+	var errs []error
+	for _, closer := range d.closers {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// hasBody reports whether the response may carry a body. Responses to HEAD
+// requests, 204 and 304 responses, and responses with a zero Content-Length
+// have none, even when they repeat the Content-Encoding of the resource.
+func hasBody(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+	return resp.ContentLength != 0
+}
+
+// newDeflateReader returns a reader for a deflate encoded body. The encoding
+// is meant to be zlib wrapped, but some servers send raw deflate data, which
+// is detected from the missing zlib header.
+func newDeflateReader(body io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(body)
+	header, err := buffered.Peek(2)
+	if err != nil && len(header) < 2 {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
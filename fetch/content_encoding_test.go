@@ -0,0 +1,165 @@
+package fetch
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/require"
+)
+
+// compressBody encodes the body with a single content encoding.
+func compressBody(t *testing.T, encoding string, body []byte) []byte {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "deflate":
+		writer = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		var err error
+		writer, err = flate.NewWriter(&buf, flate.DefaultCompression)
+		require.NoError(t, err)
+	case "br":
+		writer = brotli.NewWriter(&buf)
+	default:
+		return body
+	}
+	_, err := writer.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestHTTPFetcher_ContentEncoding(t *testing.T) {
+	page := `<html><head><title>Compressed</title></head><body>` +
+		strings.Repeat(`<p>Hello</p>`, 100) + `<a href="/next">Next</a></body></html>`
+
+	tests := []struct {
+		name           string
+		encodings      []string // in the order they are applied
+		header         string
+		acceptEncoding string
+		err            error
+	}{
+		{"gzip", []string{"gzip"}, "gzip", "", nil},
+		{"x-gzip", []string{"gzip"}, "x-gzip", "", nil},
+		{"deflate", []string{"deflate"}, "deflate", "", nil},
+		{"raw deflate", []string{"raw-deflate"}, "deflate", "", nil},
+		{"brotli", []string{"br"}, "br", "", nil},
+		{"multiple", []string{"gzip", "br"}, "gzip, br", "", nil},
+		{"identity", nil, "", "", nil},
+		{"custom accept-encoding", []string{"br"}, "br", "br", nil},
+		{"unsupported", nil, "zstd", "", ErrUnsupportedEncoding},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var acceptEncoding string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				acceptEncoding = r.Header.Get("Accept-Encoding")
+				body := []byte(page)
+				for _, encoding := range tt.encodings {
+					body = compressBody(t, encoding, body)
+				}
+				w.Header().Set("Content-Type", "text/html")
+				if tt.header != "" {
+					w.Header().Set("Content-Encoding", tt.header)
+				}
+				w.Write(body)
+			}))
+			defer server.Close()
+
+			fetcher := NewHTTPFetcher(HTTPFetcherOptions{})
+			req := &Request{URL: server.URL}
+			if tt.acceptEncoding != "" {
+				req.Headers = map[string]string{"Accept-Encoding": tt.acceptEncoding}
+			}
+			response, err := fetcher.Fetch(context.Background(), req)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.acceptEncoding != "" {
+				require.Equal(t, tt.acceptEncoding, acceptEncoding)
+			} else {
+				require.Equal(t, "gzip, deflate, br", acceptEncoding)
+			}
+			require.Equal(t, page, response.HTML)
+			require.Equal(t, "Compressed", response.Metadata.Title)
+			require.Len(t, response.Links, 1)
+			require.Empty(t, response.GetHeader("Content-Encoding"))
+		})
+	}
+}
+
+func TestHTTPFetcher_ContentEncodingMaxBodySize(t *testing.T) {
+	// A small compressed body that expands past the limit
+	page := strings.Repeat("<p>Hello</p>", 10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressBody(t, "gzip", []byte(page)))
+	}))
+	defer server.Close()
+
+	fetcher := NewHTTPFetcher(HTTPFetcherOptions{MaxBodySize: 1024})
+	_, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL})
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+
+	response, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL, TruncateBody: true})
+	require.NoError(t, err)
+	require.True(t, response.Truncated)
+	require.Len(t, response.HTML, 1024)
+}
+
+func TestHTTPFetcher_ContentEncodingNoBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		status int
+		flush  bool // send the headers before the empty body, so its length is unknown
+	}{
+		{"HEAD", http.MethodHead, http.StatusOK, false},
+		{"not modified", http.MethodGet, http.StatusNotModified, false},
+		{"no content", http.MethodGet, http.StatusNoContent, false},
+		{"empty body", http.MethodGet, http.StatusOK, false},
+		{"empty chunked body", http.MethodGet, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.Header().Set("Content-Encoding", "gzip")
+				if r.Method == http.MethodHead {
+					w.Header().Set("Content-Length", "5000")
+				}
+				w.WriteHeader(tt.status)
+				if tt.flush {
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer server.Close()
+
+			fetcher := NewHTTPFetcher(HTTPFetcherOptions{})
+			response, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL, Method: tt.method})
+			require.NoError(t, err)
+			require.Equal(t, tt.status, response.StatusCode)
+			require.Empty(t, response.HTML)
+			if tt.method == http.MethodHead {
+				// The headers still describe the resource
+				require.Equal(t, "gzip", response.GetHeader("Content-Encoding"))
+				require.Equal(t, "5000", response.GetHeader("Content-Length"))
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package fetch
+
+import (
+	"mime"
+	"strings"
+)
+
+// DefaultAllowedContentTypes lists the content types treated as pages when no
+// other list is configured.
+var DefaultAllowedContentTypes = []string{"text/html", "application/xhtml+xml"}
+
+// ContentTypeAllowed reports whether a Content-Type header value matches one
+// of the allowed media types. Parameters such as charset are ignored, and an
+// allowed type of the form "text/*" matches any subtype. A missing content
+// type is allowed, since servers commonly omit it for HTML.
+func ContentTypeAllowed(contentType string, allowed []string) bool {
+	if strings.TrimSpace(contentType) == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, candidate := range allowed {
+		candidate = strings.ToLower(strings.TrimSpace(candidate))
+		if prefix, ok := strings.CutSuffix(candidate, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == candidate {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package fetch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentTypeAllowed(t *testing.T) {
+	tests := []struct {
+		contentType string
+		allowed     []string
+		expected    bool
+	}{
+		{contentType: "text/html", allowed: DefaultAllowedContentTypes, expected: true},
+		{contentType: "text/html; charset=utf-8", allowed: DefaultAllowedContentTypes, expected: true},
+		{contentType: "TEXT/HTML", allowed: DefaultAllowedContentTypes, expected: true},
+		{contentType: "application/xhtml+xml", allowed: DefaultAllowedContentTypes, expected: true},
+		{contentType: "", allowed: DefaultAllowedContentTypes, expected: true},
+		{contentType: "application/pdf", allowed: DefaultAllowedContentTypes, expected: false},
+		{contentType: "image/png", allowed: DefaultAllowedContentTypes, expected: false},
+		{contentType: "text/plain", allowed: []string{"text/*"}, expected: true},
+		{contentType: "application/json", allowed: []string{"text/*"}, expected: false},
+		{contentType: "not a type", allowed: DefaultAllowedContentTypes, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			require.Equal(t, tt.expected, ContentTypeAllowed(tt.contentType, tt.allowed))
+		})
+	}
+}
@@ -2,6 +2,9 @@ package fetch
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/myzie/web"
 )
@@ -28,20 +31,53 @@ type Request struct {
 	Formats         []string          `json:"formats,omitempty"`
 	Actions         []Action          `json:"actions,omitempty"`
 	Headers         map[string]string `json:"headers,omitempty"`
+	Method          string            `json:"method,omitempty"`        // defaults to GET
+	Body            []byte            `json:"body,omitempty"`          // sent as the request body
+	ContentType     string            `json:"content_type,omitempty"`  // Content-Type of the body
+	MaxBodySize     int64             `json:"max_body_size,omitempty"` // overrides the fetcher's limit
+	TruncateBody    bool              `json:"truncate_body,omitempty"` // truncate rather than fail
+	ETag            string            `json:"etag,omitempty"`          // sent as If-None-Match
+	LastModified    string            `json:"last_modified,omitempty"` // sent as If-Modified-Since
 }
 
 // Response defines the JSON payload for fetch responses.
 type Response struct {
-	URL        string            `json:"url"`
-	StatusCode int               `json:"status_code"`
-	Headers    map[string]string `json:"headers"`
-	HTML       string            `json:"html,omitempty"`
-	Markdown   string            `json:"markdown,omitempty"`
-	Screenshot string            `json:"screenshot,omitempty"`
-	PDF        string            `json:"pdf,omitempty"`
-	Error      string            `json:"error,omitempty"`
-	Metadata   Metadata          `json:"metadata,omitempty"`
-	Links      []*Link           `json:"links,omitempty"`
+	URL         string            `json:"url"`
+	FinalURL    string            `json:"final_url,omitempty"` // after redirects
+	Redirects   []string          `json:"redirects,omitempty"` // URLs that redirected, in order
+	StatusCode  int               `json:"status_code"`
+	Headers     map[string]string `json:"headers"`
+	ContentType string            `json:"content_type,omitempty"`
+	Charset     string            `json:"charset,omitempty"` // of the body before decoding to UTF-8
+	HTML        string            `json:"html,omitempty"`
+	Markdown    string            `json:"markdown,omitempty"`
+	Screenshot  string            `json:"screenshot,omitempty"`
+	PDF         string            `json:"pdf,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Metadata    Metadata          `json:"metadata,omitempty"`
+	Links       []*Link           `json:"links,omitempty"`
+	Truncated   bool              `json:"truncated,omitempty"`
+}
+
+// ErrResponseTooLarge is returned when a response body exceeds the maximum
+// size and truncation was not requested.
+var ErrResponseTooLarge = errors.New("response too large")
+
+// ErrTooManyRedirects is returned when a request is redirected more times than
+// the fetcher allows.
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// GetHeader returns the value of the named response header, ignoring case.
+func (r *Response) GetHeader(name string) string {
+	if value, ok := r.Headers[name]; ok {
+		return value
+	}
+	for key, value := range r.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
 }
 
 // Fetcher defines an interface for fetching pages.
@@ -50,3 +86,18 @@ type Fetcher interface {
 	// Fetch a webpage and return the response.
 	Fetch(ctx context.Context, request *Request) (*Response, error)
 }
+
+// Fetchers is a set of named fetchers. It implements the Fetcher interface by
+// passing each request to the fetcher named by the request's Fetcher field,
+// for example "http" or ChromeFetcherName.
+type Fetchers map[string]Fetcher
+
+// Fetch implements the Fetcher interface using the fetcher named by the
+// request. An error is returned if there is no fetcher with that name.
+func (f Fetchers) Fetch(ctx context.Context, request *Request) (*Response, error) {
+	fetcher, ok := f[request.Fetcher]
+	if !ok {
+		return nil, fmt.Errorf("unknown fetcher: %q", request.Fetcher)
+	}
+	return fetcher.Fetch(ctx, request)
+}
@@ -1,17 +1,23 @@
 package fetch
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
+	"net/url"
+	"slices"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	DefaultMaxBodySize = 10 * 1024 * 1024 // 10 MB
-	DefaultTimeout     = 30 * time.Second
+	DefaultMaxBodySize         = 10 * 1024 * 1024 // 10 MB
+	DefaultTimeout             = 30 * time.Second
+	DefaultMaxRedirects        = 10
+	DefaultMaxIdleConnsPerHost = 32
+	DefaultIdleConnTimeout     = 90 * time.Second
 )
 
 var (
@@ -25,14 +31,89 @@ type HTTPFetcherOptions struct {
 	Headers     map[string]string
 	Client      *http.Client
 	MaxBodySize int64
+
+	// CookieJar stores cookies set by responses and sends them on later
+	// requests, scoped by host as with http.Client. To crawl behind a login,
+	// create a jar with net/http/cookiejar, perform the login POST with an
+	// http.Client using that jar, then pass the jar here. Cookies may also
+	// be added with HTTPFetcher.SetCookies. The Client is copied rather than
+	// modified when a jar is given.
+	CookieJar http.CookieJar
+
+	// AllowedContentTypes lists the content types whose bodies are
+	// downloaded. Other successful responses are returned with their status
+	// and headers but no body. Defaults to DefaultAllowedContentTypes.
+	AllowedContentTypes []string
+
+	// ProxyPool routes each request through one of a pool of proxies.
+	// Proxies that fail to connect are marked unhealthy. The Client is
+	// copied with a cloned transport rather than modified when a pool is
+	// given, and the Client's transport must be an *http.Transport or nil.
+	ProxyPool *ProxyPool
+
+	// MaxRedirects is the number of redirects followed before a request
+	// fails with ErrTooManyRedirects. A negative value disables following
+	// redirects, so the redirect response itself is returned. Defaults to
+	// DefaultMaxRedirects, unless the Client has its own CheckRedirect
+	// policy, which is then left in place. The Client is copied rather than
+	// modified when the policy is set.
+	MaxRedirects int
+
+	// MaxIdleConnsPerHost is the number of idle connections kept open to each
+	// host. Defaults to DefaultMaxIdleConnsPerHost, well above the net/http
+	// default of 2, which suits crawls that make many requests to one host.
+	// Concurrent requests to a host are bounded by the crawler's Workers, or
+	// by AdaptiveMaxConcurrency when adaptive concurrency is enabled, and
+	// this should be at least that bound so that every request can reuse a
+	// connection rather than opening a new one.
+	//
+	// This and the following transport settings apply to the Client's
+	// transport when it is an *http.Transport or nil. The Client is copied
+	// with a cloned transport rather than modified. A Client with another
+	// kind of transport is used as it is.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost limits the connections to each host, including those
+	// in use. Requests beyond the limit wait for a connection, so it acts as
+	// a further per-host concurrency limit. Zero means no limit.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is how long an idle connection is kept open. Defaults
+	// to DefaultIdleConnTimeout.
+	IdleConnTimeout time.Duration
+
+	// DisableHTTP2 turns off HTTP/2, which is otherwise attempted for HTTPS
+	// requests, as with the net/http default transport. With HTTP/2,
+	// concurrent requests to a host share a single connection.
+	DisableHTTP2 bool
+
+	// DisableKeepAlives closes each connection after one request.
+	DisableKeepAlives bool
+
+	// UserAgents is a pool of User-Agent strings used in turn, one per
+	// request. A single entry sets the User-Agent for every request. These
+	// take precedence over a User-Agent in Headers, while a User-Agent in a
+	// request's own Headers overrides the pool for that request.
+	//
+	// Rotating user agents disguises a crawler as many different clients.
+	// Site operators rely on the User-Agent to identify crawlers, apply their
+	// robots.txt rules and contact the people running them, so use this with
+	// care: honor robots.txt and the site's terms, keep request rates low,
+	// and prefer a truthful User-Agent that identifies the crawler wherever
+	// blocking is not a concern.
+	UserAgents []string
 }
 
 // HTTPFetcher implements the Fetcher interface using standard HTTP client.
 type HTTPFetcher struct {
-	timeout     time.Duration
-	headers     map[string]string
-	client      *http.Client
-	maxBodySize int64
+	timeout             time.Duration
+	headers             map[string]string
+	client              *http.Client
+	maxBodySize         int64
+	proxyPool           *ProxyPool
+	allowedContentTypes []string
+	userAgents          []string
+	nextUserAgent       atomic.Uint64
 }
 
 // NewHTTPFetcher creates a new HTTP fetcher
@@ -49,20 +130,166 @@ func NewHTTPFetcher(options HTTPFetcherOptions) *HTTPFetcher {
 	if options.MaxBodySize == 0 {
 		options.MaxBodySize = DefaultMaxBodySize
 	}
+	if options.AllowedContentTypes == nil {
+		options.AllowedContentTypes = DefaultAllowedContentTypes
+	}
+	if options.CookieJar != nil {
+		client := *options.Client
+		client.Jar = options.CookieJar
+		options.Client = &client
+	}
+	if options.MaxRedirects != 0 || options.Client.CheckRedirect == nil {
+		client := *options.Client
+		client.CheckRedirect = checkRedirect(options.MaxRedirects)
+		options.Client = &client
+	}
+	if transport := options.transport(); transport != nil {
+		client := *options.Client
+		client.Transport = transport
+		options.Client = &client
+	}
 	return &HTTPFetcher{
-		timeout:     options.Timeout,
-		headers:     options.Headers,
-		client:      options.Client,
-		maxBodySize: options.MaxBodySize,
+		timeout:             options.Timeout,
+		headers:             options.Headers,
+		client:              options.Client,
+		maxBodySize:         options.MaxBodySize,
+		proxyPool:           options.ProxyPool,
+		allowedContentTypes: options.AllowedContentTypes,
+		userAgents:          slices.Clone(options.UserAgents),
+	}
+}
+
+// userAgent returns the next User-Agent from the pool, or an empty string if
+// the pool is empty.
+func (f *HTTPFetcher) userAgent() string {
+	if len(f.userAgents) == 0 {
+		return ""
+	}
+	i := f.nextUserAgent.Add(1) - 1
+	return f.userAgents[i%uint64(len(f.userAgents))]
+}
+
+// transport returns a copy of the Client's transport with the transport
+// settings and proxy pool applied. It returns nil if the Client's transport
+// is not an *http.Transport, unless a proxy pool requires the default
+// transport to be used instead.
+func (options HTTPFetcherOptions) transport() *http.Transport {
+	var transport *http.Transport
+	switch t := options.Client.Transport.(type) {
+	case *http.Transport:
+		transport = t.Clone()
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	default:
+		if options.ProxyPool == nil {
+			return nil
+		}
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	if options.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+	} else if transport.MaxIdleConnsPerHost == 0 {
+		transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if options.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = options.MaxConnsPerHost
+	}
+	if options.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = options.IdleConnTimeout
+	} else if transport.IdleConnTimeout == 0 {
+		transport.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if options.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP1(true)
+		// A TLS config that offers h2 would still negotiate it
+		if transport.TLSClientConfig != nil {
+			transport.TLSClientConfig = transport.TLSClientConfig.Clone()
+			transport.TLSClientConfig.NextProtos = slices.DeleteFunc(
+				slices.Clone(transport.TLSClientConfig.NextProtos),
+				func(proto string) bool { return proto == "h2" })
+		}
+	}
+	if options.DisableKeepAlives {
+		transport.DisableKeepAlives = true
+	}
+	if options.ProxyPool != nil {
+		transport.Proxy = options.ProxyPool.proxy
+	}
+	return transport
+}
+
+// checkRedirect returns a redirect policy that follows up to maxRedirects
+// redirects, or DefaultMaxRedirects if it is zero.
+func checkRedirect(maxRedirects int) func(*http.Request, []*http.Request) error {
+	if maxRedirects == 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if maxRedirects < 0 {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > maxRedirects {
+			return fmt.Errorf("%w: stopped after %d redirects", ErrTooManyRedirects, maxRedirects)
+		}
+		return nil
+	}
+}
+
+// redirectChain returns the URLs of the requests that were redirected before
+// the final request of the response, in order.
+func redirectChain(resp *http.Response) []string {
+	var chain []string
+	for r := resp.Request.Response; r != nil; r = r.Request.Response {
+		chain = append(chain, r.Request.URL.String())
+	}
+	slices.Reverse(chain)
+	return chain
+}
+
+// SetCookies adds cookies for the URL to the fetcher's cookie jar, for example
+// to seed an authenticated session before a crawl. It has no effect if the
+// fetcher has no cookie jar.
+func (f *HTTPFetcher) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if f.client.Jar != nil {
+		f.client.Jar.SetCookies(u, cookies)
 	}
 }
 
 // Fetch implements the Fetcher interface for HTTP requests
 func (f *HTTPFetcher) Fetch(ctx context.Context, req *Request) (*Response, error) {
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
+	var proxy **url.URL
+	if f.proxyPool != nil {
+		ctx, proxy = withProxyChoice(ctx)
+	}
+
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var reqBody io.Reader
+	if len(req.Body) > 0 {
+		reqBody = bytes.NewReader(req.Body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, req.URL, reqBody)
 	if err != nil {
 		return nil, err
 	}
+	if req.ContentType != "" {
+		httpReq.Header.Set("Content-Type", req.ContentType)
+	}
+	if req.ETag != "" {
+		httpReq.Header.Set("If-None-Match", req.ETag)
+	}
+	if req.LastModified != "" {
+		httpReq.Header.Set("If-Modified-Since", req.LastModified)
+	}
+
+	// Rotate user agents, ahead of the default headers
+	if userAgent := f.userAgent(); userAgent != "" {
+		httpReq.Header.Set("User-Agent", userAgent)
+	}
 
 	// Apply default headers
 	for key, value := range f.headers {
@@ -76,38 +303,84 @@ func (f *HTTPFetcher) Fetch(ctx context.Context, req *Request) (*Response, error
 		httpReq.Header.Set(key, value)
 	}
 
+	// Ask for compressed responses, which are decoded below
+	if httpReq.Header.Get("Accept-Encoding") == "" {
+		httpReq.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
 	resp, err := f.client.Do(httpReq)
 	if err != nil {
+		if proxy != nil && *proxy != nil && isProxyFailure(err) {
+			f.proxyPool.MarkUnhealthy(*proxy)
+		}
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	// Confirm the content type indicates HTML
+	// Convert response headers to map[string]string
+	headers := make(map[string]string)
+	for name, values := range resp.Header {
+		if len(values) > 0 {
+			headers[name] = values[0] // Use first value if multiple
+		}
+	}
+
+	// Skip downloading the body unless the content type is allowed. Error
+	// responses are read regardless.
 	contentType := resp.Header.Get("Content-Type")
-	if !strings.Contains(contentType, "text/html") {
-		return nil, fmt.Errorf("unexpected content type: %s", contentType)
+	if resp.StatusCode < 400 && !ContentTypeAllowed(contentType, f.allowedContentTypes) {
+		return &Response{
+			URL:         req.URL,
+			FinalURL:    resp.Request.URL.String(),
+			Redirects:   redirectChain(resp),
+			StatusCode:  resp.StatusCode,
+			Headers:     headers,
+			ContentType: contentType,
+		}, nil
+	}
+
+	// Decode compressed responses. As when the transport decompresses a
+	// response, the encoding and length headers no longer apply. Responses
+	// without a body keep their headers, which describe the resource.
+	var bodyReader io.Reader = resp.Body
+	if contentEncoding := resp.Header.Get("Content-Encoding"); contentEncoding != "" && hasBody(resp) {
+		decoded, err := decodeContent(resp.Body, contentEncoding)
+		if err != nil {
+			return nil, err
+		}
+		defer decoded.Close()
+		bodyReader = decoded
+		delete(headers, "Content-Encoding")
+		delete(headers, "Content-Length")
 	}
 
 	// Use LimitReader to prevent reading excessive data
-	limitedReader := io.LimitReader(resp.Body, f.maxBodySize+1)
+	maxBodySize := f.maxBodySize
+	if req.MaxBodySize > 0 {
+		maxBodySize = req.MaxBodySize
+	}
+	if resp.ContentLength > maxBodySize && !req.TruncateBody {
+		return nil, fmt.Errorf("%w: %d bytes exceeds limit of %d bytes",
+			ErrResponseTooLarge, resp.ContentLength, maxBodySize)
+	}
+	limitedReader := io.LimitReader(bodyReader, maxBodySize+1)
 	body, err := io.ReadAll(limitedReader)
 	if err != nil {
 		return nil, err
 	}
 
 	// Check if the body is too large
-	if len(body) > int(f.maxBodySize) {
-		return nil, fmt.Errorf("response size exceeds limit of %d bytes", f.maxBodySize)
-	}
-
-	// Convert response headers to map[string]string
-	headers := make(map[string]string)
-	for name, values := range resp.Header {
-		if len(values) > 0 {
-			headers[name] = values[0] // Use first value if multiple
+	truncated := int64(len(body)) > maxBodySize
+	if truncated {
+		if !req.TruncateBody {
+			return nil, fmt.Errorf("%w: exceeds limit of %d bytes", ErrResponseTooLarge, maxBodySize)
 		}
+		body = body[:maxBodySize]
 	}
 
+	// Decode the body to UTF-8 so that it is parsed correctly
+	body, bodyCharset := decodeBody(body, contentType, truncated)
+
 	// Apply processing options
 	response, err := ProcessRequest(req, string(body))
 	if err != nil {
@@ -116,7 +389,12 @@ func (f *HTTPFetcher) Fetch(ctx context.Context, req *Request) (*Response, error
 
 	// Set other response fields
 	response.URL = req.URL
+	response.FinalURL = resp.Request.URL.String()
+	response.Redirects = redirectChain(resp)
 	response.StatusCode = resp.StatusCode
 	response.Headers = headers
+	response.ContentType = contentType
+	response.Charset = bodyCharset
+	response.Truncated = truncated
 	return response, nil
 }
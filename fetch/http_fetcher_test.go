@@ -0,0 +1,430 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPFetcher_CookieJar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			w.Write([]byte("<p>logged in</p>"))
+		default:
+			cookie, err := r.Cookie("session")
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte("<p>" + cookie.Value + "</p>"))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	fetcher := NewHTTPFetcher(HTTPFetcherOptions{CookieJar: jar})
+	require.Nil(t, DefaultHTTPClient.Jar)
+
+	response, err := fetcher.Fetch(ctx, &Request{URL: server.URL + "/private"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	// Cookies set by a response are sent on later requests
+	_, err = fetcher.Fetch(ctx, &Request{URL: server.URL + "/login"})
+	require.NoError(t, err)
+	response, err = fetcher.Fetch(ctx, &Request{URL: server.URL + "/private"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Contains(t, response.HTML, "abc")
+
+	// Cookies may be seeded directly
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	fetcher.SetCookies(serverURL, []*http.Cookie{{Name: "session", Value: "seeded", Path: "/"}})
+	response, err = fetcher.Fetch(ctx, &Request{URL: server.URL + "/private"})
+	require.NoError(t, err)
+	require.Contains(t, response.HTML, "seeded")
+}
+
+func TestHTTPFetcher_Method(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<p>%s %s %s</p>", r.Method, r.Header.Get("Content-Type"), body)
+	}))
+	defer server.Close()
+
+	fetcher := NewHTTPFetcher(HTTPFetcherOptions{})
+	tests := []struct {
+		name     string
+		request  *Request
+		expected string
+	}{
+		{
+			name:     "defaults to GET",
+			request:  &Request{URL: server.URL},
+			expected: "GET  ",
+		},
+		{
+			name: "POST with body",
+			request: &Request{
+				URL:         server.URL,
+				Method:      http.MethodPost,
+				Body:        []byte(`{"page":2}`),
+				ContentType: "application/json",
+			},
+			expected: `POST application/json {"page":2}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := fetcher.Fetch(context.Background(), tt.request)
+			require.NoError(t, err)
+			require.Contains(t, response.HTML, tt.expected)
+		})
+	}
+}
+
+func TestHTTPFetcher_UserAgents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<p>%s</p>", r.Header.Get("User-Agent"))
+	}))
+	defer server.Close()
+
+	fetch := func(fetcher *HTTPFetcher, request *Request) string {
+		response, err := fetcher.Fetch(context.Background(), request)
+		require.NoError(t, err)
+		return response.HTML
+	}
+
+	// User agents are used in turn and take precedence over default headers
+	fetcher := NewHTTPFetcher(HTTPFetcherOptions{
+		Headers:    map[string]string{"User-Agent": "default"},
+		UserAgents: []string{"agent-a", "agent-b", "agent-c"},
+	})
+	for _, expected := range []string{"agent-a", "agent-b", "agent-c", "agent-a"} {
+		require.Contains(t, fetch(fetcher, &Request{URL: server.URL}), expected)
+	}
+
+	// A request's own header overrides the pool
+	request := &Request{URL: server.URL, Headers: map[string]string{"User-Agent": "override"}}
+	require.Contains(t, fetch(fetcher, request), "override")
+
+	// A single user agent is used for every request
+	fetcher = NewHTTPFetcher(HTTPFetcherOptions{UserAgents: []string{"only"}})
+	require.Contains(t, fetch(fetcher, &Request{URL: server.URL}), "only")
+	require.Contains(t, fetch(fetcher, &Request{URL: server.URL}), "only")
+
+	// Without a pool the default headers apply
+	fetcher = NewHTTPFetcher(HTTPFetcherOptions{Headers: map[string]string{"User-Agent": "default"}})
+	require.Contains(t, fetch(fetcher, &Request{URL: server.URL}), "default")
+}
+
+func TestHTTPFetcher_FinalURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<p>new</p>"))
+	}))
+	defer server.Close()
+
+	fetcher := NewHTTPFetcher(HTTPFetcherOptions{})
+	response, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL + "/old"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, server.URL+"/old", response.URL)
+	require.Equal(t, server.URL+"/new", response.FinalURL)
+	require.Equal(t, []string{server.URL + "/old"}, response.Redirects)
+	require.Equal(t, "text/html", response.ContentType)
+	require.Equal(t, "text/html", response.GetHeader("content-type"))
+}
+
+func TestHTTPFetcher_Redirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var hop int
+		if _, err := fmt.Sscanf(r.URL.Path, "/hop/%d", &hop); err == nil && hop > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/hop/%d", hop-1), http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<p>done</p>"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		maxRedirects int
+		path         string
+		finalPath    string
+		redirects    int
+		statusCode   int
+		tooMany      bool
+	}{
+		{"no redirect", 0, "/hop/0", "/hop/0", 0, http.StatusOK, false},
+		{"within limit", 2, "/hop/2", "/hop/0", 2, http.StatusOK, false},
+		{"over limit", 2, "/hop/3", "", 0, 0, true},
+		{"default limit", 0, "/hop/10", "/hop/0", 10, http.StatusOK, false},
+		{"over default limit", 0, "/hop/11", "", 0, 0, true},
+		{"disabled", -1, "/hop/1", "/hop/1", 0, http.StatusFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := NewHTTPFetcher(HTTPFetcherOptions{MaxRedirects: tt.maxRedirects})
+			response, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL + tt.path})
+			if tt.tooMany {
+				require.ErrorIs(t, err, ErrTooManyRedirects)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.statusCode, response.StatusCode)
+			require.Equal(t, server.URL+tt.path, response.URL)
+			require.Equal(t, server.URL+tt.finalPath, response.FinalURL)
+			require.Len(t, response.Redirects, tt.redirects)
+			if tt.redirects > 0 {
+				require.Equal(t, server.URL+tt.path, response.Redirects[0])
+			}
+		})
+	}
+}
+
+func TestHTTPFetcher_CustomCheckRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		w.Write([]byte("new"))
+	}))
+	defer server.Close()
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	fetcher := NewHTTPFetcher(HTTPFetcherOptions{Client: client})
+	response, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL + "/old"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusMovedPermanently, response.StatusCode)
+	require.Empty(t, response.Redirects)
+}
+
+func TestHTTPFetcher_AllowedContentTypes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer server.Close()
+
+	// Bodies of other content types are not downloaded
+	fetcher := NewHTTPFetcher(HTTPFetcherOptions{})
+	response, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, "application/pdf", response.ContentType)
+	require.Empty(t, response.HTML)
+
+	fetcher = NewHTTPFetcher(HTTPFetcherOptions{AllowedContentTypes: []string{"application/pdf"}})
+	response, err = fetcher.Fetch(context.Background(), &Request{URL: server.URL})
+	require.NoError(t, err)
+	require.Contains(t, response.HTML, "%PDF-1.4")
+}
+
+func TestHTTPFetcher_MaxBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<p>0123456789</p>"))
+	}))
+	defer server.Close()
+
+	fetcher := NewHTTPFetcher(HTTPFetcherOptions{MaxBodySize: 8})
+	_, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL})
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+
+	// The request limit overrides the fetcher limit
+	response, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL, MaxBodySize: 100})
+	require.NoError(t, err)
+	require.False(t, response.Truncated)
+
+	response, err = fetcher.Fetch(context.Background(), &Request{URL: server.URL, TruncateBody: true})
+	require.NoError(t, err)
+	require.True(t, response.Truncated)
+	require.Equal(t, "<p>01234", response.HTML)
+}
+
+func TestHTTPFetcher_ConditionalRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("<p>hello</p>"))
+	}))
+	defer server.Close()
+
+	fetcher := NewHTTPFetcher(HTTPFetcherOptions{})
+	response, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, `"v1"`, response.GetHeader("ETag"))
+
+	response, err = fetcher.Fetch(context.Background(), &Request{URL: server.URL, ETag: `"v1"`})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotModified, response.StatusCode)
+	require.Empty(t, response.HTML)
+}
+
+func TestFetchers(t *testing.T) {
+	mockFetcher := NewMockFetcher()
+	mockFetcher.AddPage("https://example.com")
+	fetchers := Fetchers{"mock": mockFetcher}
+
+	response, err := fetchers.Fetch(context.Background(), &Request{URL: "https://example.com", Fetcher: "mock"})
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", response.URL)
+
+	_, err = fetchers.Fetch(context.Background(), &Request{URL: "https://example.com", Fetcher: ChromeFetcherName})
+	require.Error(t, err)
+}
+
+func TestHTTPFetcher_Transport(t *testing.T) {
+	custom := &http.Transport{MaxIdleConnsPerHost: 4, IdleConnTimeout: time.Second}
+	tests := []struct {
+		name         string
+		options      HTTPFetcherOptions
+		idlePerHost  int
+		connsPerHost int
+		idleTimeout  time.Duration
+		keepAlives   bool
+	}{
+		{"crawl defaults", HTTPFetcherOptions{}, DefaultMaxIdleConnsPerHost, 0, DefaultIdleConnTimeout, true},
+		{"tuned", HTTPFetcherOptions{
+			MaxIdleConnsPerHost: 64,
+			MaxConnsPerHost:     8,
+			IdleConnTimeout:     time.Minute,
+			DisableKeepAlives:   true,
+		}, 64, 8, time.Minute, false},
+		{"client transport settings kept", HTTPFetcherOptions{
+			Client: &http.Client{Transport: custom},
+		}, 4, 0, time.Second, true},
+		{"client transport settings overridden", HTTPFetcherOptions{
+			Client:              &http.Client{Transport: custom},
+			MaxIdleConnsPerHost: 16,
+		}, 16, 0, time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := NewHTTPFetcher(tt.options)
+			transport, ok := fetcher.client.Transport.(*http.Transport)
+			require.True(t, ok)
+			require.Equal(t, tt.idlePerHost, transport.MaxIdleConnsPerHost)
+			require.Equal(t, tt.connsPerHost, transport.MaxConnsPerHost)
+			require.Equal(t, tt.idleTimeout, transport.IdleConnTimeout)
+			require.Equal(t, !tt.keepAlives, transport.DisableKeepAlives)
+		})
+	}
+
+	// Neither the shared default client nor a given transport is modified
+	require.Nil(t, DefaultHTTPClient.Transport)
+	require.Equal(t, 4, custom.MaxIdleConnsPerHost)
+
+	// Other kinds of transport are used as they are
+	roundTripper := http.RoundTripper(roundTripperFunc(http.DefaultTransport.RoundTrip))
+	fetcher := NewHTTPFetcher(HTTPFetcherOptions{Client: &http.Client{Transport: roundTripper}})
+	require.NotNil(t, fetcher.client.Transport)
+	_, ok := fetcher.client.Transport.(*http.Transport)
+	require.False(t, ok)
+}
+
+// roundTripperFunc adapts a function to an http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestHTTPFetcher_ConnectionReuse(t *testing.T) {
+	var connections atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<p>ok</p>"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		options     HTTPFetcherOptions
+		connections int64
+	}{
+		{"keep-alive", HTTPFetcherOptions{}, 1},
+		{"keep-alives disabled", HTTPFetcherOptions{DisableKeepAlives: true}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connections.Store(0)
+			fetcher := NewHTTPFetcher(tt.options)
+			for i := 0; i < 5; i++ {
+				_, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL})
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.connections, connections.Load())
+		})
+	}
+}
+
+func TestHTTPFetcher_HTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<p>" + r.Proto + "</p>"))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		disable bool
+		proto   string
+	}{
+		{"attempted by default", false, "HTTP/2.0"},
+		{"disabled", true, "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The test server's client trusts its certificate
+			fetcher := NewHTTPFetcher(HTTPFetcherOptions{
+				Client:       server.Client(),
+				DisableHTTP2: tt.disable,
+			})
+			response, err := fetcher.Fetch(context.Background(), &Request{URL: server.URL})
+			require.NoError(t, err)
+			require.Contains(t, response.HTML, tt.proto)
+		})
+	}
+}
@@ -8,14 +8,17 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
-// MockFetcher implements the Fetcher interface for testing
+// MockFetcher implements the Fetcher interface for testing. It serves
+// predefined responses keyed by URL and records the requests it receives.
 type MockFetcher struct {
 	mock.Mock
 	responses map[string]*Response
 	errors    map[string]error
+	requests  []*Request
 	mutex     sync.RWMutex
 }
 
+// NewMockFetcher creates a MockFetcher with no responses configured.
 func NewMockFetcher() *MockFetcher {
 	return &MockFetcher{
 		responses: make(map[string]*Response),
@@ -23,6 +26,22 @@ func NewMockFetcher() *MockFetcher {
 	}
 }
 
+// NewMockResponse builds a successful HTML response for the URL containing
+// links to the given URLs.
+func NewMockResponse(url string, links ...string) *Response {
+	response := &Response{
+		URL:         url,
+		StatusCode:  200,
+		Headers:     map[string]string{"Content-Type": "text/html"},
+		ContentType: "text/html",
+	}
+	for _, link := range links {
+		response.Links = append(response.Links, &Link{URL: link})
+	}
+	return response
+}
+
+// AddResponse configures the response returned for the URL.
 func (m *MockFetcher) AddResponse(url string, response *Response) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -30,6 +49,23 @@ func (m *MockFetcher) AddResponse(url string, response *Response) {
 	m.responses[url] = response
 }
 
+// AddPage configures a successful response for the URL containing links to
+// the given URLs.
+func (m *MockFetcher) AddPage(url string, links ...string) {
+	m.AddResponse(url, NewMockResponse(url, links...))
+}
+
+// AddStatus configures an empty response with the given status code for the
+// URL, as the HTTP fetcher returns for error responses.
+func (m *MockFetcher) AddStatus(url string, statusCode int) {
+	m.AddResponse(url, &Response{
+		URL:        url,
+		StatusCode: statusCode,
+		Headers:    map[string]string{},
+	})
+}
+
+// AddError configures the error returned for the URL.
 func (m *MockFetcher) AddError(url string, err error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -37,10 +73,38 @@ func (m *MockFetcher) AddError(url string, err error) {
 	m.errors[url] = err
 }
 
-func (m *MockFetcher) Fetch(ctx context.Context, req *Request) (*Response, error) {
+// Requests returns copies of the requests received, in the order received.
+func (m *MockFetcher) Requests() []*Request {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	requests := make([]*Request, len(m.requests))
+	copy(requests, m.requests)
+	return requests
+}
+
+// RequestedURLs returns the URLs of the requests received, in the order
+// received. A URL appears once for each time it was fetched.
+func (m *MockFetcher) RequestedURLs() []string {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
+	urls := make([]string, len(m.requests))
+	for i, req := range m.requests {
+		urls[i] = req.URL
+	}
+	return urls
+}
+
+// Fetch returns the error or response configured for the request URL. An
+// error is returned for URLs that have not been configured.
+func (m *MockFetcher) Fetch(ctx context.Context, req *Request) (*Response, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	recorded := *req
+	m.requests = append(m.requests, &recorded)
+
 	if err, exists := m.errors[req.URL]; exists {
 		return nil, err
 	}
@@ -69,7 +69,7 @@ func ProcessRequest(request *Request, html string) (*Response, error) {
 	// Massage link types
 	var links []*Link
 	for _, link := range doc.Links() {
-		links = append(links, &Link{URL: link.URL, Text: link.Text})
+		links = append(links, &Link{URL: link.URL, Text: link.Text, Rel: link.Rel})
 	}
 
 	return &Response{
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"unicode"
 	"unicode/utf8"
+
+	"golang.org/x/net/idna"
 )
 
 func removeNonPrintableChars(input string) string {
@@ -37,13 +39,69 @@ func NormalizeText(text string) string {
 	return text
 }
 
+// DefaultStripParams lists the tracking query parameters removed by
+// NormalizeURLWith when NormalizeOptions.StripParams is nil.
+var DefaultStripParams = []string{"utm_*", "fbclid", "gclid"}
+
+// NormalizeOptions controls how NormalizeURLWith normalizes a URL. The zero
+// value matches NormalizeURL, which converts the scheme to https, removes the
+// query and fragment, and removes a path of "/", while leaving the case of the
+// host and path, any port, and other trailing slashes unchanged.
+type NormalizeOptions struct {
+	// KeepQuery retains the query parameters, other than those matching
+	// StripParams. The remaining parameters are sorted so that equivalent
+	// URLs normalize identically. By default the whole query is removed.
+	KeepQuery bool
+
+	// StripParams lists the query parameters removed when KeepQuery is set.
+	// A trailing "*" matches any parameter with the given prefix. Defaults to
+	// DefaultStripParams when nil. Use an empty slice to keep all parameters.
+	StripParams []string
+
+	// PreserveQueryOrder keeps the remaining query parameters in their
+	// original order rather than sorting them.
+	PreserveQueryOrder bool
+
+	// LowercaseHost converts the host to lower case.
+	LowercaseHost bool
+
+	// StripWWW removes a leading "www." from the host, so that
+	// www.example.com and example.com normalize identically. Only use this
+	// for sites that serve the same content on both hosts.
+	StripWWW bool
+
+	// LowercasePath converts the path to lower case. Only use this for sites
+	// known to treat paths case-insensitively.
+	LowercasePath bool
+
+	// RemoveDefaultPort removes the port when it is the default for the
+	// scheme, such as 443 for https.
+	RemoveDefaultPort bool
+
+	// KeepFragment retains the URL fragment.
+	KeepFragment bool
+
+	// TrimTrailingSlash removes trailing slashes from the path, so that
+	// "/docs/" and "/docs" normalize identically.
+	TrimTrailingSlash bool
+}
+
 // NormalizeURL parses a URL string and returns a normalized URL. The following
 // transformations are applied:
 // - Trim whitespace
 // - Convert http:// to https://
 // - Add https:// prefix if missing
+// - Convert internationalized hosts to their ASCII (punycode) form
 // - Remove any query parameters and URL fragments
 func NormalizeURL(value string) (*url.URL, error) {
+	return NormalizeURLWith(value, NormalizeOptions{})
+}
+
+// NormalizeURLWith parses a URL string and returns a URL normalized according
+// to the options. Whitespace is trimmed, the scheme is converted or added, and
+// internationalized hosts are converted to ASCII as with NormalizeURL. The
+// remaining transformations are controlled by the options.
+func NormalizeURLWith(value string, opts NormalizeOptions) (*url.URL, error) {
 	value = strings.TrimSpace(value)
 	if value == "" {
 		return nil, fmt.Errorf("invalid empty url")
@@ -61,15 +119,120 @@ func NormalizeURL(value string) (*url.URL, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid url %q: %w", value, err)
 	}
+	if u.Host, err = asciiHost(u); err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", value, err)
+	}
+	if opts.LowercaseHost {
+		u.Host = strings.ToLower(u.Host)
+	}
+	if opts.StripWWW {
+		u.Host = trimWWW(u.Host)
+	}
+	if opts.RemoveDefaultPort {
+		port := u.Port()
+		if (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+			u.Host = strings.TrimSuffix(u.Host, ":"+port)
+		}
+	}
+	if opts.LowercasePath {
+		u.Path = strings.ToLower(u.Path)
+		u.RawPath = ""
+	}
 	u.ForceQuery = false
-	u.RawQuery = ""
-	u.Fragment = ""
-	if u.Path == "/" {
+	switch {
+	case !opts.KeepQuery:
+		u.RawQuery = ""
+	case opts.PreserveQueryOrder:
+		u.RawQuery = stripRawParams(u.RawQuery, opts.StripParams)
+	default:
+		u.RawQuery = stripParams(u.Query(), opts.StripParams).Encode()
+	}
+	if !opts.KeepFragment {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+	if opts.TrimTrailingSlash {
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = strings.TrimRight(u.RawPath, "/")
+	} else if u.Path == "/" {
 		u.Path = ""
 	}
 	return u, nil
 }
 
+// asciiHost returns the URL's host with an internationalized hostname
+// converted to its ASCII (punycode) form, which is also lowercased. Hosts that
+// are already ASCII, including punycode hosts, are returned unchanged.
+func asciiHost(u *url.URL) (string, error) {
+	hostname := u.Hostname()
+	if isASCII(hostname) {
+		return u.Host, nil
+	}
+	ascii, err := idna.Lookup.ToASCII(hostname)
+	if err != nil {
+		return "", err
+	}
+	if port := u.Port(); port != "" {
+		return ascii + ":" + port, nil
+	}
+	return ascii, nil
+}
+
+// isASCII reports whether the string contains only ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// stripParams removes the query parameters matching the patterns, which
+// default to DefaultStripParams when nil.
+func stripParams(query url.Values, patterns []string) url.Values {
+	for name := range query {
+		if matchesParam(name, patterns) {
+			query.Del(name)
+		}
+	}
+	return query
+}
+
+// stripRawParams removes the query parameters matching the patterns from a
+// raw query string, keeping the order of the remaining parameters.
+func stripRawParams(rawQuery string, patterns []string) string {
+	var kept []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !matchesParam(name, patterns) {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// matchesParam reports whether the query parameter name matches any of the
+// patterns, which default to DefaultStripParams when nil.
+func matchesParam(name string, patterns []string) bool {
+	if patterns == nil {
+		patterns = DefaultStripParams
+	}
+	for _, pattern := range patterns {
+		prefix, isPrefix := strings.CutSuffix(pattern, "*")
+		if name == pattern || (isPrefix && strings.HasPrefix(name, prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
 // SortURLs sorts a slice of URLs by their string representation.
 func SortURLs(urls []*url.URL) {
 	sort.Slice(urls, func(i, j int) bool {
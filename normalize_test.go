@@ -141,6 +141,26 @@ func TestNormalizeURL(t *testing.T) {
 			input:    "  https://example.com  ",
 			expected: "https://example.com",
 		},
+		{
+			name:     "internationalized host converted to punycode",
+			input:    "https://münchen.de/stadt",
+			expected: "https://xn--mnchen-3ya.de/stadt",
+		},
+		{
+			name:     "mixed case internationalized host",
+			input:    "https://MÜNCHEN.de:8443",
+			expected: "https://xn--mnchen-3ya.de:8443",
+		},
+		{
+			name:     "punycode host unchanged",
+			input:    "https://xn--mnchen-3ya.de/stadt",
+			expected: "https://xn--mnchen-3ya.de/stadt",
+		},
+		{
+			name:     "internationalized host without protocol",
+			input:    "bücher.example",
+			expected: "https://xn--bcher-kva.example",
+		},
 		{
 			name:        "empty URL",
 			input:       "",
@@ -171,6 +191,118 @@ func TestNormalizeURL(t *testing.T) {
 	}
 }
 
+func TestNormalizeURLWith(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     NormalizeOptions
+		expected string
+	}{
+		{
+			name:     "query removed by default",
+			input:    "https://example.com/path?page=2&utm_source=x",
+			expected: "https://example.com/path",
+		},
+		{
+			name:     "tracking parameters removed",
+			input:    "https://example.com/path?utm_source=x&utm_medium=y&fbclid=1&gclid=2",
+			opts:     NormalizeOptions{KeepQuery: true},
+			expected: "https://example.com/path",
+		},
+		{
+			name:     "meaningful parameters kept and sorted",
+			input:    "https://example.com/path?sort=asc&utm_campaign=x&page=2#top",
+			opts:     NormalizeOptions{KeepQuery: true},
+			expected: "https://example.com/path?page=2&sort=asc",
+		},
+		{
+			name:     "custom parameters removed",
+			input:    "https://example.com/?ref=home&session_id=abc&page=2",
+			opts:     NormalizeOptions{KeepQuery: true, StripParams: []string{"ref", "session_*"}},
+			expected: "https://example.com?page=2",
+		},
+		{
+			name:     "all parameters kept",
+			input:    "https://example.com/path?utm_source=x",
+			opts:     NormalizeOptions{KeepQuery: true, StripParams: []string{}},
+			expected: "https://example.com/path?utm_source=x",
+		},
+		{
+			name:     "query order preserved",
+			input:    "https://example.com/path?sort=asc&utm_source=x&page=2",
+			opts:     NormalizeOptions{KeepQuery: true, PreserveQueryOrder: true},
+			expected: "https://example.com/path?sort=asc&page=2",
+		},
+		{
+			name:     "case and port unchanged by default",
+			input:    "https://Example.COM:443/Docs/",
+			expected: "https://Example.COM:443/Docs/",
+		},
+		{
+			name:     "host lowercased",
+			input:    "https://Example.COM/Docs",
+			opts:     NormalizeOptions{LowercaseHost: true},
+			expected: "https://example.com/Docs",
+		},
+		{
+			name:     "path lowercased",
+			input:    "https://example.com/Docs/Intro",
+			opts:     NormalizeOptions{LowercasePath: true},
+			expected: "https://example.com/docs/intro",
+		},
+		{
+			name:     "default port removed",
+			input:    "https://example.com:443/docs",
+			opts:     NormalizeOptions{RemoveDefaultPort: true},
+			expected: "https://example.com/docs",
+		},
+		{
+			name:     "other port kept",
+			input:    "https://example.com:8443/docs",
+			opts:     NormalizeOptions{RemoveDefaultPort: true},
+			expected: "https://example.com:8443/docs",
+		},
+		{
+			name:     "fragment kept",
+			input:    "https://example.com/docs#intro",
+			opts:     NormalizeOptions{KeepFragment: true},
+			expected: "https://example.com/docs#intro",
+		},
+		{
+			name:     "www removed",
+			input:    "https://www.example.com/docs",
+			opts:     NormalizeOptions{StripWWW: true},
+			expected: "https://example.com/docs",
+		},
+		{
+			name:     "www removed from subdomain",
+			input:    "https://WWW.blog.example.com/docs",
+			opts:     NormalizeOptions{StripWWW: true},
+			expected: "https://blog.example.com/docs",
+		},
+		{
+			name:     "similar prefixes kept",
+			input:    "https://www2.example.com/docs",
+			opts:     NormalizeOptions{StripWWW: true},
+			expected: "https://www2.example.com/docs",
+		},
+		{
+			name:     "trailing slash trimmed",
+			input:    "https://example.com/docs/?page=2",
+			opts:     NormalizeOptions{KeepQuery: true, TrimTrailingSlash: true},
+			expected: "https://example.com/docs?page=2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NormalizeURLWith(tt.input, tt.opts)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result.String())
+		})
+	}
+}
+
 func TestAreSameHost(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -219,6 +351,41 @@ func TestAreSameHost(t *testing.T) {
 	}
 }
 
+func TestAreSameHostIgnoringWWW(t *testing.T) {
+	tests := []struct {
+		name     string
+		url1     string
+		url2     string
+		expected bool
+	}{
+		{"same host", "https://example.com/a", "https://example.com/b", true},
+		{"www and bare", "https://www.example.com", "https://example.com/about", true},
+		{"bare and www", "https://example.com", "https://www.example.com", true},
+		{"case ignored", "https://WWW.Example.com", "https://example.com", true},
+		{"www subdomain and bare subdomain", "https://www.blog.example.com", "https://blog.example.com", true},
+		{"subdomain and parent", "https://blog.example.com", "https://example.com", false},
+		{"www subdomain and parent", "https://www.blog.example.com", "https://www.example.com", false},
+		{"other subdomain", "https://www.example.com", "https://api.example.com", false},
+		{"similar prefix", "https://www2.example.com", "https://example.com", false},
+		{"single label", "https://www.com", "https://com", false},
+		{"different ports", "https://www.example.com:8443", "https://example.com", false},
+		{"nil URLs", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var u1, u2 *url.URL
+			if tt.url1 != "" {
+				u1, _ = url.Parse(tt.url1)
+			}
+			if tt.url2 != "" {
+				u2, _ = url.Parse(tt.url2)
+			}
+			require.Equal(t, tt.expected, AreSameHostIgnoringWWW(u1, u2))
+		})
+	}
+}
+
 func TestAreRelatedHosts(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -262,6 +429,42 @@ func TestAreRelatedHosts(t *testing.T) {
 			url2:     "https://localhost",
 			expected: false,
 		},
+		{
+			name:     "subdomains under a multi-part suffix",
+			url1:     "https://www.example.co.uk",
+			url2:     "https://shop.example.co.uk",
+			expected: true,
+		},
+		{
+			name:     "different sites sharing a multi-part suffix",
+			url1:     "https://foo.co.uk",
+			url2:     "https://bar.co.uk",
+			expected: false,
+		},
+		{
+			name:     "different buckets on a hosting suffix",
+			url1:     "https://one.s3.amazonaws.com",
+			url2:     "https://two.s3.amazonaws.com",
+			expected: false,
+		},
+		{
+			name:     "public suffix host",
+			url1:     "https://co.uk",
+			url2:     "https://example.co.uk",
+			expected: false,
+		},
+		{
+			name:     "ports and case are ignored",
+			url1:     "https://WWW.Example.com:8443",
+			url2:     "http://api.example.com",
+			expected: true,
+		},
+		{
+			name:     "ip addresses",
+			url1:     "http://10.0.0.1",
+			url2:     "http://192.168.0.1",
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {